@@ -1,147 +1,516 @@
 package main
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"text/template"
 	"time"
 )
 
 const EXTENSION = ".tg"
 
+// CLIOptions holds every option passed on the command line, generator options included.
+type CLIOptions struct {
+	generator GeneratorOptions
+	// Parsing of a file stops after this many syntax errors, 0 means there is no limit
+	maxErrors         int
+	diagnosticsFormat DiagnosticsFormat
+	// Box fields of types containing themselves by value, instead of reporting them as errors
+	boxRecursive bool
+	lint         LintOptions
+	// Options of the fmt command. Files are rewritten unless any of these is set.
+	formatCheck bool
+	formatDiff  bool
+	// Executable generating the code instead of a built-in generator, empty when there is none
+	plugin           string
+	pluginParameters map[string]string
+	// Directory of text/template files generating the code instead of a built-in generator, empty when there is none
+	template string
+	// Languages passed with --lang, generated together with the ones passed in place of the language
+	languages []string
+	// Options of a single language, such as --go.package=pets, in the order they were passed
+	languageOptions []languageOption
+	// Directory generated files are written to, mirroring the input directory, empty to write them next to the schema files
+	out string
+}
+
+// languageOption is an option passed as --<language>.<name>[=value], overriding the option for that language only
+type languageOption struct {
+	language string
+	name     string
+	value    string
+	hasValue bool
+	// Set by the configuration file, which may configure languages not generated in every run
+	configured bool
+}
+
+// Names of options accepted for a single language, see applyLanguageOption
+var LANGUAGE_OPTIONS = []string{"package", "indent", "receiver-fallback", "json", "records", "per-type", "type-case", "field-case", "out"}
+
+// generationTarget is a language generated in a run, with options of that language applied
+type generationTarget struct {
+	generator Generator
+	options   GeneratorOptions
+	// Directory generated files are written to following the layout of the language, empty when they're written
+	// next to the schema file
+	outDir string
+	// Names of options passed for this language only
+	overridden []string
+}
+
+func defaultCLIOptions() CLIOptions {
+	return CLIOptions{
+		generator:         defaultOptions(),
+		maxErrors:         20,
+		diagnosticsFormat: FORMAT_TEXT,
+		lint:              defaultLintOptions(),
+		pluginParameters:  make(map[string]string),
+	}
+}
+
+// Prints the diagnostics and exits with code 1. In the text format diagnostics are rendered with snippets
+// of the parsed source, followed by the note (if any).
+func exitWithDiagnostics(diagnostics []Diagnostic, parser *Parser, note string, format DiagnosticsFormat) {
+	if format == FORMAT_TEXT {
+		renderDiagnostics(diagnostics, parser)
+		if note != "" {
+			fmt.Println(note)
+		}
+	} else {
+		err := writeDiagnostics(os.Stdout, diagnostics, format)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "ERROR writing diagnostics:", err)
+		}
+	}
+	os.Exit(1)
+}
+
+// Prints the diagnostics with snippets of the parsed source
+func renderDiagnostics(diagnostics []Diagnostic, parser *Parser) {
+	renderer := DiagnosticRenderer{
+		sources: map[string][]byte{parser.filepath: parser.lexer.data},
+		colors:  shouldUseColors(os.Stdout),
+	}
+	for _, diagnostic := range diagnostics {
+		fmt.Println(renderer.render(diagnostic))
+	}
+}
+
+// Prints an error for a language without a generator and exits
+func exitWithUnknownLanguage(lang string) {
+	supported := make([]string, 0, len(GENERATORS))
+	for _, generator := range allGenerators(defaultOptions()) {
+		supported = append(supported, generator.name())
+	}
+
+	if languageIdentifierToLanguage(lang) != NONE {
+		fmt.Printf("ERROR: There is no generator for language '%v' yet. Supported languages: %v\n", lang, strings.Join(supported, ", "))
+	} else {
+		fmt.Printf("ERROR: Unrecognized or misspelled language identifier: %v.%v\n", lang, didYouMean(lang, generatorIdentifiers()))
+	}
+	os.Exit(1)
+}
+
+// Creates a target for each of the languages, which may be comma separated lists such as 'go,rust,kotlin'.
+// Languages listed more than once are generated once. Exits when any of them has no generator or an option
+// of a language is invalid.
+func resolveTargets(languages []string, cliOptions CLIOptions) []generationTarget {
+	targets := make([]generationTarget, 0)
+	for _, list := range languages {
+		for _, lang := range strings.Split(list, ",") {
+			lang = strings.TrimSpace(lang)
+			generator := findGenerator(lang, cliOptions.generator)
+			if generator == nil {
+				exitWithUnknownLanguage(lang)
+			}
+
+			duplicate := slices.ContainsFunc(targets, func(target generationTarget) bool { return target.generator.name() == generator.name() })
+			if !duplicate {
+				targets = append(targets, generationTarget{generator: generator, options: cliOptions.generator, outDir: cliOptions.out})
+			}
+		}
+	}
+
+	for _, option := range cliOptions.languageOptions {
+		generator := findGenerator(option.language, defaultOptions())
+		if generator == nil {
+			exitWithUnknownLanguage(option.language)
+		}
+
+		index := slices.IndexFunc(targets, func(target generationTarget) bool { return target.generator.name() == generator.name() })
+		if index < 0 {
+			if !option.configured {
+				fmt.Printf("WARN: Option --%v.%v is ignored, since %v is not generated.\n", option.language, option.name, generator.name())
+			}
+			continue
+		}
+		applyLanguageOption(&targets[index], option)
+	}
+
+	for i := range targets {
+		target := &targets[i]
+		target.generator = findGenerator(target.generator.name(), target.options)
+		for _, option := range unsupportedOptions(target.generator, target.options) {
+			if slices.Contains(target.overridden, option) {
+				option = target.generator.name() + "." + option
+			}
+			fmt.Printf("WARN: Option --%v is not supported by the %v generator.\n", option, target.generator.name())
+		}
+	}
+	return targets
+}
+
+// Applies the option to the target. Boolean options may be passed without a value. Exits when the option is invalid.
+func applyLanguageOption(target *generationTarget, option languageOption) {
+	flag := fmt.Sprintf("--%v.%v", option.language, option.name)
+	requireValue := func() string {
+		if !option.hasValue || option.value == "" {
+			fmt.Printf("ERROR: No value passed for %v, expected %v=<value>\n", flag, flag)
+			os.Exit(1)
+		}
+		return option.value
+	}
+	parseBool := func() bool {
+		if !option.hasValue {
+			return true
+		}
+		value, err := strconv.ParseBool(option.value)
+		if err != nil {
+			fmt.Printf("ERROR: Invalid value of %v: %v. Expected true or false.\n", flag, option.value)
+			os.Exit(1)
+		}
+		return value
+	}
+
+	options := &target.options
+	switch option.name {
+	case "package":
+		options.packageName = requireValue()
+	case "indent":
+		indent, err := strconv.Atoi(requireValue())
+		if err != nil || indent < 1 {
+			fmt.Printf("ERROR: Invalid indentation: %v\n", option.value)
+			os.Exit(1)
+		}
+		options.indent = indent
+	case "receiver-fallback":
+		options.receiverNameFallback = requireValue()
+	case "json":
+		options.jsonAnnotations = parseBool()
+	case "records":
+		options.records = parseBool()
+	case "per-type":
+		options.filePerType = parseBool()
+	case "type-case", "field-case":
+		nameCase := requireValue()
+		if !slices.Contains(NAME_CASES, nameCase) {
+			fmt.Printf("ERROR: Invalid value of %v: %v. Expected one of: %v.%v\n", flag, nameCase, strings.Join(NAME_CASES, ", "), didYouMean(nameCase, NAME_CASES))
+			os.Exit(1)
+		}
+		if option.name == "type-case" {
+			options.typeCase = nameCase
+		} else {
+			options.fieldCase = nameCase
+		}
+	case "out":
+		target.outDir = requireValue()
+	default:
+		fmt.Printf("ERROR: Unknown option %v.%v\n", flag, didYouMean(option.name, LANGUAGE_OPTIONS))
+		os.Exit(1)
+	}
+	target.overridden = append(target.overridden, option.name)
+}
+
+// Generators report problems with diagnostics, other errors are wrapped into one
+func exitWithGeneratorError(err error, parser *Parser, format DiagnosticsFormat) {
+	var diagnostic Diagnostic
+	if !errors.As(err, &diagnostic) {
+		diagnostic = newDiagnostic(CODE_GENERATOR_FAILURE, parser.filepath, LinePos{}, err.Error())
+	}
+	exitWithDiagnostics([]Diagnostic{diagnostic}, parser, "", format)
+}
+
 func executeCLI() {
 	args := os.Args[1:]
-	if len(args) < 2 {
+	if len(args) > 0 && args[0] == "lint" {
+		executeLint(args[1:])
+		return
+	}
+
+	if len(args) > 0 && args[0] == "fmt" {
+		executeFormat(args[1:])
+		return
+	}
+
+	if len(args) > 0 && args[0] == "dump" {
+		executeDump(args[1:])
+		return
+	}
+
+	if len(args) > 0 && args[0] == "lsp" {
+		server := newLanguageServer(os.Stdin, os.Stdout)
+		os.Exit(server.serve())
+	}
+
+	if len(args) < 1 {
 		printHelp()
 		return
 	}
 	path := args[0]
 
-	info, err := getPathInfo(path)
-	if err != nil {
-		fmt.Printf("ERROR: %v\n", err.Error())
-		os.Exit(1)
+	// A plugin, templates or --lang take the place of the language: <path> --plugin=<executable> [options...]
+	languages := make([]string, 0)
+	optionArgs := args[1:]
+	if len(args) > 1 && !strings.HasPrefix(args[1], "-") {
+		languages = append(languages, args[1])
+		optionArgs = args[2:]
 	}
 
-	lang := args[1]
-	language := languageIdentifierToLanguage(lang)
-	if language == NONE {
-		fmt.Printf("ERROR: Unrecognized, unsupported or misspelled language identifier: %v\n", lang)
-		os.Exit(1)
+	cliOptions, config := loadOptions(path, optionArgs)
+	options := cliOptions.generator
+	format := cliOptions.diagnosticsFormat
+	languages = append(languages, cliOptions.languages...)
+	// Targets of the configuration file are generated unless the command line says otherwise
+	if len(languages) == 0 && cliOptions.plugin == "" && cliOptions.template == "" && config != nil {
+		languages = config.targets
 	}
 
-	options := parseArguments(args[2:])
-
-	var files []string
-	if info.IsDir() {
-		entries, err := os.ReadDir(path)
-		if err != nil {
-			fmt.Println("Failed to open directory.")
+	var targets []generationTarget
+	if len(languages) > 0 {
+		if cliOptions.plugin != "" || cliOptions.template != "" {
+			fmt.Println("ERROR: Languages cannot be generated together with a plugin or templates")
 			os.Exit(1)
 		}
-		for _, entry := range entries {
-			if strings.HasSuffix(entry.Name(), EXTENSION) {
-				tgFile := filepath.Join(path, entry.Name())
-				files = append(files, tgFile)
-			}
-		}
-	} else {
-		if strings.HasSuffix(path, EXTENSION) {
-			files = append(files, path)
-		}
+		targets = resolveTargets(languages, cliOptions)
+	} else if cliOptions.plugin == "" && cliOptions.template == "" {
+		printHelp()
+		return
 	}
+	// Only diagnostics are written to the standard output in machine readable formats
+	verbose := format == FORMAT_TEXT
 
-	if len(files) == 0 {
-		fmt.Printf("Nothing to do. Ensure your files end with %v\n", EXTENSION)
-		os.Exit(1)
+	files := collectSchemaFiles(path, config)
+	// Directory structure of the input is mirrored in output directories
+	inputDirectory := path
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		inputDirectory = filepath.Dir(path)
 	}
 	start := time.Now()
-	fmt.Printf("Processing %v files\n", len(files))
+	if verbose {
+		fmt.Printf("Processing %v files\n", len(files))
+	}
+	// Warnings and notes reported by generators and plugins
+	reported := make([]Diagnostic, 0)
+	// Files are written once every file was generated for every target, so that a failure leaves no partial output
+	pending := make([]GeneratedFile, 0)
+
+	var templates *template.Template
+	if cliOptions.template != "" {
+		var err error
+		templates, err = loadTemplates(cliOptions.template, options)
+		if err != nil {
+			exitWithGeneratorError(err, &Parser{filepath: cliOptions.template}, format)
+		}
+	}
 	for _, file := range files {
-		fmt.Printf("  %v\n", file)
+		if verbose {
+			fmt.Printf("  %v\n", file)
+		}
 		parser, success := CreateParser(file)
 		if !success {
 			os.Exit(1)
 		}
 
+		parser.maxErrors = cliOptions.maxErrors
+		parser.boxRecursiveTypes = cliOptions.boxRecursive
 		parseResult := ParseFile(&parser)
 		if !parseResult.success {
-			fmt.Println(parseResult.message)
-			os.Exit(1)
+			exitWithDiagnostics(parser.diagnostics, &parser, parser.errorLimitNote(), format)
 		}
 
 		checkResult := TypecheckFile(&parser)
 		if !checkResult.success {
-			fmt.Println(checkResult.message)
-			os.Exit(1)
+			exitWithDiagnostics(parser.diagnostics, &parser, "", format)
 		}
 
-		codeBuffer := bytes.Buffer{}
+		if cliOptions.plugin != "" {
+			generated, diagnostics := executePlugin(&parser, cliOptions)
+			reported = append(reported, diagnostics...)
+			for _, file := range generated {
+				file.path = outputPath(file.path, cliOptions.out, inputDirectory, nil)
+				pending = append(pending, file)
+			}
+			continue
+		}
 
-		switch language {
-		case JAVASCRIPT:
-			js := JavascriptGenerator{options}
-			js.generate(parser.structs, &codeBuffer)
-		case GO:
-			goGen := GoGenerator{options}
-			err = goGen.generate(parser.structs, &codeBuffer, parser.filepath)
+		if templates != nil {
+			outputs, err := renderTemplates(templates, &parser)
 			if err != nil {
-				fmt.Println(err)
-				os.Exit(1)
+				exitWithGeneratorError(err, &parser, format)
 			}
-		case JAVA:
-			java := JavaGenerator{options}
-			err = java.generate(parser.structs, &codeBuffer, parser.filepath)
-			if err != nil {
-				fmt.Println(err)
-				os.Exit(1)
+			for _, output := range outputs {
+				outputName := outputPath(output.name, cliOptions.out, inputDirectory, nil)
+				pending = append(pending, GeneratedFile{path: outputName, content: []byte(output.content)})
 			}
-		case KOTLIN:
-			kotlin := KotlinGenerator{options}
-			err = kotlin.generate(parser.structs, &codeBuffer, parser.filepath)
-			if err != nil {
-				fmt.Println(err)
-				os.Exit(1)
+			continue
+		}
+
+		// The schema is never modified by generators, so a single parse serves every language
+		schema := parser.Schema()
+		for _, target := range targets {
+			diagnostics := checkCapabilities(target.generator, &schema)
+			if verbose {
+				renderDiagnostics(diagnostics, &parser)
 			}
-		case RUST:
-			rust := RustGenerator{options}
-			err = rust.generate(parser.structs, &codeBuffer, parser.filepath)
+			reported = append(reported, diagnostics...)
+
+			generated, err := target.generator.generate(&schema)
 			if err != nil {
-				fmt.Println(err)
-				os.Exit(1)
+				exitWithGeneratorError(err, &parser, format)
 			}
-		default:
-			fmt.Println("Unsupported language (coming soon).")
+			for _, file := range generated {
+				file.path = outputPath(file.path, target.outDir, inputDirectory, target.generator.layoutPath)
+				pending = append(pending, file)
+			}
+		}
+	}
+	writeGeneratedFiles(pending)
+	end := time.Now()
+	timeElapsed := end.Sub(start)
+	if verbose {
+		fmt.Printf("Time elapsed processing: %v\n", timeElapsed)
+	} else {
+		err := writeDiagnostics(os.Stdout, reported, format)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "ERROR writing diagnostics:", err)
 			os.Exit(1)
 		}
+	}
+}
 
-		// cat.tg -> cat.js
-		newExtension := languageToExtension(language)
-		generatedFile := changeExtension(file, newExtension)
-		writer := openWriter(generatedFile)
-		if writer == nil {
-			os.Exit(1)
+// Generates code for the typechecked file with a plugin and returns the files it generated, placed next to the schema
+// file. Exits when the plugin fails or reports errors, otherwise returns the reported warnings and notes.
+func executePlugin(parser *Parser, cliOptions CLIOptions) ([]GeneratedFile, []Diagnostic) {
+	format := cliOptions.diagnosticsFormat
+	files, diagnostics, err := runPlugin(cliOptions.plugin, parser, cliOptions.generator, cliOptions.pluginParameters)
+	if err != nil {
+		exitWithGeneratorError(err, parser, format)
+	}
+
+	for _, diagnostic := range diagnostics {
+		if diagnostic.severity == SEVERITY_ERROR {
+			exitWithDiagnostics(diagnostics, parser, "", format)
 		}
-		_, writeErr := writer.Write(codeBuffer.Bytes())
-		if writeErr != nil {
-			fmt.Println("ERROR writing contents to file:", writeErr)
+	}
+
+	if format == FORMAT_TEXT {
+		renderDiagnostics(diagnostics, parser)
+	}
+
+	directory := filepath.Dir(parser.filepath)
+	generated := make([]GeneratedFile, len(files))
+	for i, file := range files {
+		generated[i] = GeneratedFile{path: filepath.Join(directory, file.Name), content: []byte(file.Content)}
+	}
+	return generated, diagnostics
+}
+
+// Moves a file generated next to its schema file into the output directory, where it's placed at the same path
+// relative to the input directory, rearranged by the layout (if any). Paths are kept when there is no output directory.
+func outputPath(path string, outDir string, inputDirectory string, layout func(relative string) string) string {
+	if outDir == "" {
+		return path
+	}
+
+	relative, err := relativePath(inputDirectory, path)
+	if err != nil || !filepath.IsLocal(relative) {
+		relative = filepath.Base(path)
+	}
+	if layout != nil {
+		relative = layout(relative)
+	}
+	return filepath.Join(outDir, relative)
+}
+
+// Writes the files, creating missing directories. Every file is first written to a temporary file next to it,
+// which replace the files once all of them were written. Exits on failure, leaving existing files untouched.
+func writeGeneratedFiles(files []GeneratedFile) {
+	for i, file := range files {
+		if slices.ContainsFunc(files[:i], func(other GeneratedFile) bool { return other.path == file.path }) {
+			fmt.Printf("ERROR: Multiple files would be written to %v. Declarations may be repeated in schema files of the same directory.\n", file.path)
 			os.Exit(1)
 		}
-		writer.Flush()
 	}
-	end := time.Now()
-	timeElapsed := end.Sub(start)
-	fmt.Printf("Time elapsed processing: %v\n", timeElapsed)
+
+	staged := make([]string, 0, len(files))
+	fail := func(message string, err error) {
+		for _, temporary := range staged {
+			os.Remove(temporary)
+		}
+		fmt.Println(message, err)
+		os.Exit(1)
+	}
+
+	for _, file := range files {
+		err := os.MkdirAll(filepath.Dir(file.path), 0755)
+		if err != nil {
+			fail("ERROR creating directory:", err)
+		}
+
+		temporary, err := os.CreateTemp(filepath.Dir(file.path), "."+filepath.Base(file.path)+".*")
+		if err != nil {
+			fail("ERROR creating file:", err)
+		}
+		staged = append(staged, temporary.Name())
+
+		_, err = temporary.Write(file.content)
+		if err == nil {
+			err = temporary.Chmod(0644)
+		}
+		closeErr := temporary.Close()
+		if err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			fail("ERROR writing contents to file:", err)
+		}
+	}
+
+	for i, file := range files {
+		err := os.Rename(staged[i], file.path)
+		if err != nil {
+			fail("ERROR writing contents to file:", err)
+		}
+	}
+}
+
+// Every option accepted by parseArguments
+var CLI_OPTIONS = []string{"--json", "--indent", "--receiver-fallback", "--box-recursive", "--max-errors", "--diagnostics-format", "--rule", "--max-fields", "--check", "--diff", "--plugin", "--plugin-opt", "--template", "--lang", "--out", "--help"}
+
+// Returns a hint naming the closest of the candidates, or an empty string when none of them is close enough.
+func didYouMean(name string, candidates []string) string {
+	suggestion, found := closestName(name, candidates)
+	if !found {
+		return ""
+	}
+	return fmt.Sprintf(" Did you mean '%v'?", suggestion)
 }
 
-// Method parseArguments parses arguments starting from index 0, returns generator options.
+// Method parseArguments parses arguments starting from index 0, returns CLI options.
 // On error exits with code 1. If help is passed as argument it's displayed and the program exits.
-func parseArguments(args []string) GeneratorOptions {
-	options := defaultOptions()
+func parseArguments(args []string) CLIOptions {
+	return applyArguments(defaultCLIOptions(), args)
+}
+
+// Same as parseArguments, but the arguments override the given options instead of the defaults
+func applyArguments(cliOptions CLIOptions, args []string) CLIOptions {
+	options := &cliOptions.generator
 	for i := 0; i < len(args); i++ {
 		switch args[i] {
 		case "-h", "--help":
@@ -172,12 +541,151 @@ func parseArguments(args []string) GeneratorOptions {
 			}
 			options.receiverNameFallback = args[i+1]
 			i++
+		case "--check":
+			cliOptions.formatCheck = true
+		case "--diff":
+			cliOptions.formatDiff = true
+		case "--box-recursive":
+			cliOptions.boxRecursive = true
+		case "--max-errors":
+			if i+1 >= len(args) {
+				fmt.Println("ERROR: No argument passed for maximum number of errors")
+				os.Exit(1)
+			}
+			maxErrors, err := strconv.Atoi(args[i+1])
+			if err != nil || maxErrors < 0 {
+				fmt.Printf("ERROR: Invalid maximum number of errors: %v\n", args[i+1])
+				os.Exit(1)
+			}
+			cliOptions.maxErrors = maxErrors
+			i++
+		case "--rule":
+			if i+1 >= len(args) {
+				fmt.Println("ERROR: No argument passed for lint rule")
+				os.Exit(1)
+			}
+			parseLintRule(&cliOptions.lint, args[i+1])
+			i++
+		case "--max-fields":
+			if i+1 >= len(args) {
+				fmt.Println("ERROR: No argument passed for maximum number of fields")
+				os.Exit(1)
+			}
+			maxFields, err := strconv.Atoi(args[i+1])
+			if err != nil || maxFields < 0 {
+				fmt.Printf("ERROR: Invalid maximum number of fields: %v\n", args[i+1])
+				os.Exit(1)
+			}
+			cliOptions.lint.maxFields = maxFields
+			i++
+		case "--plugin":
+			if i+1 >= len(args) {
+				fmt.Println("ERROR: No argument passed for plugin")
+				os.Exit(1)
+			}
+			cliOptions.plugin = args[i+1]
+			i++
+		case "--plugin-opt":
+			if i+1 >= len(args) {
+				fmt.Println("ERROR: No argument passed for plugin option")
+				os.Exit(1)
+			}
+			key, value, _ := strings.Cut(args[i+1], "=")
+			cliOptions.pluginParameters[key] = value
+			i++
+		case "--out":
+			if i+1 >= len(args) {
+				fmt.Println("ERROR: No argument passed for output directory")
+				os.Exit(1)
+			}
+			cliOptions.out = args[i+1]
+			i++
+		case "--lang":
+			if i+1 >= len(args) {
+				fmt.Println("ERROR: No argument passed for language")
+				os.Exit(1)
+			}
+			cliOptions.languages = append(cliOptions.languages, args[i+1])
+			i++
+		case "--template":
+			if i+1 >= len(args) {
+				fmt.Println("ERROR: No argument passed for template directory")
+				os.Exit(1)
+			}
+			cliOptions.template = args[i+1]
+			i++
+		case "--diagnostics-format":
+			if i+1 >= len(args) {
+				fmt.Println("ERROR: No argument passed for diagnostics format")
+				os.Exit(1)
+			}
+			cliOptions.diagnosticsFormat = parseDiagnosticsFormat(args[i+1])
+			i++
 		default:
-			fmt.Println("WARN: Unknown option", args[i])
+			// Also accepted in the form of --diagnostics-format=json
+			option, value, hasValue := strings.Cut(args[i], "=")
+			if hasValue && option == "--diagnostics-format" {
+				cliOptions.diagnosticsFormat = parseDiagnosticsFormat(value)
+				continue
+			}
+			// Same for --plugin=tg-gen-swift and --template=templates/
+			if hasValue && option == "--plugin" {
+				cliOptions.plugin = value
+				continue
+			}
+			if hasValue && option == "--template" {
+				cliOptions.template = value
+				continue
+			}
+			if hasValue && option == "--out" {
+				cliOptions.out = value
+				continue
+			}
+			if hasValue && option == "--lang" {
+				cliOptions.languages = append(cliOptions.languages, value)
+				continue
+			}
+			// Options of a single language: --go.package=pets, --java.records
+			if language, name, found := strings.Cut(strings.TrimPrefix(option, "--"), "."); found && strings.HasPrefix(option, "--") {
+				cliOptions.languageOptions = append(cliOptions.languageOptions, languageOption{language: language, name: name, value: value, hasValue: hasValue})
+				continue
+			}
+
+			fmt.Printf("WARN: Unknown option %v.%v\n", args[i], didYouMean(option, CLI_OPTIONS))
 		}
 	}
 
-	return options
+	return cliOptions
+}
+
+// Parses a lint rule configuration in the form of 'name=severity', where severity is error, warning, note or off
+func parseLintRule(options *LintOptions, config string) {
+	name, severityString, _ := strings.Cut(config, "=")
+	if findLintRule(name) == nil {
+		fmt.Printf("ERROR: Unknown lint rule: %v.%v\n", name, didYouMean(name, lintRuleNames()))
+		os.Exit(1)
+	}
+
+	if severityString == "off" {
+		options.disabled.Add(name)
+		return
+	}
+
+	severity, valid := severityFromString(severityString)
+	if !valid {
+		fmt.Printf("ERROR: Invalid severity of lint rule %v: '%v'. Expected one of: error, warning, note, off\n", name, severityString)
+		os.Exit(1)
+	}
+	options.severities[name] = severity
+}
+
+func parseDiagnosticsFormat(format string) DiagnosticsFormat {
+	diagnosticsFormat, valid := diagnosticsFormatFromString(format)
+	if !valid {
+		fmt.Printf("ERROR: Invalid diagnostics format: %v. Expected one of: text, json, sarif.%v\n", format, didYouMean(format, []string{"text", "json", "sarif"}))
+		os.Exit(1)
+	}
+	return diagnosticsFormat
 }
 
 func printHelp() {
@@ -188,12 +696,258 @@ func printHelp() {
 		exec = ""
 	}
 	fmt.Println("Usage:")
-	fmt.Printf("  %v <file path/directory> <language> [options...]\n", exec)
+	fmt.Printf("  %v <file path/directory> <language>[,<language>...] [options...]\n", exec)
+	fmt.Printf("  %v <file path/directory> --plugin <executable> [options...]\n", exec)
+	fmt.Printf("  %v <file path/directory> --template <directory> [options...]\n", exec)
+	fmt.Printf("  %v lint <file path/directory> [options...]\n", exec)
+	fmt.Printf("  %v fmt <file path/directory> [options...]\n", exec)
+	fmt.Printf("  %v dump <tokens|ast|typed> <file path> [options...]\n", exec)
+	fmt.Printf("  %v lsp\n", exec)
+	fmt.Println("Languages:")
+	for _, generator := range allGenerators(defaultOptions()) {
+		identifiers := append([]string{generator.name()}, generator.aliases()...)
+		fmt.Printf("    %-29v %v files\n", strings.Join(identifiers, ", "), generator.extension())
+	}
 	fmt.Println("Options:")
 	fmt.Println("    --json                        Generate JSON-annotations")
 	fmt.Println("    --indent [number]             Code indentation level")
 	fmt.Println("    --receiver-fallback [string]  Receiver name fallback for GO and C")
+	fmt.Println("    --box-recursive               Box fields of types containing themselves, instead of reporting an error")
+	fmt.Println("    --max-errors [number]         Stop reporting syntax errors after this many, 0 for no limit (default 20)")
+	fmt.Println("    --diagnostics-format [format] Format of reported errors: text, json or sarif (default text)")
+	fmt.Println("    --plugin [executable]         Generate code with an external plugin instead of a built-in language")
+	fmt.Println("    --plugin-opt [key=value]      Parameter passed to the plugin, can be repeated")
+	fmt.Println("    --template [directory]        Generate code from text/template files (*.tmpl) in the directory")
+	fmt.Println("    --lang [language]             Generate the language, can be repeated or comma separated")
+	fmt.Println("    --out [directory]             Write generated files to the directory, laid out as usual for each language")
 	fmt.Println("    -h, --help                    Display this help message")
+	fmt.Println("Language options:")
+	fmt.Println("    --<language>.package=[name]   Package name of the generated code")
+	fmt.Println("    --<language>.indent=[number]  Code indentation level")
+	fmt.Println("    --<language>.receiver-fallback=[string] Receiver name fallback")
+	fmt.Println("    --<language>.json[=bool]      Generate JSON-annotations")
+	fmt.Println("    --java.records[=bool]         Declare types as records instead of classes")
+	fmt.Println("    --<language>.per-type[=bool]  Write every type to a file of its own, public in Java")
+	fmt.Println("    --<language>.type-case=[case] Case of type and flags names: pascal, camel or snake")
+	fmt.Println("    --<language>.field-case=[case] Case of field and parameter names: pascal, camel or snake")
+	fmt.Println("    --<language>.out=[directory]  Same as --out, for the language only")
+	fmt.Printf("Options are also read from %v in the directory of the path or any parent directory,\n", CONFIG_FILE)
+	fmt.Println("and are overridden by the ones passed on the command line.")
+	fmt.Println("Lint options:")
+	fmt.Println("    --rule [name=severity]        Set severity of a rule: error, warning, note or off")
+	fmt.Println("    --max-fields [number]         Maximum number of fields of a type (default 32)")
+	fmt.Println("Format options:")
+	fmt.Println("    --check                       List files which are not formatted and fail, instead of rewriting them")
+	fmt.Println("    --diff                        Print differences from the formatted files, instead of rewriting them")
+	fmt.Println("Lint rules:")
+	for _, rule := range LINT_RULES {
+		fmt.Printf("    %-29v %v (%v)\n", rule.name, rule.description, severityToString(rule.severity))
+	}
+}
+
+// Returns the schema file at the path, or every schema file within the directory at the path and its subdirectories.
+// Within a configured project, the directory is searched for the configured inputs instead. Exits when there are none.
+func collectSchemaFiles(path string, config *ProjectConfig) []string {
+	info, err := getPathInfo(path)
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err.Error())
+		os.Exit(1)
+	}
+
+	var files []string
+	if info.IsDir() && config != nil && len(config.inputs) > 0 {
+		files = config.inputFiles(path)
+	} else if info.IsDir() {
+		err := filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+			if err == nil && !entry.IsDir() && strings.HasSuffix(entry.Name(), EXTENSION) {
+				files = append(files, file)
+			}
+			return err
+		})
+		if err != nil {
+			fmt.Println("Failed to open directory.")
+			os.Exit(1)
+		}
+	} else {
+		if strings.HasSuffix(path, EXTENSION) {
+			files = append(files, path)
+		}
+	}
+
+	if len(files) == 0 {
+		fmt.Printf("Nothing to do. Ensure your files end with %v\n", EXTENSION)
+		os.Exit(1)
+	}
+	return files
+}
+
+// Runs the linter over every schema file at the path. Files which fail to parse or typecheck report those errors
+// instead. Exits with code 1 when any error was found.
+func executeLint(args []string) {
+	if len(args) < 1 {
+		printHelp()
+		return
+	}
+
+	cliOptions, config := loadOptions(args[0], args[1:])
+	files := collectSchemaFiles(args[0], config)
+	format := cliOptions.diagnosticsFormat
+
+	diagnostics := make([]Diagnostic, 0)
+	renderer := DiagnosticRenderer{
+		sources: make(map[string][]byte, len(files)),
+		colors:  shouldUseColors(os.Stdout),
+	}
+	for _, file := range files {
+		parser, success := CreateParser(file)
+		if !success {
+			os.Exit(1)
+		}
+		renderer.sources[file] = parser.lexer.data
+
+		parser.maxErrors = cliOptions.maxErrors
+		parser.boxRecursiveTypes = cliOptions.boxRecursive
+		result := ParseFile(&parser)
+		if result.success {
+			result = TypecheckFile(&parser)
+		}
+		if !result.success {
+			diagnostics = append(diagnostics, parser.diagnostics...)
+			continue
+		}
+
+		linter := newLinter(&parser, cliOptions.lint)
+		diagnostics = append(diagnostics, linter.run()...)
+	}
+
+	counts := make(map[Severity]int)
+	for _, diagnostic := range diagnostics {
+		counts[diagnostic.severity]++
+	}
+
+	if format == FORMAT_TEXT {
+		for _, diagnostic := range diagnostics {
+			fmt.Println(renderer.render(diagnostic))
+		}
+		fmt.Printf("Found %v errors, %v warnings and %v notes in %v files.\n", counts[SEVERITY_ERROR], counts[SEVERITY_WARNING], counts[SEVERITY_NOTE], len(files))
+	} else {
+		writeDiagnostics(os.Stdout, diagnostics, format)
+	}
+
+	if counts[SEVERITY_ERROR] > 0 {
+		os.Exit(1)
+	}
+}
+
+// Writes the tokens or declarations of a single file as JSON to the standard output
+func executeDump(args []string) {
+	if len(args) < 2 {
+		printHelp()
+		return
+	}
+
+	kind := args[0]
+	if !slices.Contains(DUMP_KINDS, kind) {
+		fmt.Printf("ERROR: Unrecognized dump kind: %v, expected one of: %v.%v\n", kind, strings.Join(DUMP_KINDS, ", "), didYouMean(kind, DUMP_KINDS))
+		os.Exit(1)
+	}
+
+	parser, success := CreateParser(args[1])
+	if !success {
+		os.Exit(1)
+	}
+	cliOptions, _ := loadOptions(args[1], args[2:])
+	parser.maxErrors = cliOptions.maxErrors
+	parser.boxRecursiveTypes = cliOptions.boxRecursive
+
+	var dump any
+	if kind == DUMP_TOKENS {
+		dump = dumpTokens(parser.filepath, parser.lexer.data)
+	} else {
+		result := ParseFile(&parser)
+		if result.success && kind == DUMP_TYPED {
+			result = TypecheckFile(&parser)
+		}
+		if !result.success {
+			exitWithDiagnostics(parser.diagnostics, &parser, parser.errorLimitNote(), cliOptions.diagnosticsFormat)
+		}
+		dump = dumpDeclarations(&parser, kind)
+	}
+
+	err := writeDump(os.Stdout, dump)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR writing dump:", err)
+		os.Exit(1)
+	}
+}
+
+// Formats every schema file at the path in place, or only reports the files which aren't formatted
+// when checking or printing differences. Exits with code 1 when a file fails to parse, or isn't formatted
+// in the check mode.
+func executeFormat(args []string) {
+	if len(args) < 1 {
+		printHelp()
+		return
+	}
+
+	cliOptions, config := loadOptions(args[0], args[1:])
+	files := collectSchemaFiles(args[0], config)
+
+	failed := false
+	for _, file := range files {
+		parser, success := CreateParser(file)
+		if !success {
+			os.Exit(1)
+		}
+
+		parser.maxErrors = cliOptions.maxErrors
+		result := ParseFile(&parser)
+		if !result.success {
+			renderer := DiagnosticRenderer{
+				sources: map[string][]byte{file: parser.lexer.data},
+				colors:  shouldUseColors(os.Stdout),
+			}
+			for _, diagnostic := range parser.diagnostics {
+				fmt.Println(renderer.render(diagnostic))
+			}
+			failed = true
+			continue
+		}
+
+		source := string(parser.lexer.data)
+		formatted := formatSchema(&parser, cliOptions.generator.indent)
+		if formatted == source {
+			continue
+		}
+
+		if cliOptions.formatDiff {
+			fmt.Print(unifiedDiff(file, source, formatted))
+		}
+
+		if cliOptions.formatCheck {
+			if !cliOptions.formatDiff {
+				fmt.Println(file)
+			}
+			failed = true
+		}
+
+		if cliOptions.formatCheck || cliOptions.formatDiff {
+			continue
+		}
+
+		info, err := os.Stat(file)
+		if err == nil {
+			err = os.WriteFile(file, []byte(formatted), info.Mode().Perm())
+		}
+		if err != nil {
+			fmt.Printf("ERROR: Failed to write formatted file %v: %v\n", file, err)
+			failed = true
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
 }
 
 func changeExtension(file string, newExtension string) string {
@@ -234,6 +988,20 @@ const (
 	PYTHON
 )
 
+// Every identifier accepted by languageIdentifierToLanguage, used to suggest a fix for misspelled languages
+var LANGUAGE_IDENTIFIERS = []string{
+	"go", "golang",
+	"js", "javascript",
+	"ts", "typescript",
+	"java",
+	"kt", "kotlin",
+	"rs", "rust",
+	"cs", "csharp",
+	"c",
+	"cpp", "c++", "cxx", "cc",
+	"py", "python",
+}
+
 func languageIdentifierToLanguage(lang string) Language {
 	switch strings.ToLower(lang) {
 	case "go", "golang":
@@ -260,29 +1028,30 @@ func languageIdentifierToLanguage(lang string) Language {
 	return NONE
 }
 
-func languageToExtension(language Language) string {
+// Canonical identifier of a language, as accepted by languageIdentifierToLanguage.
+func languageToName(language Language) string {
 	switch language {
 	case GO:
-		return ".go"
+		return "go"
 	case JAVASCRIPT:
-		return ".js"
+		return "js"
 	case TYPESCRIPT:
-		return ".ts"
+		return "ts"
 	case JAVA:
-		return ".java"
+		return "java"
 	case KOTLIN:
-		return ".kt"
+		return "kotlin"
 	case RUST:
-		return ".rs"
+		return "rust"
 	case CSHARP:
-		return ".cs"
+		return "csharp"
 	case C:
-		return ".c"
+		return "c"
 	case CPP:
-		return ".cpp"
+		return "cpp"
 	case PYTHON:
-		return ".py"
+		return "python"
 	default:
-		panic("language has an unmapped extension?")
+		return "none"
 	}
 }
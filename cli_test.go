@@ -1,6 +1,11 @@
 package main
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
 
 func TestChangeExtension(t *testing.T) {
 	actual := changeExtension("example.txt", ".go")
@@ -25,3 +30,94 @@ func TestChangeExtensionTwoDots(t *testing.T) {
 		t.Errorf("Expected %v, got %v", expected, actual)
 	}
 }
+
+func TestLanguageOptions(t *testing.T) {
+	args := []string{"--json", "--lang", "rust,kt", "--lang=go", "--go.package=pets", "--rust.indent=2", "--rust.out=gen/rs", "--kotlin.json=false", "--kotlin.type-case=snake"}
+	cliOptions := parseArguments(args)
+	targets := resolveTargets(append([]string{"golang"}, cliOptions.languages...), cliOptions)
+
+	names := make([]string, len(targets))
+	for i, target := range targets {
+		names[i] = target.generator.name()
+	}
+	if !slices.Equal(names, []string{"go", "rust", "kotlin"}) {
+		t.Fatalf("Expected targets go, rust and kotlin, found %v", names)
+	}
+
+	goTarget, rustTarget, kotlinTarget := targets[0], targets[1], targets[2]
+	if goTarget.options.packageName != "pets" || !goTarget.options.jsonAnnotations || goTarget.options.indent != 4 {
+		t.Errorf("Unexpected options of go: %+v", goTarget.options)
+	}
+	if rustTarget.options.packageName != "" || rustTarget.options.indent != 2 || rustTarget.outDir != "gen/rs" {
+		t.Errorf("Unexpected options of rust: %+v, output directory '%v'", rustTarget.options, rustTarget.outDir)
+	}
+	if kotlinTarget.options.jsonAnnotations || kotlinTarget.outDir != "" || kotlinTarget.options.typeCase != CASE_SNAKE {
+		t.Errorf("Unexpected options of kotlin: %+v", kotlinTarget.options)
+	}
+}
+
+func TestWriteGeneratedFiles(t *testing.T) {
+	directory := t.TempDir()
+	existing := filepath.Join(directory, "cat.go")
+	os.WriteFile(existing, []byte("package old\n"), 0644)
+
+	writeGeneratedFiles([]GeneratedFile{
+		{path: existing, content: []byte("package main\n")},
+		{path: filepath.Join(directory, "gen", "cat.rs"), content: []byte("struct Cat {}\n")},
+	})
+
+	entries, _ := os.ReadDir(directory)
+	if len(entries) != 2 {
+		t.Errorf("Expected no temporary files to remain, found %v", entries)
+	}
+	for path, expected := range map[string]string{existing: "package main\n", filepath.Join(directory, "gen", "cat.rs"): "struct Cat {}\n"} {
+		content, err := os.ReadFile(path)
+		if err != nil || string(content) != expected {
+			t.Errorf("Expected '%v' to contain %q, found %q (%v)", path, expected, content, err)
+		}
+	}
+}
+
+func TestOutputPath(t *testing.T) {
+	java := &JavaGenerator{defaultOptions()}
+	tests := []struct {
+		path      string
+		outDir    string
+		inputDir  string
+		generator Generator
+		expected  string
+	}{
+		{"schemas/shop/cat.java", "", "schemas", java, "schemas/shop/cat.java"},
+		{"schemas/shop/cat.java", "gen", "schemas", java, "gen/src/main/java/shop/cat.java"},
+		{"schemas/shop/cat.md", "docs", "schemas", nil, "docs/shop/cat.md"},
+		{"cat.md", "docs", ".", nil, "docs/cat.md"},
+	}
+
+	for _, test := range tests {
+		var layout func(string) string
+		if test.generator != nil {
+			layout = test.generator.layoutPath
+		}
+		actual := outputPath(filepath.FromSlash(test.path), test.outDir, test.inputDir, layout)
+		if actual != filepath.FromSlash(test.expected) {
+			t.Errorf("Expected '%v' to be written to '%v', found '%v'", test.path, test.expected, actual)
+		}
+	}
+
+	// Schema files are collected from every level of the input directory, which is mirrored in the output directory
+	root := t.TempDir()
+	nested := filepath.Join(root, "shop", "pets")
+	os.MkdirAll(nested, 0755)
+	os.WriteFile(filepath.Join(root, "order.tg"), []byte("type Order {}\n"), 0644)
+	os.WriteFile(filepath.Join(nested, "cat.tg"), []byte("type Cat {}\n"), 0644)
+
+	files := collectSchemaFiles(root, nil)
+	if !slices.Equal(files, []string{filepath.Join(root, "order.tg"), filepath.Join(nested, "cat.tg")}) {
+		t.Fatalf("Expected schema files of nested directories to be collected, found %v", files)
+	}
+
+	expected := filepath.Join("gen", "src", "main", "java", "shop", "pets", "cat.java")
+	if actual := outputPath(changeExtension(files[1], ".java"), "gen", root, java.layoutPath); actual != expected {
+		t.Errorf("Expected the nested schema to be written to '%v', found '%v'", expected, actual)
+	}
+}
@@ -0,0 +1,601 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Projects are configured with a pocketgen.json file, which applies to every schema file in its directory and
+// the subdirectories. Options passed on the command line take precedence over the configured ones, and options
+// of a single language take precedence over the global ones.
+//
+//	{
+//	    "inputs": ["schemas/*.tg"],
+//	    "json": true,
+//	    "targets": {
+//	        "go": {"package": "pets", "out": "gen/go"},
+//	        "java": {"records": true}
+//	    },
+//	    "lint": {"rules": {"unused-type": "off"}, "max-fields": 40}
+//	}
+const CONFIG_FILE = "pocketgen.json"
+
+// ProjectConfig holds the parts of the configuration file not expressed as CLIOptions
+type ProjectConfig struct {
+	// Glob patterns of schema files, joined with the directory of the configuration file
+	inputs []string
+	// Languages generated when none are passed on the command line, in the declared order
+	targets []string
+}
+
+// Options accepted at the top level of the configuration file, besides inputs, targets and lint
+var CONFIG_OPTIONS = []string{"inputs", "targets", "lint", "out", "indent", "receiver-fallback", "json", "box-recursive", "max-errors", "diagnostics-format"}
+
+var CONFIG_LINT_OPTIONS = []string{"rules", "max-fields"}
+
+type configKind = uint8
+
+const (
+	CONFIG_OBJECT configKind = iota
+	CONFIG_ARRAY
+	CONFIG_STRING
+	CONFIG_NUMBER
+	CONFIG_BOOL
+	CONFIG_NULL
+)
+
+// configNode is a JSON value of the configuration file, together with its position
+type configNode struct {
+	kind configKind
+	pos  LinePos
+	// Members of an object, in the order they are declared
+	members []configMember
+	items   []configNode
+	// Value of a scalar: string, json.Number, bool or nil
+	value any
+}
+
+type configMember struct {
+	name    string
+	namePos LinePos
+	value   configNode
+}
+
+func configKindToString(kind configKind) string {
+	switch kind {
+	case CONFIG_OBJECT:
+		return "an object"
+	case CONFIG_ARRAY:
+		return "an array"
+	case CONFIG_STRING:
+		return "a string"
+	case CONFIG_NUMBER:
+		return "a number"
+	case CONFIG_BOOL:
+		return "a boolean"
+	default:
+		return "null"
+	}
+}
+
+// configReader builds configNodes from the tokens of the decoder, tracking their positions in the data
+type configReader struct {
+	path    string
+	data    []byte
+	decoder *json.Decoder
+}
+
+// Returns the position of the byte offset, columns count characters
+func (reader *configReader) position(offset int64) LinePos {
+	offset = min(offset, int64(len(reader.data)))
+	preceding := reader.data[:offset]
+	lineStart := bytes.LastIndexByte(preceding, '\n') + 1
+	return LinePos{
+		number: bytes.Count(preceding, []byte{'\n'}) + 1,
+		offset: utf8.RuneCount(preceding[lineStart:]) + 1,
+	}
+}
+
+// Reads the next token, returning the position of its first character
+func (reader *configReader) token() (json.Token, LinePos, error) {
+	// The decoder is positioned after the previous token, followed by whitespace and separators
+	offset := reader.decoder.InputOffset()
+	for offset < int64(len(reader.data)) && strings.IndexByte(" \t\r\n,:", reader.data[offset]) >= 0 {
+		offset++
+	}
+
+	token, err := reader.decoder.Token()
+	if err != nil {
+		var syntaxError *json.SyntaxError
+		if errors.As(err, &syntaxError) {
+			offset = max(syntaxError.Offset-1, 0)
+		}
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		message := strings.TrimPrefix(err.Error(), "json: ")
+		return nil, reader.position(offset), newDiagnostic(CODE_CONFIG_SYNTAX, reader.path, reader.position(offset), "Invalid JSON: "+message+".")
+	}
+	return token, reader.position(offset), nil
+}
+
+func (reader *configReader) read() (configNode, error) {
+	token, pos, err := reader.token()
+	if err != nil {
+		return configNode{}, err
+	}
+
+	switch value := token.(type) {
+	case json.Delim:
+		if value == '[' {
+			node := configNode{kind: CONFIG_ARRAY, pos: pos}
+			for reader.decoder.More() {
+				item, err := reader.read()
+				if err != nil {
+					return node, err
+				}
+				node.items = append(node.items, item)
+			}
+			_, _, err = reader.token()
+			return node, err
+		}
+
+		node := configNode{kind: CONFIG_OBJECT, pos: pos}
+		for reader.decoder.More() {
+			token, namePos, err := reader.token()
+			if err != nil {
+				return node, err
+			}
+			name := token.(string)
+			for _, member := range node.members {
+				if member.name == name {
+					message := fmt.Sprintf("Option '%s' is declared more than once.", name)
+					return node, newNameDiagnostic(CODE_CONFIG_SYNTAX, reader.path, namePos, `"`+name+`"`, message)
+				}
+			}
+
+			value, err := reader.read()
+			if err != nil {
+				return node, err
+			}
+			node.members = append(node.members, configMember{name: name, namePos: namePos, value: value})
+		}
+		_, _, err = reader.token()
+		return node, err
+	case string:
+		return configNode{kind: CONFIG_STRING, pos: pos, value: value}, nil
+	case json.Number:
+		return configNode{kind: CONFIG_NUMBER, pos: pos, value: value}, nil
+	case bool:
+		return configNode{kind: CONFIG_BOOL, pos: pos, value: value}, nil
+	}
+	return configNode{kind: CONFIG_NULL, pos: pos}, nil
+}
+
+// Parses the configuration file into nodes. Fails with a positioned diagnostic on invalid JSON.
+func parseConfigNodes(path string, data []byte) (configNode, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	reader := configReader{path: path, data: data, decoder: decoder}
+
+	root, err := reader.read()
+	if err != nil {
+		return root, err
+	}
+	if root.kind != CONFIG_OBJECT {
+		return root, newDiagnostic(CODE_CONFIG_INVALID_VALUE, path, root.pos, "Expected the configuration to be an object, found "+configKindToString(root.kind)+".")
+	}
+
+	remaining := data[decoder.InputOffset():]
+	if trimmed := bytes.TrimLeft(remaining, " \t\r\n"); len(trimmed) > 0 {
+		pos := reader.position(int64(len(data) - len(trimmed)))
+		return root, newDiagnostic(CODE_CONFIG_SYNTAX, path, pos, "Invalid JSON: unexpected data after the configuration object.")
+	}
+	return root, nil
+}
+
+// configValidator converts nodes into options, collecting a diagnostic for every invalid one
+type configValidator struct {
+	path        string
+	diagnostics []Diagnostic
+}
+
+func (validator *configValidator) report(code DiagnosticCode, pos LinePos, message string) {
+	validator.diagnostics = append(validator.diagnostics, newDiagnostic(code, validator.path, pos, message))
+}
+
+func (validator *configValidator) expect(node configNode, kind configKind, name string) bool {
+	if node.kind != kind {
+		message := fmt.Sprintf("Expected '%s' to be %s, found %s.", name, configKindToString(kind), configKindToString(node.kind))
+		validator.report(CODE_CONFIG_INVALID_VALUE, node.pos, message)
+		return false
+	}
+	return true
+}
+
+func (validator *configValidator) unknownOption(member configMember, options []string, within string) {
+	message := fmt.Sprintf("Unknown option '%s'%s.%s", member.name, within, didYouMean(member.name, options))
+	diagnostic := newNameDiagnostic(CODE_CONFIG_UNKNOWN_OPTION, validator.path, member.namePos, `"`+member.name+`"`, message)
+	validator.diagnostics = append(validator.diagnostics, diagnostic)
+}
+
+// Returns the string value, false (after reporting it) when the node is not a non-empty string
+func (validator *configValidator) string(node configNode, name string) (string, bool) {
+	if !validator.expect(node, CONFIG_STRING, name) {
+		return "", false
+	}
+	value := node.value.(string)
+	if value == "" {
+		validator.report(CODE_CONFIG_INVALID_VALUE, node.pos, fmt.Sprintf("Expected '%s' not to be empty.", name))
+		return "", false
+	}
+	return value, true
+}
+
+// Returns the integer value, false (after reporting it) when the node is not an integer of at least the minimum
+func (validator *configValidator) integer(node configNode, name string, minimum int) (int, bool) {
+	if !validator.expect(node, CONFIG_NUMBER, name) {
+		return 0, false
+	}
+	value, err := node.value.(json.Number).Int64()
+	if err != nil || value < int64(minimum) {
+		validator.report(CODE_CONFIG_INVALID_VALUE, node.pos, fmt.Sprintf("Expected '%s' to be an integer of at least %v, found %v.", name, minimum, node.value))
+		return 0, false
+	}
+	return int(value), true
+}
+
+func (validator *configValidator) bool(node configNode, name string) (bool, bool) {
+	if !validator.expect(node, CONFIG_BOOL, name) {
+		return false, false
+	}
+	return node.value.(bool), true
+}
+
+// Applies the configuration to the options, returning the parts which are not options
+func (validator *configValidator) apply(root configNode, cliOptions *CLIOptions) ProjectConfig {
+	directory := filepath.Dir(validator.path)
+	config := ProjectConfig{}
+	options := &cliOptions.generator
+
+	for _, member := range root.members {
+		value := member.value
+		switch member.name {
+		case "inputs":
+			if !validator.expect(value, CONFIG_ARRAY, member.name) {
+				continue
+			}
+			for _, item := range value.items {
+				pattern, valid := validator.string(item, "inputs")
+				if !valid {
+					continue
+				}
+				if _, err := filepath.Match(pattern, ""); err != nil {
+					validator.report(CODE_CONFIG_INVALID_VALUE, item.pos, fmt.Sprintf("Invalid glob pattern '%s'.", pattern))
+					continue
+				}
+				config.inputs = append(config.inputs, filepath.Join(directory, pattern))
+			}
+		case "targets":
+			if validator.expect(value, CONFIG_OBJECT, member.name) {
+				validator.applyTargets(value, &config, cliOptions)
+			}
+		case "lint":
+			if validator.expect(value, CONFIG_OBJECT, member.name) {
+				validator.applyLint(value, &cliOptions.lint)
+			}
+		case "out":
+			if out, valid := validator.string(value, member.name); valid {
+				cliOptions.out = filepath.Join(directory, out)
+			}
+		case "indent":
+			if indent, valid := validator.integer(value, member.name, 1); valid {
+				options.indent = indent
+			}
+		case "receiver-fallback":
+			if receiver, valid := validator.string(value, member.name); valid {
+				options.receiverNameFallback = receiver
+			}
+		case "json":
+			if enabled, valid := validator.bool(value, member.name); valid {
+				options.jsonAnnotations = enabled
+			}
+		case "box-recursive":
+			if box, valid := validator.bool(value, member.name); valid {
+				cliOptions.boxRecursive = box
+			}
+		case "max-errors":
+			if maxErrors, valid := validator.integer(value, member.name, 0); valid {
+				cliOptions.maxErrors = maxErrors
+			}
+		case "diagnostics-format":
+			format, valid := validator.string(value, member.name)
+			if !valid {
+				continue
+			}
+			formats := []string{"text", "json", "sarif"}
+			index := slices.Index(formats, format)
+			if index < 0 {
+				message := fmt.Sprintf("Invalid diagnostics format '%s', expected one of: text, json, sarif.%s", format, didYouMean(format, formats))
+				validator.report(CODE_CONFIG_INVALID_VALUE, value.pos, message)
+				continue
+			}
+			cliOptions.diagnosticsFormat = parseDiagnosticsFormat(format)
+		default:
+			validator.unknownOption(member, CONFIG_OPTIONS, "")
+		}
+	}
+
+	return config
+}
+
+// Targets are keyed by language, their options are applied as if passed with --<language>.<option>
+// before the command line ones
+func (validator *configValidator) applyTargets(targets configNode, config *ProjectConfig, cliOptions *CLIOptions) {
+	directory := filepath.Dir(validator.path)
+	configured := make([]languageOption, 0)
+	for _, target := range targets.members {
+		generator := findGenerator(target.name, defaultOptions())
+		if generator == nil {
+			message := fmt.Sprintf("There is no generator for language '%s'.%s", target.name, didYouMean(target.name, generatorIdentifiers()))
+			diagnostic := newNameDiagnostic(CODE_CONFIG_UNKNOWN_OPTION, validator.path, target.namePos, `"`+target.name+`"`, message)
+			validator.diagnostics = append(validator.diagnostics, diagnostic)
+			continue
+		}
+		config.targets = append(config.targets, target.name)
+
+		if !validator.expect(target.value, CONFIG_OBJECT, target.name) {
+			continue
+		}
+		for _, member := range target.value.members {
+			name := target.name + "." + member.name
+			option := languageOption{language: target.name, name: member.name, hasValue: true, configured: true}
+			switch member.name {
+			case "package", "receiver-fallback", "out":
+				value, valid := validator.string(member.value, name)
+				if !valid {
+					continue
+				}
+				if member.name == "out" {
+					value = filepath.Join(directory, value)
+				}
+				option.value = value
+			case "indent":
+				indent, valid := validator.integer(member.value, name, 1)
+				if !valid {
+					continue
+				}
+				option.value = fmt.Sprint(indent)
+			case "type-case", "field-case":
+				nameCase, valid := validator.string(member.value, name)
+				if !valid {
+					continue
+				}
+				if !slices.Contains(NAME_CASES, nameCase) {
+					message := fmt.Sprintf("Invalid case of %s: '%s', expected one of: %s.%s", name, nameCase, strings.Join(NAME_CASES, ", "), didYouMean(nameCase, NAME_CASES))
+					validator.report(CODE_CONFIG_INVALID_VALUE, member.value.pos, message)
+					continue
+				}
+				option.value = nameCase
+			case "json", "records", "per-type":
+				enabled, valid := validator.bool(member.value, name)
+				if !valid {
+					continue
+				}
+				option.value = fmt.Sprint(enabled)
+			default:
+				validator.unknownOption(member, LANGUAGE_OPTIONS, " of a target")
+				continue
+			}
+			configured = append(configured, option)
+		}
+	}
+
+	cliOptions.languageOptions = append(configured, cliOptions.languageOptions...)
+}
+
+func (validator *configValidator) applyLint(lint configNode, options *LintOptions) {
+	for _, member := range lint.members {
+		switch member.name {
+		case "rules":
+			if !validator.expect(member.value, CONFIG_OBJECT, "lint.rules") {
+				continue
+			}
+			for _, rule := range member.value.members {
+				if findLintRule(rule.name) == nil {
+					validator.unknownOption(rule, lintRuleNames(), " of lint rules")
+					continue
+				}
+
+				severityString, valid := validator.string(rule.value, rule.name)
+				if !valid {
+					continue
+				}
+				if severityString == "off" {
+					options.disabled.Add(rule.name)
+					continue
+				}
+				severity, valid := severityFromString(severityString)
+				if !valid {
+					message := fmt.Sprintf("Invalid severity of lint rule %s: '%s'. Expected one of: error, warning, note, off.", rule.name, severityString)
+					validator.report(CODE_CONFIG_INVALID_VALUE, rule.value.pos, message)
+					continue
+				}
+				options.severities[rule.name] = severity
+			}
+		case "max-fields":
+			if maxFields, valid := validator.integer(member.value, "lint.max-fields", 0); valid {
+				options.maxFields = maxFields
+			}
+		default:
+			validator.unknownOption(member, CONFIG_LINT_OPTIONS, " of lint")
+		}
+	}
+}
+
+// Reads the configuration file and applies it to the options. Diagnostics are returned for invalid options.
+func loadConfig(path string, data []byte, cliOptions *CLIOptions) (ProjectConfig, []Diagnostic) {
+	root, err := parseConfigNodes(path, data)
+	if err != nil {
+		var diagnostic Diagnostic
+		errors.As(err, &diagnostic)
+		return ProjectConfig{}, []Diagnostic{diagnostic}
+	}
+
+	validator := configValidator{path: path}
+	config := validator.apply(root, cliOptions)
+	return config, validator.diagnostics
+}
+
+// Returns the path of the configuration file closest to the path, searching its directory and the parent
+// directories. Returns an empty string when there is none.
+func findConfigFile(path string) string {
+	directory, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+	if info, err := os.Stat(directory); err == nil && !info.IsDir() {
+		directory = filepath.Dir(directory)
+	}
+
+	for {
+		candidate := filepath.Join(directory, CONFIG_FILE)
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			// Keep paths relative when the input path is relative, so that they read the same in messages
+			if workingDirectory, err := os.Getwd(); err == nil && !filepath.IsAbs(path) {
+				if relative, err := filepath.Rel(workingDirectory, candidate); err == nil {
+					return relative
+				}
+			}
+			return candidate
+		}
+
+		parent := filepath.Dir(directory)
+		if parent == directory {
+			return ""
+		}
+		directory = parent
+	}
+}
+
+// Returns the options of the project the path belongs to, overridden by the command line arguments.
+// The configuration is nil when there is no configuration file. Exits when the configuration file is invalid.
+func loadOptions(path string, args []string) (CLIOptions, *ProjectConfig) {
+	cliOptions := defaultCLIOptions()
+	configPath := findConfigFile(path)
+	if configPath == "" {
+		return applyArguments(cliOptions, args), nil
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		fmt.Printf("ERROR: Failed to read configuration file %v: %v\n", configPath, err)
+		os.Exit(1)
+	}
+
+	config, diagnostics := loadConfig(configPath, data, &cliOptions)
+	cliOptions = applyArguments(cliOptions, args)
+	if len(diagnostics) > 0 {
+		if cliOptions.diagnosticsFormat == FORMAT_TEXT {
+			renderer := DiagnosticRenderer{
+				sources: map[string][]byte{configPath: data},
+				colors:  shouldUseColors(os.Stdout),
+			}
+			for _, diagnostic := range diagnostics {
+				fmt.Println(renderer.render(diagnostic))
+			}
+		} else {
+			writeDiagnostics(os.Stdout, diagnostics, cliOptions.diagnosticsFormat)
+		}
+		os.Exit(1)
+	}
+	return cliOptions, &config
+}
+
+// Returns schema files matching the inputs of the configuration which are within the directory
+func (config *ProjectConfig) inputFiles(directory string) []string {
+	files := make([]string, 0)
+	for _, pattern := range config.inputs {
+		for _, match := range globFiles(pattern) {
+			relative, err := relativePath(directory, match)
+			if err != nil || !filepath.IsLocal(relative) || !strings.HasSuffix(match, EXTENSION) || slices.Contains(files, match) {
+				continue
+			}
+			files = append(files, match)
+		}
+	}
+	return files
+}
+
+// Same as filepath.Glob, but '**' as a whole path element matches any number of directories.
+// Patterns were validated when the configuration was loaded, so errors are not expected.
+func globFiles(pattern string) []string {
+	elements := strings.Split(filepath.ToSlash(pattern), "/")
+	recursive := slices.Index(elements, "**")
+	if recursive < 0 {
+		matches, _ := filepath.Glob(pattern)
+		return matches
+	}
+
+	// Directories matching the elements before '**' are searched for files matching the whole pattern
+	root := "."
+	if recursive > 0 {
+		root = filepath.FromSlash(strings.Join(elements[:recursive], "/"))
+	}
+	roots, _ := filepath.Glob(root)
+
+	matches := make([]string, 0)
+	for _, root := range roots {
+		filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err == nil && !entry.IsDir() && matchElements(elements, strings.Split(filepath.ToSlash(path), "/")) {
+				matches = append(matches, path)
+			}
+			return nil
+		})
+	}
+	return matches
+}
+
+// Matches path elements against pattern elements with filepath.Match, where '**' matches any number of elements
+func matchElements(pattern []string, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+
+	if pattern[0] == "**" {
+		for i := 0; i <= len(path); i++ {
+			if matchElements(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(path) == 0 {
+		return false
+	}
+	matched, _ := filepath.Match(pattern[0], path[0])
+	return matched && matchElements(pattern[1:], path[1:])
+}
+
+// Same as filepath.Rel, but either of the paths may be relative to the working directory
+func relativePath(base string, target string) (string, error) {
+	base, err := filepath.Abs(base)
+	if err != nil {
+		return "", err
+	}
+	target, err = filepath.Abs(target)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Rel(base, target)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestConfigPrecedence(t *testing.T) {
+	source := `{
+    "indent": 2,
+    "targets": {
+        "go": {"package": "pets", "out": "gen/go", "json": true},
+        "kt": {"indent": 8, "field-case": "snake"}
+    },
+    "lint": {"rules": {"unused-type": "off", "field-case": "error"}, "max-fields": 40}
+}`
+	cliOptions := defaultCLIOptions()
+	config, diagnostics := loadConfig("project/pocketgen.json", []byte(source), &cliOptions)
+	if len(diagnostics) != 0 {
+		t.Fatalf("Unexpected diagnostics: %v", diagnostics)
+	}
+	if !slices.Equal(config.targets, []string{"go", "kt"}) {
+		t.Errorf("Expected targets go and kt, found %v", config.targets)
+	}
+	if !cliOptions.lint.disabled.Contains("unused-type") || cliOptions.lint.severities["field-case"] != SEVERITY_ERROR || cliOptions.lint.maxFields != 40 {
+		t.Errorf("Unexpected lint options: %+v", cliOptions.lint)
+	}
+
+	// Command line options override the configured ones of the same scope
+	cliOptions = applyArguments(cliOptions, []string{"--indent", "4", "--go.package=cats"})
+	targets := resolveTargets(config.targets, cliOptions)
+
+	goTarget, kotlinTarget := targets[0], targets[1]
+	if goTarget.options.packageName != "cats" || goTarget.options.indent != 4 || !goTarget.options.jsonAnnotations {
+		t.Errorf("Unexpected options of go: %+v", goTarget.options)
+	}
+	if goTarget.outDir != filepath.Join("project", "gen", "go") {
+		t.Errorf("Expected the output directory relative to the configuration file, found '%v'", goTarget.outDir)
+	}
+	if kotlinTarget.options.indent != 8 {
+		t.Errorf("Expected the configured indentation of kotlin to override the global one, found %v", kotlinTarget.options.indent)
+	}
+	if kotlinTarget.options.fieldCase != CASE_SNAKE || goTarget.options.fieldCase != "" {
+		t.Errorf("Expected snake case fields only in kotlin, found '%v' and '%v'", kotlinTarget.options.fieldCase, goTarget.options.fieldCase)
+	}
+}
+
+func TestConfigDiagnostics(t *testing.T) {
+	type expectedDiagnostic struct {
+		code   DiagnosticCode
+		line   int
+		column int
+	}
+
+	tests := []struct {
+		source   string
+		expected []expectedDiagnostic
+	}{
+		{"{\n  \"indnt\": 2,\n  \"json\": \"yes\"\n}", []expectedDiagnostic{{CODE_CONFIG_UNKNOWN_OPTION, 2, 3}, {CODE_CONFIG_INVALID_VALUE, 3, 11}}},
+		{"{\n  \"targets\": {\"swift\": {}, \"go\": {\"indent\": 0}}\n}", []expectedDiagnostic{{CODE_CONFIG_UNKNOWN_OPTION, 2, 15}, {CODE_CONFIG_INVALID_VALUE, 2, 45}}},
+		{"{\n  \"lint\": {\"rules\": {\"field-case\": \"fatal\", \"no-such-rule\": \"off\"}}\n}", []expectedDiagnostic{{CODE_CONFIG_INVALID_VALUE, 2, 36}, {CODE_CONFIG_UNKNOWN_OPTION, 2, 45}}},
+		{"{\n  \"json\": tru\n}", []expectedDiagnostic{{CODE_CONFIG_SYNTAX, 2, 14}}},
+		{"{\n  \"indent\": 2,\n  \"indent\": 3\n}", []expectedDiagnostic{{CODE_CONFIG_SYNTAX, 3, 3}}},
+		{"{\"inputs\": [\"*.tg\"]} {}", []expectedDiagnostic{{CODE_CONFIG_SYNTAX, 1, 22}}},
+		{"[]", []expectedDiagnostic{{CODE_CONFIG_INVALID_VALUE, 1, 1}}},
+		{"{\n  \"targets\": {\"go\": {\"type-case\": \"kebab\"}}\n}", []expectedDiagnostic{{CODE_CONFIG_INVALID_VALUE, 2, 35}}},
+	}
+
+	for _, test := range tests {
+		cliOptions := defaultCLIOptions()
+		_, diagnostics := loadConfig("pocketgen.json", []byte(test.source), &cliOptions)
+		if len(diagnostics) != len(test.expected) {
+			t.Errorf("Expected %v diagnostics for %q, found %v", len(test.expected), test.source, diagnostics)
+			continue
+		}
+
+		for i, expected := range test.expected {
+			diagnostic := diagnostics[i]
+			if diagnostic.code != expected.code || diagnostic.start != (LinePos{number: expected.line, offset: expected.column}) {
+				t.Errorf("Expected %v at %v:%v for %q, found %v", expected.code, expected.line, expected.column, test.source, diagnostic.Error())
+			}
+		}
+	}
+}
+
+func TestFindConfigFile(t *testing.T) {
+	root := t.TempDir()
+	schemas := filepath.Join(root, "schemas", "pets")
+	os.MkdirAll(schemas, 0755)
+	os.WriteFile(filepath.Join(root, CONFIG_FILE), []byte("{\"inputs\": [\"schemas/*/*.tg\"]}"), 0644)
+	os.WriteFile(filepath.Join(schemas, "cat.tg"), []byte("type Cat {}\n"), 0644)
+	os.WriteFile(filepath.Join(schemas, "notes.txt"), []byte(""), 0644)
+
+	for _, path := range []string{root, schemas, filepath.Join(schemas, "cat.tg")} {
+		if found := findConfigFile(path); found != filepath.Join(root, CONFIG_FILE) {
+			t.Errorf("Expected the configuration file to be found from '%v', found '%v'", path, found)
+		}
+	}
+
+	cliOptions, config := loadOptions(root, nil)
+	if config == nil || cliOptions.generator.indent != 4 {
+		t.Fatalf("Expected the configuration to be loaded with default options, found %+v", cliOptions.generator)
+	}
+	if files := config.inputFiles(root); !slices.Equal(files, []string{filepath.Join(schemas, "cat.tg")}) {
+		t.Errorf("Expected the configured inputs to match a single schema, found %v", files)
+	}
+	if files := config.inputFiles(filepath.Join(root, "schemas", "dogs")); len(files) != 0 {
+		t.Errorf("Expected no inputs outside of the directory, found %v", files)
+	}
+
+	// '**' matches any number of directories
+	deep := filepath.Join(schemas, "wild", "lion.tg")
+	os.MkdirAll(filepath.Dir(deep), 0755)
+	os.WriteFile(deep, []byte("type Lion {}\n"), 0644)
+	config.inputs = []string{filepath.Join(root, "schemas", "**", "*.tg")}
+	if files := config.inputFiles(root); !slices.Equal(files, []string{filepath.Join(schemas, "cat.tg"), deep}) {
+		t.Errorf("Expected the recursive input to match schemas at every level, found %v", files)
+	}
+}
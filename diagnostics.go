@@ -0,0 +1,710 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// DiagnosticCode identifies the kind of a reported problem. The thousands digit tells which stage reported it:
+// 1 - lexer, 2 - parser, 3 - typechecker, 4 - generators, 5 - lint (see LINT_RULES), 6 - configuration file.
+type DiagnosticCode = string
+
+const (
+	CODE_INVALID_RUNE_ENCODING  DiagnosticCode = "TG1001"
+	CODE_UNCLOSED_STRING        DiagnosticCode = "TG1002"
+	CODE_UNCLOSED_BLOCK_COMMENT DiagnosticCode = "TG1003"
+	CODE_UNCLOSED_CODE_BLOCK    DiagnosticCode = "TG1004"
+	CODE_UNKNOWN_SYMBOL         DiagnosticCode = "TG1005"
+
+	CODE_UNEXPECTED_TOKEN          DiagnosticCode = "TG2001"
+	CODE_INVALID_TUPLE             DiagnosticCode = "TG2002"
+	CODE_ATTRIBUTES_ON_USE         DiagnosticCode = "TG2003"
+	CODE_ATTRIBUTES_ON_DECLARATION DiagnosticCode = "TG2004"
+
+	CODE_UNDECLARED_TYPE         DiagnosticCode = "TG3001"
+	CODE_REDECLARED_TYPE         DiagnosticCode = "TG3002"
+	CODE_REDECLARED_FIELD        DiagnosticCode = "TG3003"
+	CODE_REDECLARED_METHOD       DiagnosticCode = "TG3004"
+	CODE_RESERVED_NAME           DiagnosticCode = "TG3005"
+	CODE_UNDECLARED_MIXIN        DiagnosticCode = "TG3006"
+	CODE_REDECLARED_MIXIN        DiagnosticCode = "TG3007"
+	CODE_NESTED_MIXIN            DiagnosticCode = "TG3008"
+	CODE_DUPLICATE_MIXIN_USE     DiagnosticCode = "TG3009"
+	CODE_UNKNOWN_ATTRIBUTE       DiagnosticCode = "TG3010"
+	CODE_DUPLICATE_ATTRIBUTE     DiagnosticCode = "TG3011"
+	CODE_INVALID_ATTRIBUTE_ARG   DiagnosticCode = "TG3012"
+	CODE_CONFLICTING_ATTRIBUTES  DiagnosticCode = "TG3013"
+	CODE_EXCLUDED_TYPE_REFERENCE DiagnosticCode = "TG3014"
+	CODE_UNKNOWN_LANGUAGE        DiagnosticCode = "TG3015"
+	CODE_INVALID_FLAGS_BACKING   DiagnosticCode = "TG3016"
+	CODE_FLAGS_OVERFLOW          DiagnosticCode = "TG3017"
+	CODE_REDECLARED_FLAG         DiagnosticCode = "TG3018"
+	CODE_RECURSIVE_TYPE          DiagnosticCode = "TG3019"
+
+	CODE_KEYWORD_COLLISION DiagnosticCode = "TG4001"
+	CODE_GENERATOR_FAILURE DiagnosticCode = "TG4002"
+	CODE_PLUGIN_FAILURE    DiagnosticCode = "TG4003"
+	CODE_TEMPLATE_FAILURE  DiagnosticCode = "TG4004"
+	// Reported as a warning, the construct is generated without the unsupported part
+	CODE_UNSUPPORTED_CONSTRUCT DiagnosticCode = "TG4005"
+	CODE_NAME_COLLISION        DiagnosticCode = "TG4006"
+
+	CODE_CONFIG_SYNTAX         DiagnosticCode = "TG6001"
+	CODE_CONFIG_UNKNOWN_OPTION DiagnosticCode = "TG6002"
+	CODE_CONFIG_INVALID_VALUE  DiagnosticCode = "TG6003"
+)
+
+type Severity = int
+
+const (
+	SEVERITY_ERROR Severity = iota
+	SEVERITY_WARNING
+	SEVERITY_NOTE
+)
+
+func severityToString(severity Severity) string {
+	switch severity {
+	case SEVERITY_ERROR:
+		return "error"
+	case SEVERITY_WARNING:
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+func severityFromString(severity string) (Severity, bool) {
+	switch severity {
+	case "error":
+		return SEVERITY_ERROR, true
+	case "warning":
+		return SEVERITY_WARNING, true
+	case "note":
+		return SEVERITY_NOTE, true
+	}
+	return SEVERITY_ERROR, false
+}
+
+// Diagnostic is a problem found in a schema by any of the stages. Positions span from start to end (inclusive).
+type Diagnostic struct {
+	code     DiagnosticCode
+	severity Severity
+	file     string
+	start    LinePos
+	end      LinePos
+	message  string
+	// Other places relevant to the problem, for example the first declaration of a redeclared type
+	related []RelatedLocation
+	// Suggested edit resolving the problem, nil when there is none
+	fix *FixIt
+}
+
+type RelatedLocation struct {
+	file    string
+	start   LinePos
+	end     LinePos
+	message string
+}
+
+// FixIt replaces the text between start and end (exclusive) with the replacement. Insertions have start equal to end.
+type FixIt struct {
+	message     string
+	start       LinePos
+	end         LinePos
+	replacement string
+}
+
+func newDiagnostic(code DiagnosticCode, file string, pos LinePos, message string) Diagnostic {
+	diagnostic := Diagnostic{
+		code:     code,
+		severity: SEVERITY_ERROR,
+		file:     file,
+		start:    pos,
+		end:      pos,
+		message:  message,
+	}
+
+	return diagnostic
+}
+
+// Same as newDiagnostic, but the diagnostic spans the whole name starting at the given position
+func newNameDiagnostic(code DiagnosticCode, file string, pos LinePos, name string, message string) Diagnostic {
+	diagnostic := newDiagnostic(code, file, pos, message)
+	diagnostic.end = nameEnd(pos, name)
+	return diagnostic
+}
+
+// Returns the position of the last character of a name starting at the given position
+func nameEnd(pos LinePos, name string) LinePos {
+	length := utf8.RuneCountInString(name)
+	if length > 0 {
+		pos.offset += length - 1
+	}
+	return pos
+}
+
+func (diagnostic *Diagnostic) addRelated(start LinePos, end LinePos, message string) {
+	related := RelatedLocation{
+		file:    diagnostic.file,
+		start:   start,
+		end:     end,
+		message: message,
+	}
+	diagnostic.related = append(diagnostic.related, related)
+}
+
+// Suggests replacing the name at the given position with the closest of the candidates, if any is close enough
+func (diagnostic *Diagnostic) suggestName(pos LinePos, name string, candidates []string) {
+	suggestion, found := closestName(name, candidates)
+	if !found {
+		return
+	}
+
+	end := nameEnd(pos, name)
+	end.offset++
+	diagnostic.fix = &FixIt{
+		message:     fmt.Sprintf("did you mean '%s'?", suggestion),
+		start:       pos,
+		end:         end,
+		replacement: suggestion,
+	}
+}
+
+// Returns the candidate with the smallest edit distance to the name. Candidates further than a third
+// of the name's length (but at least one edit) are not considered. On ties the earlier candidate wins.
+func closestName(name string, candidates []string) (string, bool) {
+	maxDistance := max(1, utf8.RuneCountInString(name)/3)
+
+	closest, closestDistance := "", maxDistance+1
+	for _, candidate := range candidates {
+		if candidate == name {
+			continue
+		}
+
+		distance := editDistance(name, candidate)
+		if distance < closestDistance {
+			closest, closestDistance = candidate, distance
+		}
+	}
+
+	return closest, closest != ""
+}
+
+// Computes the optimal string alignment distance, which is the Levenshtein distance
+// where swapping two adjacent characters also counts as a single edit.
+func editDistance(a string, b string) int {
+	runesA, runesB := []rune(a), []rune(b)
+
+	// Only the last three rows of the matrix are needed at once
+	beforePrevious := make([]int, len(runesB)+1)
+	previous := make([]int, len(runesB)+1)
+	current := make([]int, len(runesB)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(runesA); i++ {
+		current[0] = i
+		for j := 1; j <= len(runesB); j++ {
+			cost := 1
+			if runesA[i-1] == runesB[j-1] {
+				cost = 0
+			}
+
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+			if i > 1 && j > 1 && runesA[i-1] == runesB[j-2] && runesA[i-2] == runesB[j-1] {
+				current[j] = min(current[j], beforePrevious[j-2]+1)
+			}
+		}
+		beforePrevious, previous, current = previous, current, beforePrevious
+	}
+
+	return previous[len(runesB)]
+}
+
+// Diagnostics are returned as errors by the generators
+func (diagnostic Diagnostic) Error() string {
+	builder := strings.Builder{}
+	severity := strings.ToUpper(severityToString(diagnostic.severity))
+	start := diagnostic.start
+	builder.WriteString(fmt.Sprintf("%s[%s] @ %s:%v:%v %s", severity, diagnostic.code, diagnostic.file, start.number, start.offset, diagnostic.message))
+
+	for _, related := range diagnostic.related {
+		builder.WriteString(fmt.Sprintf("\n  %s:%v:%v %s", related.file, related.start.number, related.start.offset, related.message))
+	}
+
+	if diagnostic.fix != nil {
+		builder.WriteString("\n  help: " + diagnostic.fix.message)
+	}
+
+	return builder.String()
+}
+
+type DiagnosticsFormat = int
+
+const (
+	FORMAT_TEXT DiagnosticsFormat = iota
+	FORMAT_JSON
+	FORMAT_SARIF
+)
+
+// Returns false for unrecognized formats
+func diagnosticsFormatFromString(format string) (DiagnosticsFormat, bool) {
+	switch strings.ToLower(format) {
+	case "text":
+		return FORMAT_TEXT, true
+	case "json":
+		return FORMAT_JSON, true
+	case "sarif":
+		return FORMAT_SARIF, true
+	}
+	return FORMAT_TEXT, false
+}
+
+// Version of the JSON diagnostics output, bumped on incompatible changes
+const DIAGNOSTICS_JSON_VERSION = 1
+
+type jsonPosition struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+type jsonRelated struct {
+	File    string       `json:"file"`
+	Start   jsonPosition `json:"start"`
+	End     jsonPosition `json:"end"`
+	Message string       `json:"message"`
+}
+
+type jsonFix struct {
+	Message     string       `json:"message"`
+	Start       jsonPosition `json:"start"`
+	End         jsonPosition `json:"end"`
+	Replacement string       `json:"replacement"`
+}
+
+type jsonDiagnostic struct {
+	Code     string        `json:"code"`
+	Severity string        `json:"severity"`
+	File     string        `json:"file"`
+	Start    jsonPosition  `json:"start"`
+	End      jsonPosition  `json:"end"`
+	Message  string        `json:"message"`
+	Related  []jsonRelated `json:"related,omitempty"`
+	Fix      *jsonFix      `json:"fix,omitempty"`
+}
+
+type jsonDiagnostics struct {
+	Version     int              `json:"version"`
+	Diagnostics []jsonDiagnostic `json:"diagnostics"`
+}
+
+func toJSONPosition(pos LinePos) jsonPosition {
+	return jsonPosition{Line: pos.number, Column: pos.offset}
+}
+
+func fromJSONPosition(position jsonPosition) LinePos {
+	return LinePos{number: position.Line, offset: position.Column}
+}
+
+// Converts a diagnostic read from JSON, such as one reported by a plugin. Unknown severities are treated as errors.
+func fromJSONDiagnostic(converted jsonDiagnostic) Diagnostic {
+	severity, _ := severityFromString(converted.Severity)
+	diagnostic := Diagnostic{
+		code:     converted.Code,
+		severity: severity,
+		file:     converted.File,
+		start:    fromJSONPosition(converted.Start),
+		end:      fromJSONPosition(converted.End),
+		message:  converted.Message,
+	}
+
+	for _, related := range converted.Related {
+		diagnostic.related = append(diagnostic.related, RelatedLocation{
+			file:    related.File,
+			start:   fromJSONPosition(related.Start),
+			end:     fromJSONPosition(related.End),
+			message: related.Message,
+		})
+	}
+
+	if fix := converted.Fix; fix != nil {
+		diagnostic.fix = &FixIt{
+			message:     fix.Message,
+			start:       fromJSONPosition(fix.Start),
+			end:         fromJSONPosition(fix.End),
+			replacement: fix.Replacement,
+		}
+	}
+
+	return diagnostic
+}
+
+func toJSONDiagnostic(diagnostic Diagnostic) jsonDiagnostic {
+	converted := jsonDiagnostic{
+		Code:     diagnostic.code,
+		Severity: severityToString(diagnostic.severity),
+		File:     diagnostic.file,
+		Start:    toJSONPosition(diagnostic.start),
+		End:      toJSONPosition(diagnostic.end),
+		Message:  diagnostic.message,
+	}
+
+	for _, related := range diagnostic.related {
+		converted.Related = append(converted.Related, jsonRelated{
+			File:    related.file,
+			Start:   toJSONPosition(related.start),
+			End:     toJSONPosition(related.end),
+			Message: related.message,
+		})
+	}
+
+	if fix := diagnostic.fix; fix != nil {
+		converted.Fix = &jsonFix{
+			Message:     fix.message,
+			Start:       toJSONPosition(fix.start),
+			End:         toJSONPosition(fix.end),
+			Replacement: fix.replacement,
+		}
+	}
+
+	return converted
+}
+
+// Subset of SARIF 2.1.0 (https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html)
+// needed to describe the diagnostics.
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	Id string `json:"id"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleId           string          `json:"ruleId"`
+	Level            string          `json:"level"`
+	Message          sarifMessage    `json:"message"`
+	Locations        []sarifLocation `json:"locations"`
+	RelatedLocations []sarifLocation `json:"relatedLocations,omitempty"`
+	Fixes            []sarifFix      `json:"fixes,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	Message          *sarifMessage         `json:"message,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	Uri string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+}
+
+type sarifFix struct {
+	Description     sarifMessage          `json:"description"`
+	ArtifactChanges []sarifArtifactChange `json:"artifactChanges"`
+}
+
+type sarifArtifactChange struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Replacements     []sarifReplacement    `json:"replacements"`
+}
+
+type sarifReplacement struct {
+	DeletedRegion   sarifRegion   `json:"deletedRegion"`
+	InsertedContent *sarifMessage `json:"insertedContent,omitempty"`
+}
+
+// SARIF regions end at the column after the last character
+func toSarifRegion(start LinePos, end LinePos) sarifRegion {
+	region := sarifRegion{
+		StartLine:   start.number,
+		StartColumn: start.offset,
+		EndLine:     end.number,
+		EndColumn:   end.offset + 1,
+	}
+
+	return region
+}
+
+func toSarifLevel(severity Severity) string {
+	if severity == SEVERITY_NOTE {
+		return "note"
+	}
+	return severityToString(severity)
+}
+
+func toSarifResult(diagnostic Diagnostic) sarifResult {
+	result := sarifResult{
+		RuleId:  diagnostic.code,
+		Level:   toSarifLevel(diagnostic.severity),
+		Message: sarifMessage{Text: diagnostic.message},
+		Locations: []sarifLocation{{
+			PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{Uri: diagnostic.file},
+				Region:           toSarifRegion(diagnostic.start, diagnostic.end),
+			},
+		}},
+	}
+
+	for _, related := range diagnostic.related {
+		result.RelatedLocations = append(result.RelatedLocations, sarifLocation{
+			PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{Uri: related.file},
+				Region:           toSarifRegion(related.start, related.end),
+			},
+			Message: &sarifMessage{Text: related.message},
+		})
+	}
+
+	if fix := diagnostic.fix; fix != nil {
+		deleted := toSarifRegion(fix.start, fix.end)
+		deleted.EndColumn = fix.end.offset
+		result.Fixes = []sarifFix{{
+			Description: sarifMessage{Text: fix.message},
+			ArtifactChanges: []sarifArtifactChange{{
+				ArtifactLocation: sarifArtifactLocation{Uri: diagnostic.file},
+				Replacements: []sarifReplacement{{
+					DeletedRegion:   deleted,
+					InsertedContent: &sarifMessage{Text: fix.replacement},
+				}},
+			}},
+		}}
+	}
+
+	return result
+}
+
+func toSarifLog(diagnostics []Diagnostic) sarifLog {
+	rules := make([]sarifRule, 0)
+	results := make([]sarifResult, 0, len(diagnostics))
+	for _, diagnostic := range diagnostics {
+		known := false
+		for _, rule := range rules {
+			known = known || rule.Id == diagnostic.code
+		}
+		if !known {
+			rules = append(rules, sarifRule{Id: diagnostic.code})
+		}
+
+		results = append(results, toSarifResult(diagnostic))
+	}
+
+	log := sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool:    sarifTool{Driver: sarifDriver{Name: "pocketgen", Rules: rules}},
+			Results: results,
+		}},
+	}
+
+	return log
+}
+
+// Writes the diagnostics in the requested format. Text is written one diagnostic per line,
+// JSON and SARIF as a single indented document.
+func writeDiagnostics(writer io.Writer, diagnostics []Diagnostic, format DiagnosticsFormat) error {
+	var document any
+	switch format {
+	case FORMAT_JSON:
+		converted := make([]jsonDiagnostic, 0, len(diagnostics))
+		for _, diagnostic := range diagnostics {
+			converted = append(converted, toJSONDiagnostic(diagnostic))
+		}
+		document = jsonDiagnostics{Version: DIAGNOSTICS_JSON_VERSION, Diagnostics: converted}
+	case FORMAT_SARIF:
+		document = toSarifLog(diagnostics)
+	default:
+		for _, diagnostic := range diagnostics {
+			_, err := fmt.Fprintln(writer, diagnostic.Error())
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(document)
+}
+
+// ANSI escape sequences used when rendering diagnostics for a terminal
+const (
+	ANSI_RESET  = "\x1b[0m"
+	ANSI_BOLD   = "\x1b[1m"
+	ANSI_RED    = "\x1b[1;31m"
+	ANSI_YELLOW = "\x1b[1;33m"
+	ANSI_BLUE   = "\x1b[1;34m"
+	ANSI_CYAN   = "\x1b[1;36m"
+)
+
+// Colors are only used when writing to a terminal, unless disabled with the NO_COLOR environment variable
+func shouldUseColors(file *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// DiagnosticRenderer renders diagnostics together with the source code they point at, in the style of:
+//
+//	error[TG3001]: Type of field 'Detials' was never declared.
+//	 --> cat.tg:3:5
+//	  |
+//	3 |     Detials details;
+//	  |     ^^^^^^^
+type DiagnosticRenderer struct {
+	// Contents of the files, by path. Diagnostics of files missing here are rendered without snippets.
+	sources map[string][]byte
+	colors  bool
+}
+
+func (renderer *DiagnosticRenderer) paint(color string, text string) string {
+	if !renderer.colors {
+		return text
+	}
+	return color + text + ANSI_RESET
+}
+
+func (renderer *DiagnosticRenderer) severityColor(severity Severity) string {
+	switch severity {
+	case SEVERITY_ERROR:
+		return ANSI_RED
+	case SEVERITY_WARNING:
+		return ANSI_YELLOW
+	default:
+		return ANSI_CYAN
+	}
+}
+
+// Returns the given line of the file without the line terminator, false when it does not exist
+func (renderer *DiagnosticRenderer) sourceLine(file string, number int) (string, bool) {
+	source, found := renderer.sources[file]
+	if !found || number < 1 {
+		return "", false
+	}
+
+	lines := strings.Split(string(source), "\n")
+	if number > len(lines) {
+		return "", false
+	}
+	return strings.TrimRight(lines[number-1], "\r"), true
+}
+
+func (renderer *DiagnosticRenderer) render(diagnostic Diagnostic) string {
+	builder := strings.Builder{}
+	color := renderer.severityColor(diagnostic.severity)
+	header := fmt.Sprintf("%s[%s]", severityToString(diagnostic.severity), diagnostic.code)
+	builder.WriteString(renderer.paint(color, header) + renderer.paint(ANSI_BOLD, ": "+diagnostic.message) + "\n")
+
+	// The gutter is wide enough for every line number shown
+	width := len(fmt.Sprint(diagnostic.start.number))
+	for _, related := range diagnostic.related {
+		width = max(width, len(fmt.Sprint(related.start.number)))
+	}
+	gutter := strings.Repeat(" ", width)
+
+	start := diagnostic.start
+	location := fmt.Sprintf("%s:%v:%v", diagnostic.file, start.number, start.offset)
+	builder.WriteString(gutter + renderer.paint(ANSI_BLUE, "--> ") + location + "\n")
+
+	renderer.writeSnippet(&builder, width, diagnostic.file, diagnostic.start, diagnostic.end, "^", color, "")
+	for _, related := range diagnostic.related {
+		if related.file != diagnostic.file {
+			location := fmt.Sprintf("%s:%v:%v", related.file, related.start.number, related.start.offset)
+			builder.WriteString(gutter + renderer.paint(ANSI_BLUE, "::: ") + location + "\n")
+		}
+		renderer.writeSnippet(&builder, width, related.file, related.start, related.end, "-", ANSI_BLUE, related.message)
+	}
+
+	if diagnostic.fix != nil {
+		builder.WriteString(gutter + renderer.paint(ANSI_BLUE, " = ") + renderer.paint(ANSI_BOLD, "help") + ": " + diagnostic.fix.message + "\n")
+	}
+
+	return builder.String()
+}
+
+// Writes the source line at the start position with the span underlined. Spans crossing lines are underlined
+// up to the end of the first line.
+func (renderer *DiagnosticRenderer) writeSnippet(builder *strings.Builder, width int, file string, start LinePos, end LinePos, mark string, color string, label string) {
+	line, found := renderer.sourceLine(file, start.number)
+	if !found {
+		if label != "" {
+			location := fmt.Sprintf("%s:%v:%v", file, start.number, start.offset)
+			builder.WriteString(strings.Repeat(" ", width) + renderer.paint(ANSI_BLUE, " = ") + location + " " + label + "\n")
+		}
+		return
+	}
+
+	emptyGutter := renderer.paint(ANSI_BLUE, strings.Repeat(" ", width)+" |")
+	number := fmt.Sprintf("%*v |", width, start.number)
+	builder.WriteString(emptyGutter + "\n")
+	builder.WriteString(renderer.paint(ANSI_BLUE, number) + " " + line + "\n")
+
+	runes := []rune(line)
+	length := 1
+	if end.number == start.number && end.offset > start.offset {
+		length = end.offset - start.offset + 1
+	} else if end.number > start.number {
+		length = max(len(runes)-start.offset+1, 1)
+	}
+
+	// Tabs are kept, so that the underline stays aligned with the source line
+	padding := strings.Builder{}
+	for i := 0; i < start.offset-1 && i < len(runes); i++ {
+		if runes[i] == '\t' {
+			padding.WriteRune('\t')
+		} else {
+			padding.WriteRune(' ')
+		}
+	}
+
+	underline := strings.Repeat(mark, length)
+	if label != "" {
+		underline += " " + label
+	}
+	builder.WriteString(emptyGutter + " " + padding.String() + renderer.paint(color, underline) + "\n")
+}
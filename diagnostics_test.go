@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRedeclarationDiagnostic(t *testing.T) {
+	parser, result := parseAndTypecheck(t, "type Cat {}\ntype Cat {}\n")
+	if result.success || len(parser.diagnostics) != 1 {
+		t.Fatalf("Expected a single redeclaration error, found:\n%v", result.message)
+	}
+
+	diagnostic := parser.diagnostics[0]
+	if diagnostic.code != CODE_REDECLARED_TYPE || diagnostic.start.number != 2 {
+		t.Errorf("Expected redeclaration to be reported at the second declaration: %v", diagnostic.Error())
+	}
+
+	if len(diagnostic.related) != 1 || diagnostic.related[0].start.number != 1 {
+		t.Errorf("Expected the first declaration as a related location: %v", diagnostic.Error())
+	}
+}
+
+func TestMissingSemicolonFixIt(t *testing.T) {
+	parser := CreateParserFromData("test", []byte("type Cat {\n    u32 age\n}\n"))
+	ParseFile(&parser)
+	if len(parser.diagnostics) != 1 {
+		t.Fatalf("Expected a single syntax error, found %v", len(parser.diagnostics))
+	}
+
+	fix := parser.diagnostics[0].fix
+	if fix == nil || fix.replacement != ";" || fix.start.number != 3 {
+		t.Errorf("Expected a fix inserting a semicolon: %v", parser.diagnostics[0].Error())
+	}
+}
+
+func TestKeywordCollisionDiagnostic(t *testing.T) {
+	parser, result := parseAndTypecheck(t, "type Cat {\n    u32 range;\n}\n")
+	if !result.success {
+		t.Fatal(result.message)
+	}
+	schema := parser.Schema()
+
+	goGen := GoGenerator{defaultOptions()}
+	err := goGen.write(&schema, "", &bytes.Buffer{})
+
+	var diagnostic Diagnostic
+	if !errors.As(err, &diagnostic) || diagnostic.code != CODE_KEYWORD_COLLISION || diagnostic.start.number != 2 {
+		t.Errorf("Expected a keyword collision diagnostic, found: %v", err)
+	}
+}
+
+func TestJSONDiagnostics(t *testing.T) {
+	diagnostic := newDiagnostic(CODE_UNDECLARED_TYPE, "cat.tg", LinePos{number: 3, offset: 5}, "Type of field 'Dog' was never declared.")
+	diagnostic.addRelated(LinePos{number: 1, offset: 1}, LinePos{number: 1, offset: 4}, "Declared in type 'Cat'.")
+
+	buffer := bytes.Buffer{}
+	err := writeDiagnostics(&buffer, []Diagnostic{diagnostic}, FORMAT_JSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var document jsonDiagnostics
+	err = json.Unmarshal(buffer.Bytes(), &document)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if document.Version != DIAGNOSTICS_JSON_VERSION || len(document.Diagnostics) != 1 {
+		t.Fatalf("Unexpected document: %v", buffer.String())
+	}
+
+	actual := document.Diagnostics[0]
+	if actual.Code != "TG3001" || actual.Severity != "error" || actual.Start.Line != 3 || actual.Start.Column != 5 || len(actual.Related) != 1 {
+		t.Errorf("Unexpected diagnostic: %v", buffer.String())
+	}
+}
+
+func TestSarifDiagnostics(t *testing.T) {
+	diagnostic := newDiagnostic(CODE_UNEXPECTED_TOKEN, "cat.tg", LinePos{number: 2, offset: 7}, "Expected semicolon ';'.")
+
+	buffer := bytes.Buffer{}
+	err := writeDiagnostics(&buffer, []Diagnostic{diagnostic, diagnostic}, FORMAT_SARIF)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var log sarifLog
+	err = json.Unmarshal(buffer.Bytes(), &log)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := log.Runs[0]
+	if log.Version != "2.1.0" || len(run.Tool.Driver.Rules) != 1 || len(run.Results) != 2 {
+		t.Fatalf("Unexpected SARIF log: %v", buffer.String())
+	}
+
+	region := run.Results[0].Locations[0].PhysicalLocation.Region
+	if run.Results[0].RuleId != "TG2001" || region.StartLine != 2 || region.StartColumn != 7 {
+		t.Errorf("Unexpected SARIF result: %v", buffer.String())
+	}
+}
+
+func TestTextDiagnostics(t *testing.T) {
+	diagnostic := newDiagnostic(CODE_UNDECLARED_TYPE, "cat.tg", LinePos{number: 3, offset: 5}, "Type of field 'Dog' was never declared.")
+
+	buffer := bytes.Buffer{}
+	writeDiagnostics(&buffer, []Diagnostic{diagnostic}, FORMAT_TEXT)
+
+	expected := "ERROR[TG3001] @ cat.tg:3:5 Type of field 'Dog' was never declared."
+	if strings.TrimSpace(buffer.String()) != expected {
+		t.Errorf("Expected %v, found %v", expected, buffer.String())
+	}
+}
+
+func TestRenderedDiagnostic(t *testing.T) {
+	source := "type Cat {\n    string name;\n\tu32 name;\n}\n"
+	parser, result := parseAndTypecheck(t, source)
+	if result.success || len(parser.diagnostics) != 1 {
+		t.Fatalf("Expected a single redeclaration error, found:\n%v", result.message)
+	}
+
+	renderer := DiagnosticRenderer{sources: map[string][]byte{"test": []byte(source)}}
+	rendered := renderer.render(parser.diagnostics[0])
+
+	expected := []string{
+		"error[TG3003]: Field 'name' was declared multiple times.",
+		" --> test:3:6",
+		"3 | \tu32 name;",
+		"  | \t    ^^^^",
+		"2 |     string name;",
+		"  |            ---- First declaration of 'name'.",
+	}
+	for _, line := range expected {
+		if !strings.Contains(rendered, line) {
+			t.Errorf("Expected line %q in rendered diagnostic:\n%v", line, rendered)
+		}
+	}
+}
+
+func TestRenderedDiagnosticWithoutSource(t *testing.T) {
+	diagnostic := newDiagnostic(CODE_UNDECLARED_TYPE, "cat.tg", LinePos{number: 3, offset: 5}, "Type of field 'Dog' was never declared.")
+
+	renderer := DiagnosticRenderer{}
+	rendered := renderer.render(diagnostic)
+	if strings.Contains(rendered, "|") || !strings.Contains(rendered, " --> cat.tg:3:5") {
+		t.Errorf("Expected only the header and location without source:\n%v", rendered)
+	}
+}
+
+func TestClosestName(t *testing.T) {
+	candidates := append(slices.Clone(PRIMITIVES), "Details")
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"sting", "string"},
+		{"u23", "u32"},
+		{"Detials", "Details"},
+		{"bol", "bool"},
+		{"Cat", ""},
+		{"string", ""},
+	}
+
+	for _, test := range tests {
+		suggestion, _ := closestName(test.name, candidates)
+		if suggestion != test.expected {
+			t.Errorf("Expected suggestion '%v' for '%v', found '%v'", test.expected, test.name, suggestion)
+		}
+	}
+}
+
+func TestUndeclaredTypeSuggestion(t *testing.T) {
+	parser, result := parseAndTypecheck(t, "type Cat {\n    Detials details;\n}\ntype Details {}\n")
+	if result.success || len(parser.diagnostics) != 1 {
+		t.Fatalf("Expected a single undeclared type error, found:\n%v", result.message)
+	}
+
+	fix := parser.diagnostics[0].fix
+	if fix == nil || fix.replacement != "Details" || fix.start.offset != 5 || fix.end.offset != 12 {
+		t.Errorf("Expected a fix replacing 'Detials' with 'Details': %v", parser.diagnostics[0].Error())
+	}
+}
+
+func TestMisspelledKeywordSuggestion(t *testing.T) {
+	parser := CreateParserFromData("test", []byte("tpye Cat {}\ntype Dog {\n    fucn bark();\n}\n"))
+	ParseFile(&parser)
+	if len(parser.diagnostics) != 2 {
+		t.Fatalf("Expected two syntax errors, found %v", len(parser.diagnostics))
+	}
+
+	for i, expected := range []string{"type", "func"} {
+		fix := parser.diagnostics[i].fix
+		if fix == nil || fix.replacement != expected {
+			t.Errorf("Expected a fix replacing the keyword with '%v': %v", expected, parser.diagnostics[i].Error())
+		}
+	}
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// Version of the documents written by 'dump'. Increased whenever a field is removed or changes its meaning,
+// new fields may be added without a change of version.
+const DUMP_JSON_VERSION = 1
+
+type DumpKind = string
+
+const (
+	// Tokens as produced by the lexer
+	DUMP_TOKENS DumpKind = "tokens"
+	// Declarations as parsed, before the typechecker splices mixins and resolves primitives
+	DUMP_AST DumpKind = "ast"
+	// Declarations after typechecking, as the generators receive them
+	DUMP_TYPED DumpKind = "typed"
+)
+
+var DUMP_KINDS = []DumpKind{DUMP_TOKENS, DUMP_AST, DUMP_TYPED}
+
+type jsonToken struct {
+	Type  string `json:"type"`
+	Value string `json:"value,omitempty"`
+	Tag   string `json:"tag,omitempty"`
+	// Kind of the problem, only set for error tokens
+	Error  string       `json:"error,omitempty"`
+	Start  jsonPosition `json:"start"`
+	End    jsonPosition `json:"end"`
+	Offset int          `json:"offset"`
+}
+
+type jsonAttributeArg struct {
+	Key   string  `json:"key"`
+	Value *string `json:"value,omitempty"`
+}
+
+type jsonAttribute struct {
+	Name  string             `json:"name"`
+	Start jsonPosition       `json:"start"`
+	Args  []jsonAttributeArg `json:"args"`
+}
+
+type jsonField struct {
+	Name       string          `json:"name,omitempty"`
+	NameStart  *jsonPosition   `json:"nameStart,omitempty"`
+	Type       string          `json:"type,omitempty"`
+	TypeStart  jsonPosition    `json:"typeStart"`
+	Modifiers  []string        `json:"modifiers"`
+	Attributes []jsonAttribute `json:"attributes"`
+	Elements   []jsonField     `json:"elements,omitempty"`
+	Result     *jsonField      `json:"result,omitempty"`
+	// Name of the type generated for a tuple or function type by languages which cannot express it inline,
+	// only set after typechecking
+	GeneratedName string `json:"generatedName,omitempty"`
+}
+
+type jsonMethod struct {
+	Name        string          `json:"name"`
+	Start       jsonPosition    `json:"start"`
+	Params      []jsonField     `json:"params"`
+	ReturnType  string          `json:"returnType,omitempty"`
+	ReturnStart *jsonPosition   `json:"returnStart,omitempty"`
+	ReturnTuple *jsonField      `json:"returnTuple,omitempty"`
+	Attributes  []jsonAttribute `json:"attributes"`
+}
+
+type jsonMixinUse struct {
+	Name  string       `json:"name"`
+	Start jsonPosition `json:"start"`
+}
+
+type jsonCodeBlock struct {
+	Tag      string       `json:"tag"`
+	Code     string       `json:"code"`
+	Start    jsonPosition `json:"start"`
+	Position int          `json:"position"`
+}
+
+type jsonTypeDecl struct {
+	Name       string          `json:"name"`
+	Start      jsonPosition    `json:"start"`
+	NameStart  jsonPosition    `json:"nameStart"`
+	End        jsonPosition    `json:"end"`
+	Fields     []jsonField     `json:"fields"`
+	Methods    []jsonMethod    `json:"methods"`
+	Uses       []jsonMixinUse  `json:"uses"`
+	Attributes []jsonAttribute `json:"attributes"`
+	CodeBlocks []jsonCodeBlock `json:"codeBlocks"`
+}
+
+type jsonFlagMember struct {
+	Name  string       `json:"name"`
+	Start jsonPosition `json:"start"`
+	Value uint64       `json:"value"`
+}
+
+type jsonFlagsDecl struct {
+	Name         string           `json:"name"`
+	Start        jsonPosition     `json:"start"`
+	NameStart    jsonPosition     `json:"nameStart"`
+	End          jsonPosition     `json:"end"`
+	BackingType  string           `json:"backingType"`
+	BackingStart jsonPosition     `json:"backingStart"`
+	Members      []jsonFlagMember `json:"members"`
+}
+
+type jsonTokensDump struct {
+	Version int         `json:"version"`
+	Kind    DumpKind    `json:"kind"`
+	File    string      `json:"file"`
+	Tokens  []jsonToken `json:"tokens"`
+}
+
+type jsonDeclarationsDump struct {
+	Version int            `json:"version"`
+	Kind    DumpKind       `json:"kind"`
+	File    string         `json:"file"`
+	Types   []jsonTypeDecl `json:"types"`
+	// Omitted after typechecking, when mixins are spliced into the types using them
+	Mixins     []jsonTypeDecl  `json:"mixins,omitempty"`
+	Flags      []jsonFlagsDecl `json:"flags"`
+	CodeBlocks []jsonCodeBlock `json:"codeBlocks"`
+}
+
+// Names of TokenErrorType values, indexed by the value
+var TOKEN_ERROR_NAMES = []string{"invalid_rune_encoding", "unclosed_string", "unclosed_block_comment", "unclosed_code_block"}
+
+// Names of the field modifiers in the order of their bits
+var MODIFIER_NAMES = []struct {
+	modifier FieldModifier
+	name     string
+}{
+	{FIELD_CONST, "const"},
+	{FIELD_ARRAY, "array"},
+	{FIELD_NULLABLE, "nullable"},
+	{FIELD_PRIMITIVE, "primitive"},
+	{FIELD_TUPLE, "tuple"},
+	{FIELD_FUNCTION, "function"},
+	{FIELD_BOXED, "boxed"},
+}
+
+func modifiersToStrings(modifiers FieldModifier) []string {
+	names := make([]string, 0)
+	for _, entry := range MODIFIER_NAMES {
+		if modifiers&entry.modifier != 0 {
+			names = append(names, entry.name)
+		}
+	}
+	return names
+}
+
+func toJSONPositionPtr(pos LinePos) *jsonPosition {
+	position := toJSONPosition(pos)
+	return &position
+}
+
+// Names of token types without the prefix, such as 'identifier' or 'curly_open'
+func tokenTypeToJSON(tokenType TokenType) string {
+	return strings.ToLower(strings.TrimPrefix(TokenTypeToString(tokenType), "TOKEN_"))
+}
+
+// Lexes the data until the end of file. Errors are included as tokens, the lexer continues past them.
+func dumpTokens(filepath string, data []byte) jsonTokensDump {
+	lexer := CreateLexer(data)
+	tokens := make([]jsonToken, 0)
+	for {
+		token := lexer.NextToken()
+		converted := jsonToken{
+			Type:   tokenTypeToJSON(token.tokenType),
+			Start:  toJSONPosition(token.line),
+			End:    toJSONPosition(token.end),
+			Offset: token.pos,
+		}
+
+		switch token.tokenType {
+		case TOKEN_KEYWORD, TOKEN_IDENTIFIER, TOKEN_STRING, TOKEN_UNKNOWN_SYMBOL:
+			converted.Value = TokenValueToString(token)
+		case TOKEN_CODE_BLOCK:
+			converted.Value = token.tokenValue.string
+			converted.Tag = token.tokenValue.tag
+		case TOKEN_ERROR:
+			converted.Error = TOKEN_ERROR_NAMES[token.tokenValue.int]
+		}
+		tokens = append(tokens, converted)
+
+		if IsType(token, TOKEN_EOF) {
+			return jsonTokensDump{Version: DUMP_JSON_VERSION, Kind: DUMP_TOKENS, File: filepath, Tokens: tokens}
+		}
+	}
+}
+
+func toJSONAttributes(attributes []Attribute) []jsonAttribute {
+	converted := make([]jsonAttribute, 0, len(attributes))
+	for _, attribute := range attributes {
+		args := make([]jsonAttributeArg, 0, len(attribute.args))
+		for _, arg := range attribute.args {
+			jsonArg := jsonAttributeArg{Key: arg.key}
+			if arg.hasValue {
+				value := arg.value
+				jsonArg.Value = &value
+			}
+			args = append(args, jsonArg)
+		}
+		converted = append(converted, jsonAttribute{Name: attribute.name, Start: toJSONPosition(attribute.line), Args: args})
+	}
+	return converted
+}
+
+func toJSONField(field Field) jsonField {
+	converted := jsonField{
+		Name:          field.varName,
+		Type:          field.typeName,
+		TypeStart:     toJSONPosition(field.typeLine),
+		Modifiers:     modifiersToStrings(field.modifiers),
+		Attributes:    toJSONAttributes(field.attributes),
+		GeneratedName: field.generatedName,
+	}
+
+	// Elements of tuples and parameters of function types have no names
+	if field.varName != "" {
+		converted.NameStart = toJSONPositionPtr(field.varLine)
+	}
+
+	for _, element := range field.elements {
+		converted.Elements = append(converted.Elements, toJSONField(element))
+	}
+
+	if field.result != nil {
+		result := toJSONField(*field.result)
+		converted.Result = &result
+	}
+	return converted
+}
+
+func modifiersFromStrings(names []string) FieldModifier {
+	modifiers := FIELD_NONE
+	for _, entry := range MODIFIER_NAMES {
+		if slices.Contains(names, entry.name) {
+			modifiers |= entry.modifier
+		}
+	}
+	return modifiers
+}
+
+// Converts a field read from JSON back, attributes are left out
+func fromJSONField(converted jsonField) Field {
+	field := Field{
+		varName:       converted.Name,
+		typeName:      converted.Type,
+		typeLine:      fromJSONPosition(converted.TypeStart),
+		modifiers:     modifiersFromStrings(converted.Modifiers),
+		generatedName: converted.GeneratedName,
+	}
+
+	if converted.NameStart != nil {
+		field.varLine = fromJSONPosition(*converted.NameStart)
+	}
+
+	for _, element := range converted.Elements {
+		field.elements = append(field.elements, fromJSONField(element))
+	}
+
+	if converted.Result != nil {
+		result := fromJSONField(*converted.Result)
+		field.result = &result
+	}
+	return field
+}
+
+func toJSONFields(fields []Field) []jsonField {
+	converted := make([]jsonField, 0, len(fields))
+	for _, field := range fields {
+		converted = append(converted, toJSONField(field))
+	}
+	return converted
+}
+
+func toJSONCodeBlocks(codeBlocks []CodeBlock) []jsonCodeBlock {
+	converted := make([]jsonCodeBlock, 0, len(codeBlocks))
+	for _, block := range codeBlocks {
+		converted = append(converted, jsonCodeBlock{Tag: block.tag, Code: block.code, Start: toJSONPosition(block.line), Position: block.position})
+	}
+	return converted
+}
+
+func toJSONTypeDecl(decl TypeDecl) jsonTypeDecl {
+	converted := jsonTypeDecl{
+		Name:       decl.typeName,
+		Start:      toJSONPosition(decl.line),
+		NameStart:  toJSONPosition(decl.typeLine),
+		End:        toJSONPosition(decl.endLine),
+		Fields:     toJSONFields(decl.fields),
+		Methods:    make([]jsonMethod, 0, len(decl.methods)),
+		Uses:       make([]jsonMixinUse, 0, len(decl.uses)),
+		Attributes: toJSONAttributes(decl.attributes),
+		CodeBlocks: toJSONCodeBlocks(decl.codeBlocks),
+	}
+
+	for _, method := range decl.methods {
+		jsonMethod := jsonMethod{
+			Name:       method.name,
+			Start:      toJSONPosition(method.line),
+			Params:     toJSONFields(method.fields),
+			ReturnType: method.returnType,
+			Attributes: toJSONAttributes(method.attributes),
+		}
+		if method.returnType != "" {
+			jsonMethod.ReturnStart = toJSONPositionPtr(method.returnLine)
+		}
+		if method.returnTuple != nil {
+			tuple := toJSONField(*method.returnTuple)
+			jsonMethod.ReturnTuple = &tuple
+		}
+		converted.Methods = append(converted.Methods, jsonMethod)
+	}
+
+	for _, use := range decl.uses {
+		converted.Uses = append(converted.Uses, jsonMixinUse{Name: use.name, Start: toJSONPosition(use.nameLine)})
+	}
+	return converted
+}
+
+func toJSONTypeDecls(decls []TypeDecl) []jsonTypeDecl {
+	converted := make([]jsonTypeDecl, 0, len(decls))
+	for _, decl := range decls {
+		converted = append(converted, toJSONTypeDecl(decl))
+	}
+	return converted
+}
+
+func toJSONFlagsDecl(flagsDecl FlagsDecl) jsonFlagsDecl {
+	converted := jsonFlagsDecl{
+		Name:         flagsDecl.name,
+		Start:        toJSONPosition(flagsDecl.line),
+		NameStart:    toJSONPosition(flagsDecl.nameLine),
+		End:          toJSONPosition(flagsDecl.endLine),
+		BackingType:  flagsDecl.backingType,
+		BackingStart: toJSONPosition(flagsDecl.backingLine),
+		Members:      make([]jsonFlagMember, 0, len(flagsDecl.members)),
+	}
+
+	for i, member := range flagsDecl.members {
+		converted.Members = append(converted.Members, jsonFlagMember{Name: member.name, Start: toJSONPosition(member.line), Value: 1 << i})
+	}
+	return converted
+}
+
+// Converts declarations of a parsed file. Mixins are included only when the file wasn't typechecked yet.
+func dumpDeclarations(parser *Parser, kind DumpKind) jsonDeclarationsDump {
+	dump := jsonDeclarationsDump{
+		Version:    DUMP_JSON_VERSION,
+		Kind:       kind,
+		File:       parser.filepath,
+		Flags:      make([]jsonFlagsDecl, 0, len(parser.flags)),
+		CodeBlocks: toJSONCodeBlocks(parser.codeBlocks),
+	}
+
+	if kind == DUMP_AST {
+		dump.Types = toJSONTypeDecls(parser.structs)
+		dump.Mixins = toJSONTypeDecls(parser.mixins)
+	} else {
+		dump.Types = toJSONTypeDecls(withGeneratedNames(parser.structs))
+	}
+
+	for _, flagsDecl := range parser.flags {
+		dump.Flags = append(dump.Flags, toJSONFlagsDecl(flagsDecl))
+	}
+	return dump
+}
+
+// Returns copies of the types, where tuples and function types are named as generators name them
+// (before any target renames the declarations)
+func withGeneratedNames(types []TypeDecl) []TypeDecl {
+	named := make([]TypeDecl, len(types))
+	for i, t := range types {
+		t.fields = cloneFields(t.fields)
+		methods := make([]FuncDecl, len(t.methods))
+		for j, method := range t.methods {
+			method.fields = cloneFields(method.fields)
+			if method.returnTuple != nil {
+				returnTuple := cloneField(*method.returnTuple)
+				method.returnTuple = &returnTuple
+			}
+			methods[j] = method
+		}
+		t.methods = methods
+
+		nameTypeGeneratedTypes(&t)
+		named[i] = t
+	}
+	return named
+}
+
+func writeDump(writer io.Writer, dump any) error {
+	encoded, err := json.MarshalIndent(dump, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(writer, string(encoded))
+	return err
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestDumpTokens(t *testing.T) {
+	dump := dumpTokens("test", []byte("type Cat { u32 age; }\n\"open"))
+	if dump.Version != DUMP_JSON_VERSION || dump.Kind != DUMP_TOKENS {
+		t.Fatalf("Unexpected header: %+v", dump)
+	}
+
+	types := make([]string, 0, len(dump.Tokens))
+	for _, token := range dump.Tokens {
+		types = append(types, token.Type)
+	}
+	expected := []string{"keyword", "identifier", "curly_open", "identifier", "identifier", "semicolon", "curly_close", "error", "eof"}
+	if !slices.Equal(types, expected) {
+		t.Fatalf("Expected token types %v, found %v", expected, types)
+	}
+
+	age := dump.Tokens[4]
+	if age.Value != "age" || age.Start != (jsonPosition{Line: 1, Column: 16}) || age.End != (jsonPosition{Line: 1, Column: 18}) || age.Offset != 15 {
+		t.Errorf("Unexpected token: %+v", age)
+	}
+
+	if dump.Tokens[7].Error != "unclosed_string" {
+		t.Errorf("Expected an unclosed string error: %+v", dump.Tokens[7])
+	}
+}
+
+func TestDumpDeclarations(t *testing.T) {
+	source := "mixin Named {\n    string name;\n}\ntype Cat {\n    use Named;\n    [u32?] ages;\n}\nflags Perm : u8 {\n    READ;\n    WRITE;\n}\n"
+	parser := CreateParserFromData("test", []byte(source))
+	result := ParseFile(&parser)
+	if !result.success {
+		t.Fatal(result.message)
+	}
+
+	ast := dumpDeclarations(&parser, DUMP_AST)
+	if len(ast.Mixins) != 1 || len(ast.Types[0].Fields) != 1 || len(ast.Types[0].Uses) != 1 {
+		t.Fatalf("Expected the mixin to be kept separately before typechecking: %+v", ast)
+	}
+
+	ages := ast.Types[0].Fields[0]
+	if !slices.Equal(ages.Modifiers, []string{"array", "nullable"}) {
+		t.Errorf("Expected modifiers of a nullable array, found %v", ages.Modifiers)
+	}
+
+	result = TypecheckFile(&parser)
+	if !result.success {
+		t.Fatal(result.message)
+	}
+
+	typed := dumpDeclarations(&parser, DUMP_TYPED)
+	if len(typed.Mixins) != 0 || len(typed.Types[0].Fields) != 2 {
+		t.Fatalf("Expected the mixin to be spliced after typechecking: %+v", typed)
+	}
+
+	name := typed.Types[0].Fields[0]
+	if name.Name != "name" || !slices.Contains(name.Modifiers, "primitive") || *name.NameStart != (jsonPosition{Line: 2, Column: 12}) {
+		t.Errorf("Unexpected spliced field: %+v", name)
+	}
+
+	perm := typed.Flags[0]
+	if perm.BackingType != "u8" || len(perm.Members) != 2 || perm.Members[1].Value != 2 {
+		t.Errorf("Unexpected flags: %+v", perm)
+	}
+
+	// The written document is read back the same
+	buffer := bytes.Buffer{}
+	err := writeDump(&buffer, typed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded jsonDeclarationsDump
+	err = json.Unmarshal(buffer.Bytes(), &decoded)
+	if err != nil || decoded.Version != DUMP_JSON_VERSION || decoded.Kind != DUMP_TYPED || len(decoded.Types[0].Fields) != 2 {
+		t.Errorf("Unexpected document read back (%v):\n%v", err, buffer.String())
+	}
+}
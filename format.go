@@ -0,0 +1,515 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Formatter prints a parsed schema back in the canonical form: one member per line, types of consecutive fields
+// aligned, normalized spacing and a blank line between declarations. Comments are kept where they were written.
+// Formatting an already formatted schema gives back the same text.
+type Formatter struct {
+	builder  strings.Builder
+	indent   int
+	comments []Comment
+	// Index of the first comment which wasn't printed yet
+	nextComment int
+	// Lines of the source, used to keep blank lines separating members
+	lines []string
+	// Source line of the last printed line
+	lastLine int
+	// Unset at the beginning of a block, where blank lines of the source are dropped
+	blockStarted bool
+	// Forces a blank line before the next printed line
+	separate bool
+}
+
+// A declaration or a member of a type, positioned where it starts in the source (at its first attribute, if any)
+type formatItem struct {
+	start     LinePos
+	typeDecl  *TypeDecl
+	isMixin   bool
+	flagsDecl *FlagsDecl
+	codeBlock *CodeBlock
+	field     *Field
+	method    *FuncDecl
+	use       *MixinUse
+}
+
+// Position following every other position, used as the end of the file
+var END_OF_FILE = LinePos{number: math.MaxInt, offset: math.MaxInt}
+
+// Formats a successfully parsed (but not typechecked) schema. Typechecking expands mixins, which would duplicate
+// their members in every type using them.
+func formatSchema(parser *Parser, indent int) string {
+	formatter := Formatter{
+		indent:   indent,
+		comments: parser.lexer.comments,
+		lines:    strings.Split(string(parser.lexer.data), "\n"),
+	}
+
+	items := topLevelItems(parser)
+	for i, item := range items {
+		next := END_OF_FILE
+		if i+1 < len(items) {
+			next = items[i+1].start
+		}
+
+		formatter.separate = i > 0
+		formatter.flushComments(item.start, 0)
+		switch {
+		case item.typeDecl != nil:
+			keyword := KEYWORD_TYPE
+			if item.isMixin {
+				keyword = KEYWORD_MIXIN
+			}
+			formatter.writeType(item.typeDecl, keyword, next)
+		case item.flagsDecl != nil:
+			formatter.writeFlags(item.flagsDecl, next)
+		case item.codeBlock != nil:
+			formatter.writeLine(formatCodeBlock(*item.codeBlock), item.start, 0, next)
+		}
+	}
+
+	formatter.separate = len(items) > 0
+	formatter.flushComments(END_OF_FILE, 0)
+	return formatter.builder.String()
+}
+
+func attributesStart(attributes []Attribute, start LinePos) LinePos {
+	if len(attributes) > 0 {
+		return attributes[0].line
+	}
+	return start
+}
+
+func sortFormatItems(items []formatItem) {
+	slices.SortStableFunc(items, func(a formatItem, b formatItem) int {
+		if a.start.number != b.start.number {
+			return a.start.number - b.start.number
+		}
+		return a.start.offset - b.start.offset
+	})
+}
+
+func topLevelItems(parser *Parser) []formatItem {
+	items := make([]formatItem, 0)
+	for i := range parser.structs {
+		decl := &parser.structs[i]
+		items = append(items, formatItem{start: attributesStart(decl.attributes, decl.line), typeDecl: decl})
+	}
+	for i := range parser.mixins {
+		items = append(items, formatItem{start: parser.mixins[i].line, typeDecl: &parser.mixins[i], isMixin: true})
+	}
+	for i := range parser.flags {
+		items = append(items, formatItem{start: parser.flags[i].line, flagsDecl: &parser.flags[i]})
+	}
+	for i := range parser.codeBlocks {
+		items = append(items, formatItem{start: parser.codeBlocks[i].line, codeBlock: &parser.codeBlocks[i]})
+	}
+
+	sortFormatItems(items)
+	return items
+}
+
+func typeMembers(decl *TypeDecl) []formatItem {
+	items := make([]formatItem, 0)
+	for i := range decl.fields {
+		field := &decl.fields[i]
+		items = append(items, formatItem{start: attributesStart(field.attributes, field.typeLine), field: field})
+	}
+	for i := range decl.methods {
+		method := &decl.methods[i]
+		items = append(items, formatItem{start: attributesStart(method.attributes, method.line), method: method})
+	}
+	for i := range decl.uses {
+		items = append(items, formatItem{start: decl.uses[i].line, use: &decl.uses[i]})
+	}
+	for i := range decl.codeBlocks {
+		items = append(items, formatItem{start: decl.codeBlocks[i].line, codeBlock: &decl.codeBlocks[i]})
+	}
+
+	sortFormatItems(items)
+	return items
+}
+
+func (formatter *Formatter) isBlank(number int) bool {
+	return number >= 1 && number <= len(formatter.lines) && strings.TrimSpace(formatter.lines[number-1]) == ""
+}
+
+// Starts a new output line for the text coming from the given source position. A blank line is printed before it
+// when forced, or when the source had one and the line isn't the first one of a block.
+func (formatter *Formatter) startLine(text string, source LinePos, depth int) {
+	blank := formatter.blockStarted && source.number > formatter.lastLine && formatter.isBlank(source.number-1)
+	if formatter.separate || blank {
+		formatter.builder.WriteString("\n")
+	}
+
+	formatter.separate = false
+	formatter.blockStarted = true
+	formatter.lastLine = source.number
+	formatter.builder.WriteString(strings.Repeat(" ", depth*formatter.indent))
+	formatter.builder.WriteString(text)
+}
+
+// Ends the current output line. A comment written after the last token of the line is kept at its end,
+// that is any comment following the printed line in the source before the next position.
+func (formatter *Formatter) endLine(next LinePos) {
+	if formatter.nextComment < len(formatter.comments) {
+		comment := formatter.comments[formatter.nextComment]
+		if comment.trailing && linePosBefore(comment.line, next) {
+			formatter.builder.WriteString(" #" + comment.text)
+			formatter.nextComment++
+		}
+	}
+	formatter.builder.WriteString("\n")
+}
+
+func (formatter *Formatter) writeLine(text string, source LinePos, depth int, next LinePos) {
+	formatter.startLine(text, source, depth)
+	formatter.endLine(next)
+}
+
+// Prints every comment positioned before the given position on lines of their own
+func (formatter *Formatter) flushComments(before LinePos, depth int) {
+	for formatter.nextComment < len(formatter.comments) {
+		comment := formatter.comments[formatter.nextComment]
+		if !linePosBefore(comment.line, before) {
+			return
+		}
+
+		formatter.nextComment++
+		formatter.startLine("#"+comment.text, comment.line, depth)
+		formatter.builder.WriteString("\n")
+	}
+}
+
+func (formatter *Formatter) hasCommentsBefore(pos LinePos) bool {
+	return formatter.nextComment < len(formatter.comments) && linePosBefore(formatter.comments[formatter.nextComment].line, pos)
+}
+
+// Prints the closing brace of a block, blank lines before it are always dropped
+func (formatter *Formatter) writeBlockEnd(next LinePos) {
+	formatter.builder.WriteString("}")
+	formatter.endLine(next)
+}
+
+func (formatter *Formatter) writeAttributes(attributes []Attribute, declStart LinePos) {
+	for i, attribute := range attributes {
+		next := declStart
+		if i+1 < len(attributes) {
+			next = attributes[i+1].line
+		}
+		// Comments between the attributes and the declaration stay in place
+		formatter.flushComments(attribute.line, 0)
+		formatter.writeLine(formatAttribute(attribute), attribute.line, 0, next)
+	}
+}
+
+func (formatter *Formatter) writeType(decl *TypeDecl, keyword string, next LinePos) {
+	formatter.writeAttributes(decl.attributes, decl.line)
+	formatter.flushComments(decl.line, 0)
+
+	header := keyword + " " + decl.typeName + " {"
+	members := typeMembers(decl)
+	if len(members) == 0 && !formatter.hasCommentsBefore(decl.endLine) {
+		formatter.writeLine(header+"}", decl.line, 0, next)
+		return
+	}
+
+	firstLine := decl.endLine
+	if len(members) > 0 {
+		firstLine = members[0].start
+	}
+	formatter.writeLine(header, decl.line, 0, firstLine)
+	formatter.blockStarted = false
+
+	widths := formatter.fieldWidths(members)
+	for i, member := range members {
+		memberNext := decl.endLine
+		if i+1 < len(members) {
+			memberNext = members[i+1].start
+		}
+
+		formatter.flushComments(member.start, 1)
+		var text string
+		switch {
+		case member.field != nil:
+			text = formatField(*member.field, widths[i]) + ";"
+		case member.method != nil:
+			text = formatMethod(*member.method) + ";"
+		case member.use != nil:
+			text = KEYWORD_USE + " " + member.use.name + ";"
+		case member.codeBlock != nil:
+			text = formatCodeBlock(*member.codeBlock)
+		}
+		formatter.writeLine(text, member.start, 1, memberNext)
+	}
+
+	formatter.flushComments(decl.endLine, 1)
+	formatter.writeBlockEnd(next)
+}
+
+// Computes the width of the type column of every field. Types are aligned within runs of fields
+// which follow each other, without blank lines or comments in between.
+func (formatter *Formatter) fieldWidths(members []formatItem) []int {
+	widths := make([]int, len(members))
+	runStart := 0
+	for i := 0; i <= len(members); i++ {
+		continuesRun := i > 0 && i < len(members) && members[i].field != nil && members[i-1].field != nil &&
+			members[i].start.number-members[i-1].start.number <= 1 && !formatter.separatedByComment(members[i-1].start, members[i].start)
+		if continuesRun {
+			continue
+		}
+
+		width := 0
+		for _, member := range members[runStart:i] {
+			if member.field != nil {
+				width = max(width, utf8.RuneCountInString(formatFieldLeft(*member.field)))
+			}
+		}
+		for j := runStart; j < i; j++ {
+			widths[j] = width
+		}
+		runStart = i
+	}
+	return widths
+}
+
+// Whether any comment written on a line of its own lies between the two positions
+func (formatter *Formatter) separatedByComment(start LinePos, end LinePos) bool {
+	for _, comment := range formatter.comments[formatter.nextComment:] {
+		if !comment.trailing && linePosBefore(start, comment.line) && linePosBefore(comment.line, end) {
+			return true
+		}
+	}
+	return false
+}
+
+func (formatter *Formatter) writeFlags(flagsDecl *FlagsDecl, next LinePos) {
+	header := fmt.Sprintf("%s %s : %s {", KEYWORD_FLAGS, flagsDecl.name, flagsDecl.backingType)
+	if len(flagsDecl.members) == 0 && !formatter.hasCommentsBefore(flagsDecl.endLine) {
+		formatter.writeLine(header+"}", flagsDecl.line, 0, next)
+		return
+	}
+
+	firstLine := flagsDecl.endLine
+	if len(flagsDecl.members) > 0 {
+		firstLine = flagsDecl.members[0].line
+	}
+	formatter.writeLine(header, flagsDecl.line, 0, firstLine)
+	formatter.blockStarted = false
+
+	for i, member := range flagsDecl.members {
+		memberNext := flagsDecl.endLine
+		if i+1 < len(flagsDecl.members) {
+			memberNext = flagsDecl.members[i+1].line
+		}
+
+		formatter.flushComments(member.line, 1)
+		formatter.writeLine(member.name+";", member.line, 1, memberNext)
+	}
+
+	formatter.flushComments(flagsDecl.endLine, 1)
+	formatter.writeBlockEnd(next)
+}
+
+func formatAttribute(attribute Attribute) string {
+	args := make([]string, len(attribute.args))
+	for i, arg := range attribute.args {
+		args[i] = arg.key
+		if arg.hasValue {
+			escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg.value)
+			args[i] += `="` + escaped + `"`
+		}
+	}
+	return "@" + attribute.name + "(" + strings.Join(args, ", ") + ")"
+}
+
+func formatAttributes(attributes []Attribute) string {
+	text := ""
+	for _, attribute := range attributes {
+		text += formatAttribute(attribute) + " "
+	}
+	return text
+}
+
+func formatFieldType(field Field) string {
+	var text string
+	if field.hasModifier(FIELD_TUPLE) {
+		text = "(" + formatFieldTypes(field.elements) + ")"
+	} else if field.hasModifier(FIELD_FUNCTION) {
+		text = KEYWORD_FN + "(" + formatFieldTypes(field.elements) + ")"
+		if field.result != nil {
+			text += " " + formatFieldType(*field.result)
+		}
+	} else {
+		text = field.typeName
+	}
+
+	if field.hasModifier(FIELD_NULLABLE) {
+		text += "?"
+	}
+
+	if field.hasModifier(FIELD_ARRAY) {
+		text = "[" + text + "]"
+	}
+	return text
+}
+
+func formatFieldTypes(fields []Field) string {
+	types := make([]string, len(fields))
+	for i, field := range fields {
+		types[i] = formatFieldType(field)
+	}
+	return strings.Join(types, ", ")
+}
+
+// Everything preceding the name of a field: attributes, the const modifier and the type
+func formatFieldLeft(field Field) string {
+	text := formatAttributes(field.attributes)
+	if field.hasModifier(FIELD_CONST) {
+		text += KEYWORD_CONST + " "
+	}
+	return text + formatFieldType(field)
+}
+
+// Formats the field with the type column padded to the given width
+func formatField(field Field, width int) string {
+	left := formatFieldLeft(field)
+	padding := max(0, width-utf8.RuneCountInString(left))
+	return left + strings.Repeat(" ", padding) + " " + field.varName
+}
+
+func formatMethod(method FuncDecl) string {
+	params := make([]string, len(method.fields))
+	for i, param := range method.fields {
+		params[i] = formatField(param, 0)
+	}
+
+	text := formatAttributes(method.attributes) + KEYWORD_FUNC + " " + method.name + "(" + strings.Join(params, ", ") + ")"
+	if method.returnTuple != nil {
+		text += " " + formatFieldType(*method.returnTuple)
+	} else if method.returnType != "" {
+		text += " " + method.returnType
+	}
+	return text
+}
+
+func formatCodeBlock(codeBlock CodeBlock) string {
+	return "%" + codeBlock.tag + "{" + codeBlock.code + "}%"
+}
+
+// Splits the text into lines, keeping the line breaks
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// Builds a unified diff of two texts, with three lines of context around every change.
+// Returns an empty string when the texts are the same.
+func unifiedDiff(name string, before string, after string) string {
+	if before == after {
+		return ""
+	}
+
+	a := splitLines(before)
+	b := splitLines(after)
+
+	// lengths[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else {
+				lengths[i][j] = max(lengths[i+1][j], lengths[i][j+1])
+			}
+		}
+	}
+
+	// Every line of the diff prefixed with ' ', '-' or '+'
+	type diffLine struct {
+		kind byte
+		text string
+	}
+	lines := make([]diffLine, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lengths[i+1][j] >= lengths[i][j+1]):
+			lines = append(lines, diffLine{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', b[j]})
+			j++
+		}
+	}
+
+	const CONTEXT = 3
+	builder := strings.Builder{}
+	builder.WriteString("--- " + name + "\n")
+	builder.WriteString("+++ " + name + "\n")
+
+	for start := 0; start < len(lines); {
+		if lines[start].kind == ' ' {
+			start++
+			continue
+		}
+
+		// Extend the hunk while changes are separated by less than twice the context
+		hunkStart := max(0, start-CONTEXT)
+		end := start
+		for k := start; k < len(lines) && k-end <= 2*CONTEXT; k++ {
+			if lines[k].kind != ' ' {
+				end = k + 1
+			}
+		}
+		hunkEnd := min(len(lines), end+CONTEXT)
+
+		// Line numbers of the hunk in both texts are counted from the start of the diff
+		oldStart, newStart := 1, 1
+		for _, line := range lines[:hunkStart] {
+			if line.kind != '+' {
+				oldStart++
+			}
+			if line.kind != '-' {
+				newStart++
+			}
+		}
+		oldCount, newCount := 0, 0
+		for _, line := range lines[hunkStart:hunkEnd] {
+			if line.kind != '+' {
+				oldCount++
+			}
+			if line.kind != '-' {
+				newCount++
+			}
+		}
+
+		builder.WriteString(fmt.Sprintf("@@ -%v,%v +%v,%v @@\n", oldStart, oldCount, newStart, newCount))
+		for _, line := range lines[hunkStart:hunkEnd] {
+			builder.WriteByte(line.kind)
+			builder.WriteString(line.text)
+			if !strings.HasSuffix(line.text, "\n") {
+				builder.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		start = hunkEnd
+	}
+
+	return builder.String()
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func formatSource(t *testing.T, path string, source string) string {
+	parser := CreateParserFromData(path, []byte(source))
+	result := ParseFile(&parser)
+	if !result.success {
+		t.Fatalf("Failed to parse %v:\n%v", path, result.message)
+	}
+	return formatSchema(&parser, 4)
+}
+
+func TestFormat(t *testing.T) {
+	source := `# Header
+@except(js) type  Car{ # trailing
+  u32 yearProduced; u16 doors;
+  const   [string?] tags ;
+
+
+  func drive( u16 speed , fn(u32)bool check) ;
+  # Details
+  (f64,f64) position;
+}
+flags Perm:u8{READ;WRITE;}
+@only(go)
+# Singly linked
+@name(go="Link")
+# Renamed in Go
+type Node {}
+`
+	expected := `# Header
+@except(js)
+type Car { # trailing
+    u32             yearProduced;
+    u16             doors;
+    const [string?] tags;
+
+    func drive(u16 speed, fn(u32) bool check);
+    # Details
+    (f64, f64) position;
+}
+
+flags Perm : u8 {
+    READ;
+    WRITE;
+}
+
+@only(go)
+# Singly linked
+@name(go="Link")
+# Renamed in Go
+type Node {}
+`
+	formatted := formatSource(t, "test", source)
+	if formatted != expected {
+		t.Errorf("Unexpected formatting:\n%v", unifiedDiff("test", expected, formatted))
+	}
+}
+
+func TestFormatIsIdempotent(t *testing.T) {
+	paths, err := filepath.Glob("test/*.tg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range paths {
+		// Contains syntax errors on purpose
+		if filepath.Base(path) == "lexer1.tg" {
+			continue
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		once := formatSource(t, path, string(data))
+		twice := formatSource(t, path, once)
+		if once != twice {
+			t.Errorf("Formatting %v is not idempotent:\n%v", path, unifiedDiff(path, once, twice))
+		}
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	before := "a\nb\nc\nd\n"
+	after := "a\nB\nc\nd\ne\n"
+	expected := "--- x\n+++ x\n@@ -1,4 +1,5 @@\n a\n-b\n+B\n c\n d\n+e\n"
+
+	diff := unifiedDiff("x", before, after)
+	if diff != expected {
+		t.Errorf("Expected diff:\n%v\nfound:\n%v", expected, diff)
+	}
+
+	if unifiedDiff("x", before, before) != "" || !strings.HasPrefix(diff, "--- x") {
+		t.Errorf("Expected no diff for equal texts")
+	}
+}
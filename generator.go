@@ -1,10 +1,8 @@
 package main
 
 import (
-	"bufio"
 	"bytes"
 	"fmt"
-	"os"
 	"slices"
 	"strings"
 	"unicode"
@@ -13,16 +11,47 @@ import (
 
 type GeneratorOptions struct {
 	indent               int
-	packageName          string
 	receiverNameFallback string
 	jsonAnnotations      bool
+	// Package of the generated code, empty for the default package of the language. Go packages may be given
+	// as a path (internal/pets), Java and Kotlin packages are qualified names (com.example.pets).
+	packageName string
+	// Declare types as records instead of classes, in Java
+	records bool
+	// Write every type and flags declaration to a file of its own, named after the declaration
+	filePerType bool
+	// Case of type and flags names and of field and parameter names, one of NAME_CASES. Names overridden with
+	// @name are kept as written, an empty case keeps the declared names.
+	typeCase  string
+	fieldCase string
+}
+
+const (
+	CASE_PASCAL = "pascal"
+	CASE_CAMEL  = "camel"
+	CASE_SNAKE  = "snake"
+)
+
+var NAME_CASES = []string{CASE_PASCAL, CASE_CAMEL, CASE_SNAKE}
+
+// Converts the name to one of NAME_CASES, an empty case returns the name unchanged
+func toCase(name string, nameCase string) string {
+	switch nameCase {
+	case CASE_PASCAL:
+		return toPascalCase(name)
+	case CASE_CAMEL:
+		return lowercaseFirstLetter(toPascalCase(name))
+	case CASE_SNAKE:
+		return toSnakeCase(name)
+	}
+	return name
 }
 
 // These require validation against specific languages
 func defaultOptions() GeneratorOptions {
 	return GeneratorOptions{
 		indent:               4,
-		packageName:          "main",
+		packageName:          "",
 		receiverNameFallback: "this",
 		jsonAnnotations:      false,
 	}
@@ -49,13 +78,26 @@ type RustGenerator struct {
 }
 
 func keywordCollisionError(declType string, keyword string, language string, filepath string, pos LinePos) error {
-	return fmt.Errorf("ERROR @ %s:%v:%v %v name '%v' is a %v keyword.\n",
-		filepath, pos.number, pos.offset, declType, keyword, language)
+	message := fmt.Sprintf("%v name '%v' is a %v keyword.", capitalizeFirstLetter(declType), keyword, language)
+	return newNameDiagnostic(CODE_KEYWORD_COLLISION, filepath, pos, keyword, message)
 }
 
-// Checks if keywords collide with type, field or parameter names per given keyword set
-func checkKeywords(types []TypeDecl, keywords []string, language string, filepath string) error {
-	for _, t := range types {
+// Checks if keywords collide with type, field, parameter or flag names per given keyword set
+func checkKeywords(schema *Schema, keywords []string, language string) error {
+	filepath := schema.filepath
+	for _, flags := range schema.flags {
+		if slices.Contains(keywords, flags.name) {
+			return keywordCollisionError("flags", flags.name, language, filepath, flags.nameLine)
+		}
+
+		for _, member := range flags.members {
+			if slices.Contains(keywords, member.name) {
+				return keywordCollisionError("flag", member.name, language, filepath, member.line)
+			}
+		}
+	}
+
+	for _, t := range schema.types {
 		if slices.Contains(keywords, t.typeName) {
 			return keywordCollisionError("type", t.typeName, language, filepath, t.typeLine)
 		}
@@ -82,103 +124,597 @@ func checkKeywords(types []TypeDecl, keywords []string, language string, filepat
 	return nil
 }
 
-// Translate types to a language specific representation using the supplied convert() function.
-// Each generator should provide a mapping function.
-func translateTypes(types []TypeDecl, convert func(s string) string) {
+// Checks that no two declarations of the same scope are generated with the same name, which happens
+// when distinct names are converted to the same case
+func checkNameCollisions(schema *Schema, language string) error {
+	filepath := schema.filepath
+	declared := make(map[string]LinePos, len(schema.flags)+len(schema.types))
+	collision := func(kind string, name string, pos LinePos, first LinePos) error {
+		message := fmt.Sprintf("%v name '%v' of the %v target collides with another declaration.", capitalizeFirstLetter(kind), name, language)
+		// Only the start is marked, since the declared name differs from the generated one
+		diagnostic := newDiagnostic(CODE_NAME_COLLISION, filepath, pos, message)
+		diagnostic.addRelated(first, first, fmt.Sprintf("Also generated as '%v'.", name))
+		return diagnostic
+	}
+
+	for _, flags := range schema.flags {
+		if first, found := declared[flags.name]; found {
+			return collision("flags", flags.name, flags.nameLine, first)
+		}
+		declared[flags.name] = flags.nameLine
+	}
+
+	for _, t := range schema.types {
+		if first, found := declared[t.typeName]; found {
+			return collision("type", t.typeName, t.typeLine, first)
+		}
+		declared[t.typeName] = t.typeLine
+
+		fields := make(map[string]LinePos, len(t.fields))
+		for _, field := range t.fields {
+			if first, found := fields[field.varName]; found {
+				return collision("field", field.varName, field.varLine, first)
+			}
+			fields[field.varName] = field.varLine
+		}
+
+		for _, fn := range t.methods {
+			params := make(map[string]LinePos, len(fn.fields))
+			for _, f := range fn.fields {
+				if first, found := params[f.varName]; found {
+					return collision("parameter", f.varName, f.varLine, first)
+				}
+				params[f.varName] = f.varLine
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the name overridden for the target with @name, or the declared name otherwise.
+func targetName(attributes []Attribute, language Language, name string) string {
+	return casedTargetName(attributes, language, name, "")
+}
+
+// Same as targetName, but a name which isn't overridden is converted to the given case
+func casedTargetName(attributes []Attribute, language Language, name string, nameCase string) string {
+	for _, attribute := range attributes {
+		if attribute.name != ATTRIBUTE_NAME {
+			continue
+		}
+
+		for _, arg := range attribute.args {
+			if languageIdentifierToLanguage(arg.key) == language {
+				return arg.value
+			}
+		}
+	}
+
+	return toCase(name, nameCase)
+}
+
+func resolveTargetFields(fields []Field, language Language, typeNames map[string]string, fieldCase string) []Field {
+	resolved := make([]Field, 0, len(fields))
+	for _, field := range fields {
+		if targetMask(field.attributes)&targetBit(language) == 0 {
+			continue
+		}
+
+		field.varName = casedTargetName(field.attributes, language, field.varName, fieldCase)
+		resolved = append(resolved, resolveTargetFieldType(field, typeNames))
+	}
+
+	return resolved
+}
+
+// Returns a copy of the field, where references to renamed types (including tuple elements and function
+// parameters and results) are replaced.
+func resolveTargetFieldType(field Field, typeNames map[string]string) Field {
+	if newName, renamed := typeNames[field.typeName]; renamed {
+		field.typeName = newName
+	}
+
+	if field.elements != nil {
+		elements := make([]Field, len(field.elements))
+		for i, element := range field.elements {
+			elements[i] = resolveTargetFieldType(element, typeNames)
+		}
+		field.elements = elements
+	}
+
+	if field.result != nil {
+		result := resolveTargetFieldType(*field.result, typeNames)
+		field.result = &result
+	}
+
+	return field
+}
+
+// Names tuples and function types, which are represented with generated types in languages that cannot
+// express them inline. The name is derived from enclosing declarations, for example the tuple of field 'position'
+// in type 'Car' is named 'CarPosition', and its second element (if it's a tuple as well) is named 'CarPositionItem1'.
+// Parameters of function types are suffixed with 'Arg' and the index, their results with 'Result'.
+func nameGeneratedTypes(field *Field, name string) {
+	if field.hasModifier(FIELD_TUPLE) || field.hasModifier(FIELD_FUNCTION) {
+		field.generatedName = name
+	}
+
+	suffix := "Item"
+	if field.hasModifier(FIELD_FUNCTION) {
+		suffix = "Arg"
+	}
+
+	for i := range field.elements {
+		nameGeneratedTypes(&field.elements[i], fmt.Sprintf("%s%s%v", name, suffix, i))
+	}
+
+	if field.result != nil {
+		nameGeneratedTypes(field.result, name+"Result")
+	}
+}
+
+// Returns every tuple and function type referenced by the type, including the ones nested in other tuples
+// and function types. Tuples returned by methods and function types are skipped, unless includeReturns is set,
+// but their nested tuples are not.
+func collectGeneratedTypes(t TypeDecl, includeReturns bool) []Field {
+	generated := make([]Field, 0)
+
+	var collect func(field Field)
+	collect = func(field Field) {
+		if field.hasModifier(FIELD_TUPLE) || field.hasModifier(FIELD_FUNCTION) {
+			generated = append(generated, field)
+		}
+
+		for _, element := range field.elements {
+			collect(element)
+		}
+
+		if field.result == nil {
+			return
+		}
+
+		if includeReturns || !field.result.hasModifier(FIELD_TUPLE) {
+			collect(*field.result)
+		} else {
+			for _, element := range field.result.elements {
+				collect(element)
+			}
+		}
+	}
+
+	for _, field := range t.fields {
+		collect(field)
+	}
+
+	for _, method := range t.methods {
+		for _, field := range method.fields {
+			collect(field)
+		}
+
+		if method.returnTuple == nil {
+			continue
+		}
+
+		if includeReturns {
+			collect(*method.returnTuple)
+		} else {
+			for _, element := range method.returnTuple.elements {
+				collect(element)
+			}
+		}
+	}
+
+	return generated
+}
+
+// Returns a copy of the types as seen by the given target. Declarations excluded with @only or @except are dropped
+// and the new names of types are applied, together with every reference to a renamed type.
+// The typechecker guarantees that no retained declaration references a dropped type.
+func resolveTargetTypes(types []TypeDecl, language Language, typeNames map[string]string, fieldCase string) []TypeDecl {
+	resolved := make([]TypeDecl, 0, len(types))
 	for _, t := range types {
-		for i := range t.fields {
-			field := &t.fields[i]
-			field.typeName = convert(field.typeName)
+		if targetMask(t.attributes)&targetBit(language) == 0 {
+			continue
+		}
+
+		t.typeName = typeNames[t.typeName]
+		t.fields = resolveTargetFields(t.fields, language, typeNames, fieldCase)
+
+		methods := make([]FuncDecl, 0, len(t.methods))
+		for _, method := range t.methods {
+			if targetMask(method.attributes)&targetBit(language) == 0 {
+				continue
+			}
+
+			method.name = targetName(method.attributes, language, method.name)
+			if newName, renamed := typeNames[method.returnType]; renamed {
+				method.returnType = newName
+			}
+
+			method.fields = resolveTargetFields(method.fields, language, typeNames, fieldCase)
+			if method.returnTuple != nil {
+				returnTuple := resolveTargetFieldType(*method.returnTuple, typeNames)
+				method.returnTuple = &returnTuple
+			}
+
+			methods = append(methods, method)
+		}
+		t.methods = methods
+		t.codeBlocks = resolveTargetCodeBlocks(t.codeBlocks, language)
+		nameTypeGeneratedTypes(&t)
+
+		resolved = append(resolved, t)
+	}
+
+	return resolved
+}
+
+// Names tuples and function types held by fields and methods of the type, see nameGeneratedTypes.
+// The fields are modified in place, so they must not be shared with other declarations.
+func nameTypeGeneratedTypes(t *TypeDecl) {
+	for i := range t.fields {
+		field := &t.fields[i]
+		nameGeneratedTypes(field, t.typeName+capitalizeFirstLetter(field.varName))
+	}
+
+	for i := range t.methods {
+		method := &t.methods[i]
+		methodName := t.typeName + capitalizeFirstLetter(method.name)
+		for j := range method.fields {
+			field := &method.fields[j]
+			nameGeneratedTypes(field, methodName+capitalizeFirstLetter(field.varName))
+		}
+
+		if method.returnTuple != nil {
+			nameGeneratedTypes(method.returnTuple, methodName+"Result")
+		}
+	}
+}
+
+func resolveTargetCodeBlocks(codeBlocks []CodeBlock, language Language) []CodeBlock {
+	resolved := make([]CodeBlock, 0)
+	for _, codeBlock := range codeBlocks {
+		if languageIdentifierToLanguage(codeBlock.tag) == language {
+			resolved = append(resolved, codeBlock)
 		}
-		for i := range t.methods {
-			method := &t.methods[i]
+	}
+
+	return resolved
+}
+
+// Returns a copy of the schema as seen by the given target. Names are overridden with @name or converted to
+// the cases of the options, code blocks of other languages are dropped and file level code blocks are
+// repositioned to follow the same types after declarations were excluded.
+func resolveTarget(schema *Schema, language Language, options GeneratorOptions) Schema {
+	typeNames := make(map[string]string, len(schema.flags)+len(schema.types))
+	flags := make([]FlagsDecl, len(schema.flags))
+	for i, decl := range schema.flags {
+		typeNames[decl.name] = toCase(decl.name, options.typeCase)
+		decl.name = typeNames[decl.name]
+		flags[i] = decl
+	}
+	for _, t := range schema.types {
+		typeNames[t.typeName] = casedTargetName(t.attributes, language, t.typeName, options.typeCase)
+	}
+
+	resolved := Schema{
+		filepath:   schema.filepath,
+		types:      resolveTargetTypes(schema.types, language, typeNames, options.fieldCase),
+		flags:      flags,
+		codeBlocks: resolveTargetCodeBlocks(schema.codeBlocks, language),
+	}
+
+	retained := make([]int, len(schema.types)+1)
+	for i, t := range schema.types {
+		retained[i+1] = retained[i]
+		if targetMask(t.attributes)&targetBit(language) != 0 {
+			retained[i+1] += 1
+		}
+	}
+
+	for i := range resolved.codeBlocks {
+		codeBlock := &resolved.codeBlocks[i]
+		codeBlock.position = retained[codeBlock.position]
+	}
+
+	return resolved
+}
+
+// Returns a copy of the types translated to a language specific representation using the supplied convert()
+// function. Each generator should provide a mapping function. The given types are left untouched, so that a single
+// typechecked schema can be generated for any number of targets.
+func translateTypes(types []TypeDecl, convert func(s string) string) []TypeDecl {
+	translated := make([]TypeDecl, len(types))
+	for i, t := range types {
+		fields := make([]Field, len(t.fields))
+		for j, field := range t.fields {
+			fields[j] = translateField(field, convert)
+		}
+		t.fields = fields
+
+		methods := make([]FuncDecl, len(t.methods))
+		for j, method := range t.methods {
 			method.returnType = convert(method.returnType)
-			for j := range method.fields {
-				field := &method.fields[j]
-				field.typeName = convert(field.typeName)
+			if method.returnTuple != nil {
+				returnTuple := translateField(*method.returnTuple, convert)
+				method.returnTuple = &returnTuple
+			}
+
+			params := make([]Field, len(method.fields))
+			for k, param := range method.fields {
+				params[k] = translateField(param, convert)
 			}
+			method.fields = params
+			methods[j] = method
 		}
+		t.methods = methods
+
+		translated[i] = t
+	}
+
+	return translated
+}
+
+// Returns a copy of the field with its type names (including tuple elements and function parameters and results)
+// translated using convert()
+func translateField(field Field, convert func(s string) string) Field {
+	field = cloneField(field)
+	translateFieldType(&field, convert)
+	return field
+}
+
+// Translates the type names in place, the field must not share elements with other fields
+func translateFieldType(field *Field, convert func(s string) string) {
+	if !field.hasModifier(FIELD_TUPLE) && !field.hasModifier(FIELD_FUNCTION) {
+		field.typeName = convert(field.typeName)
+	}
+
+	for i := range field.elements {
+		translateFieldType(&field.elements[i], convert)
+	}
+
+	if field.result != nil {
+		translateFieldType(field.result, convert)
 	}
 }
 
 // Writes Javascript definitions based on type declarations
-func (js *JavascriptGenerator) generate(types []TypeDecl, writer *bytes.Buffer) {
+func (js *JavascriptGenerator) write(schema *Schema, declaration string, writer *bytes.Buffer) error {
+	resolved := resolveTarget(schema, JAVASCRIPT, js.options)
+	types := resolved.types
+
+	err := checkNameCollisions(&resolved, js.name())
+	if err != nil {
+		return err
+	}
+
+	// Declarations in files of their own are modules, importing each other
+	export := ""
+	if declaration != "" {
+		export = "export "
+		js.writeImports(&resolved, declaration, writer)
+	}
+	writeHeaderCodeBlocks(&resolved, declaration, writer)
+
 	indent := js.options.indent
 	joiner := newJoiner()
-	for _, t := range types {
+	for _, flags := range resolved.flags {
+		if !writesDeclaration(declaration, flags.name) {
+			continue
+		}
 		if joiner.join() {
 			writer.WriteString("\n")
 		}
-		writer.WriteString("class " + t.typeName + " {\n")
+		writer.WriteString(export)
+		js.writeFlags(flags, writer)
+	}
+
+	for i, t := range types {
+		if !writesDeclaration(declaration, t.typeName) {
+			continue
+		}
+		if joiner.join() {
+			writer.WriteString("\n")
+		}
+		js.writeCallbacks(t, writer)
+		writer.WriteString(export + "class " + t.typeName + " {\n")
 
-		writeIndent(indent, writer)
 		js.writeConstructor(t.fields, writer)
 		js.writeMethods(t.methods, writer)
+		writeCodeBlocks(t.codeBlocks, indent, writer)
+		writer.WriteString("}\n")
+		writeTrailingCodeBlocks(resolved.codeBlocks, i, writer)
 	}
+	return nil
 }
 
 // Writes Go definitions based on type declarations
-func (goGen *GoGenerator) generate(types []TypeDecl, writer *bytes.Buffer, filepath string) error {
-	err := checkKeywords(types, GO_KEYWORDS, "go", filepath)
+func (goGen *GoGenerator) write(schema *Schema, declaration string, writer *bytes.Buffer) error {
+	resolved := resolveTarget(schema, GO, goGen.options)
+	types := resolved.types
+
+	err := checkKeywords(&resolved, goGen.keywords(), goGen.name())
 	if err != nil {
 		return err
 	}
-	translateTypes(types, toGoType)
+	err = checkNameCollisions(&resolved, goGen.name())
+	if err != nil {
+		return err
+	}
+	types = translateTypes(types, toGoType)
 
-	writer.WriteString("package " + goGen.options.packageName + "\n\n")
+	writer.WriteString("package " + goGen.packageClause() + "\n\n")
+	writeHeaderCodeBlocks(&resolved, declaration, writer)
 
 	typeJoiner := newJoiner()
-	for _, t := range types {
+	for _, flags := range resolved.flags {
+		if !writesDeclaration(declaration, flags.name) {
+			continue
+		}
+		if typeJoiner.join() {
+			writer.WriteString("\n")
+		}
+		goGen.writeFlags(flags, writer)
+	}
+
+	for i, t := range types {
+		if !writesDeclaration(declaration, t.typeName) {
+			continue
+		}
 		if typeJoiner.join() {
 			writer.WriteString("\n")
 		}
 		writer.WriteString("type " + t.typeName + " struct {\n")
 
 		goGen.writeFields(t.fields, writer)
+		writeCodeBlocks(t.codeBlocks, goGen.options.indent, writer)
 		writer.WriteString("}\n")
 		goGen.writeMethods(t, writer)
+		goGen.writeTuples(t, writer)
+		writeTrailingCodeBlocks(resolved.codeBlocks, i, writer)
 	}
 	return nil
 }
 
 // Writes Java definitions based on type declarations
-func (java *JavaGenerator) generate(types []TypeDecl, writer *bytes.Buffer, filepath string) error {
-	err := checkKeywords(types, JAVA_KEYWORDS, "java", filepath)
+func (java *JavaGenerator) write(schema *Schema, declaration string, writer *bytes.Buffer) error {
+	resolved := resolveTarget(schema, JAVA, java.options)
+	types := resolved.types
+
+	err := checkKeywords(&resolved, java.keywords(), java.name())
+	if err != nil {
+		return err
+	}
+	err = checkNameCollisions(&resolved, java.name())
 	if err != nil {
 		return err
 	}
-	translateTypes(types, toJavaType)
+	// Flags are represented as sets of enum constants
+	types = translateTypes(types, func(typeName string) string {
+		if isFlagsType(&resolved, typeName) {
+			return "EnumSet<" + typeName + ">"
+		}
+		return toJavaType(typeName)
+	})
+
+	if java.options.packageName != "" {
+		writer.WriteString("package " + java.options.packageName + ";\n\n")
+	}
+
+	// A file may declare a single public class, so declarations are public only in files of their own
+	modifier := ""
+	if declaration != "" {
+		modifier = "public "
+	}
+	declared := make([]TypeDecl, 0, len(types))
+	usesFlags := false
+	for i, t := range types {
+		if !writesDeclaration(declaration, t.typeName) {
+			continue
+		}
+		declared = append(declared, t)
+		referenced := typeReferences(resolved.types[i])
+		usesFlags = usesFlags || slices.ContainsFunc(resolved.flags, func(flags FlagsDecl) bool { return referenced.Contains(flags.name) })
+	}
+	for _, flags := range resolved.flags {
+		usesFlags = usesFlags || writesDeclaration(declaration, flags.name)
+	}
+
+	imports := java.functionImports(declared)
+	if usesFlags {
+		imports = append([]string{"java.util.EnumSet"}, imports...)
+	}
+	for _, imported := range imports {
+		writer.WriteString("import " + imported + ";\n")
+	}
+	if len(imports) > 0 {
+		writer.WriteString("\n")
+	}
+	writeHeaderCodeBlocks(&resolved, declaration, writer)
 
 	joiner := newJoiner()
 	// May require specifying package name
-	for _, t := range types {
+	for _, flags := range resolved.flags {
+		if !writesDeclaration(declaration, flags.name) {
+			continue
+		}
 		if joiner.join() {
 			writer.WriteString("\n")
 		}
-		writer.WriteString("class " + t.typeName + " {\n")
+		java.writeFlags(flags, modifier, writer)
+	}
 
-		java.writeFields(t.fields, writer)
-		if len(t.fields) > 0 {
+	for i, t := range types {
+		if !writesDeclaration(declaration, t.typeName) {
+			continue
+		}
+		if joiner.join() {
 			writer.WriteString("\n")
 		}
-		java.writeConstructor(t, writer)
-		java.writeMethods(t, writer)
+		if java.options.records {
+			// Components of records are final and initialized by the canonical constructor
+			writer.WriteString(modifier + "record " + t.typeName + "(")
+			joiner := newJoiner()
+			for _, field := range t.fields {
+				if joiner.join() {
+					writer.WriteString(", ")
+				}
+				java.writeField(field, writer)
+			}
+			writer.WriteString(") {\n")
+		} else {
+			writer.WriteString(modifier + "class " + t.typeName + " {\n")
+			java.writeFields(t.fields, writer)
+			if len(t.fields) > 0 {
+				writer.WriteString("\n")
+			}
+			java.writeConstructor(t, modifier, writer)
+		}
+		java.writeMethods(t, modifier, writer)
+		writeCodeBlocks(t.codeBlocks, java.options.indent, writer)
 		writer.WriteString("}\n")
+		java.writeGeneratedTypes(t, writer)
+		writeTrailingCodeBlocks(resolved.codeBlocks, i, writer)
 	}
 	return nil
 }
 
 // Writes Kotlin definitions based on type declarations
-func (kotlin *KotlinGenerator) generate(types []TypeDecl, writer *bytes.Buffer, filepath string) error {
-	err := checkKeywords(types, KOTLIN_KEYWORDS, "kotlin", filepath)
+func (kotlin *KotlinGenerator) write(schema *Schema, declaration string, writer *bytes.Buffer) error {
+	resolved := resolveTarget(schema, KOTLIN, kotlin.options)
+	types := resolved.types
+
+	err := checkKeywords(&resolved, kotlin.keywords(), kotlin.name())
+	if err != nil {
+		return err
+	}
+	err = checkNameCollisions(&resolved, kotlin.name())
 	if err != nil {
 		return err
 	}
-	translateTypes(types, toKotlinType)
+	types = translateTypes(types, toKotlinType)
+
+	if kotlin.options.packageName != "" {
+		writer.WriteString("package " + kotlin.options.packageName + "\n\n")
+	}
+	writeHeaderCodeBlocks(&resolved, declaration, writer)
 
 	joiner := newJoiner()
 	// May require specifying package name
-	for _, t := range types {
+	for _, flags := range resolved.flags {
+		if !writesDeclaration(declaration, flags.name) {
+			continue
+		}
+		if joiner.join() {
+			writer.WriteString("\n")
+		}
+		kotlin.writeFlags(flags, writer)
+	}
+
+	for i, t := range types {
+		if !writesDeclaration(declaration, t.typeName) {
+			continue
+		}
 		if joiner.join() {
 			writer.WriteString("\n")
 		}
@@ -192,45 +728,294 @@ func (kotlin *KotlinGenerator) generate(types []TypeDecl, writer *bytes.Buffer,
 			kotlin.writeConstructor(t, writer)
 		}
 
-		if len(t.methods) > 0 {
+		if len(t.methods) > 0 || len(t.codeBlocks) > 0 {
 			writer.WriteString(" {\n")
 			kotlin.writeMethods(t, writer)
+			writeCodeBlocks(t.codeBlocks, kotlin.options.indent, writer)
+			writer.WriteString("}")
+		}
+		writer.WriteString("\n")
+		kotlin.writeTuples(t, writer)
+		writeTrailingCodeBlocks(resolved.codeBlocks, i, writer)
+	}
+	return nil
+}
+
+// Writes Rust definitions based on type declarations
+func (rust *RustGenerator) write(schema *Schema, declaration string, writer *bytes.Buffer) error {
+	resolved := resolveTarget(schema, RUST, rust.options)
+	types := resolved.types
+
+	err := checkKeywords(&resolved, rust.keywords(), rust.name())
+	if err != nil {
+		return err
+	}
+	err = checkNameCollisions(&resolved, rust.name())
+	if err != nil {
+		return err
+	}
+	types = translateTypes(types, toRustType)
+
+	writeHeaderCodeBlocks(&resolved, declaration, writer)
+
+	joiner := newJoiner()
+	// May require specifying mod name
+	for _, flags := range resolved.flags {
+		if !writesDeclaration(declaration, flags.name) {
+			continue
+		}
+		if joiner.join() {
+			writer.WriteString("\n")
+		}
+		rust.writeFlags(flags, writer)
+	}
+
+	for i, t := range types {
+		if !writesDeclaration(declaration, t.typeName) {
+			continue
+		}
+		if joiner.join() {
+			writer.WriteString("\n")
+		}
+		writer.WriteString("struct " + t.typeName + " {\n")
+		rust.writeFields(t.fields, writer)
+		writeCodeBlocks(t.codeBlocks, rust.options.indent, writer)
+		writer.WriteString("}\n")
+
+		if len(t.methods) > 0 {
+			writer.WriteString("impl " + t.typeName + " {\n")
+			rust.writeMethods(t, writer)
 			writer.WriteString("}\n")
 		}
+		writeTrailingCodeBlocks(resolved.codeBlocks, i, writer)
 	}
 	return nil
 }
 
-// Writes Rust definitions based on type declarations
-func (rust *RustGenerator) generate(types []TypeDecl, writer *bytes.Buffer, filepath string) error {
-	err := checkKeywords(types, RUST_KEYWORDS, "rust", filepath)
-	if err != nil {
-		return err
+func isFlagsType(schema *Schema, typeName string) bool {
+	for _, flags := range schema.flags {
+		if flags.name == typeName {
+			return true
+		}
+	}
+	return false
+}
+
+// Imports the types and flags referenced by the declaration from the files they're declared in
+func (js *JavascriptGenerator) writeImports(resolved *Schema, declaration string, writer *bytes.Buffer) {
+	referenced := NewSet(0)
+	for _, t := range resolved.types {
+		if t.typeName == declaration {
+			referenced = typeReferences(t)
+		}
+	}
+
+	names := make([]string, 0)
+	for _, flags := range resolved.flags {
+		names = append(names, flags.name)
+	}
+	for _, t := range resolved.types {
+		names = append(names, t.typeName)
+	}
+
+	joiner := newJoiner()
+	for _, name := range names {
+		if name == declaration || !referenced.Contains(name) {
+			continue
+		}
+		joiner.join()
+		writer.WriteString(fmt.Sprintf("import { %s } from \"./%s%s\";\n", name, name, js.extension()))
+	}
+	if !joiner.firstCall {
+		writer.WriteString("\n")
+	}
+}
+
+// Writes flags as a frozen object of masks. Masks of 64-bit flags are BigInts, since numbers only hold 53 bits.
+func (js *JavascriptGenerator) writeFlags(flags FlagsDecl, writer *bytes.Buffer) {
+	indent := js.options.indent
+	writer.WriteString("const " + flags.name + " = Object.freeze({\n")
+	for bit, member := range flags.members {
+		writeIndent(indent, writer)
+		writer.WriteString(member.name + ": ")
+		switch {
+		case flags.backingType == "u64":
+			writer.WriteString(fmt.Sprintf("1n << %vn", bit))
+		case bit < 31:
+			writer.WriteString(fmt.Sprintf("1 << %v", bit))
+		default:
+			writer.WriteString(fmt.Sprintf("2 ** %v", bit))
+		}
+		writer.WriteString(",\n")
+	}
+	writer.WriteString("});\n")
+}
+
+// Writes flags as typed constants, with Has and Set helper methods
+func (goGen *GoGenerator) writeFlags(flags FlagsDecl, writer *bytes.Buffer) {
+	indent := goGen.options.indent
+	writer.WriteString("type " + flags.name + " " + toGoType(flags.backingType) + "\n")
+
+	if len(flags.members) > 0 {
+		writer.WriteString("\nconst (\n")
+		for i, member := range flags.members {
+			writeIndent(indent, writer)
+			writer.WriteString(flags.name + toPascalCase(member.name))
+			if i == 0 {
+				writer.WriteString(" " + flags.name + " = 1 << iota")
+			}
+			writer.WriteString("\n")
+		}
+		writer.WriteString(")\n")
+	}
+
+	receiver := goGen.toReceiverName(flags.name)
+	writer.WriteString("\nfunc (" + receiver + " " + flags.name + ") Has(flag " + flags.name + ") bool {\n")
+	writeIndent(indent, writer)
+	writer.WriteString("return " + receiver + "&flag == flag\n}\n")
+
+	writer.WriteString("\nfunc (" + receiver + " *" + flags.name + ") Set(flag " + flags.name + ") {\n")
+	writeIndent(indent, writer)
+	writer.WriteString("*" + receiver + " |= flag\n}\n")
+}
+
+// Writes flags as an enum, with helpers converting between an EnumSet and its mask
+// The modifier precedes the enum and its static methods
+func (java *JavaGenerator) writeFlags(flags FlagsDecl, modifier string, writer *bytes.Buffer) {
+	indent := java.options.indent
+	maskType, one := "int", "1"
+	if flags.backingType == "u64" {
+		maskType, one = "long", "1L"
+	}
+	setType := "EnumSet<" + flags.name + ">"
+
+	writer.WriteString(modifier + "enum " + flags.name + " {\n")
+	for i, member := range flags.members {
+		writeIndent(indent, writer)
+		writer.WriteString(member.name)
+		if i < len(flags.members)-1 {
+			writer.WriteString(",\n")
+		}
+	}
+	writer.WriteString(";\n\n")
+
+	writeIndent(indent, writer)
+	writer.WriteString("final " + maskType + " mask = " + one + " << ordinal();\n\n")
+
+	writeIndent(indent, writer)
+	writer.WriteString(modifier + "static " + maskType + " toMask(" + setType + " flags) {\n")
+	writeIndent(2*indent, writer)
+	writer.WriteString(maskType + " mask = 0;\n")
+	writeIndent(2*indent, writer)
+	writer.WriteString("for (" + flags.name + " flag : flags) {\n")
+	writeIndent(3*indent, writer)
+	writer.WriteString("mask |= flag.mask;\n")
+	writeIndent(2*indent, writer)
+	writer.WriteString("}\n")
+	writeIndent(2*indent, writer)
+	writer.WriteString("return mask;\n")
+	writeIndent(indent, writer)
+	writer.WriteString("}\n\n")
+
+	writeIndent(indent, writer)
+	writer.WriteString(modifier + "static " + setType + " fromMask(" + maskType + " mask) {\n")
+	writeIndent(2*indent, writer)
+	writer.WriteString(setType + " flags = EnumSet.noneOf(" + flags.name + ".class);\n")
+	writeIndent(2*indent, writer)
+	writer.WriteString("for (" + flags.name + " flag : values()) {\n")
+	writeIndent(3*indent, writer)
+	writer.WriteString("if ((mask & flag.mask) != 0) {\n")
+	writeIndent(4*indent, writer)
+	writer.WriteString("flags.add(flag);\n")
+	writeIndent(3*indent, writer)
+	writer.WriteString("}\n")
+	writeIndent(2*indent, writer)
+	writer.WriteString("}\n")
+	writeIndent(2*indent, writer)
+	writer.WriteString("return flags;\n")
+	writeIndent(indent, writer)
+	writer.WriteString("}\n")
+	writer.WriteString("}\n")
+}
+
+// Writes flags as an inline value class, combined with the infix 'or' function
+func (kotlin *KotlinGenerator) writeFlags(flags FlagsDecl, writer *bytes.Buffer) {
+	indent := kotlin.options.indent
+	maskType, one := "Int", "1"
+	if flags.backingType == "u64" {
+		maskType, one = "Long", "1L"
 	}
-	translateTypes(types, toRustType)
 
-	joiner := newJoiner()
-	// May require specifying mod name
-	for _, t := range types {
-		if joiner.join() {
-			writer.WriteString("\n")
+	writer.WriteString("@JvmInline\n")
+	writer.WriteString("value class " + flags.name + "(val value: " + maskType + ") {\n")
+	writeIndent(indent, writer)
+	writer.WriteString("infix fun or(other: " + flags.name + "): " + flags.name + " = " + flags.name + "(value or other.value)\n\n")
+	writeIndent(indent, writer)
+	writer.WriteString("fun has(flag: " + flags.name + "): Boolean = value and flag.value == flag.value\n")
+
+	if len(flags.members) > 0 {
+		writer.WriteString("\n")
+		writeIndent(indent, writer)
+		writer.WriteString("companion object {\n")
+		for bit, member := range flags.members {
+			writeIndent(2*indent, writer)
+			writer.WriteString(fmt.Sprintf("val %v = %v(%v shl %v)\n", member.name, flags.name, one, bit))
 		}
-		writer.WriteString("struct " + t.typeName + " {\n")
-		rust.writeFields(t.fields, writer)
+		writeIndent(indent, writer)
 		writer.WriteString("}\n")
+	}
+	writer.WriteString("}\n")
+}
 
-		if len(t.methods) > 0 {
-			writer.WriteString("impl " + t.typeName + " {\n")
-			rust.writeMethods(t, writer)
-			writer.WriteString("}\n")
-		}
+// Writes flags as a tuple struct in the style of the bitflags crate, with an implementation of BitOr
+func (rust *RustGenerator) writeFlags(flags FlagsDecl, writer *bytes.Buffer) {
+	indent := rust.options.indent
+	name := flags.name
+
+	writer.WriteString("#[derive(Clone, Copy, Debug, Default, PartialEq, Eq, Hash)]\n")
+	writer.WriteString("struct " + name + "(" + toRustType(flags.backingType) + ");\n\n")
+
+	writer.WriteString("impl " + name + " {\n")
+	for bit, member := range flags.members {
+		writeIndent(indent, writer)
+		writer.WriteString(fmt.Sprintf("const %v: %v = %v(1 << %v);\n", member.name, name, name, bit))
 	}
-	return nil
+	if len(flags.members) > 0 {
+		writer.WriteString("\n")
+	}
+	writeIndent(indent, writer)
+	writer.WriteString("fn contains(&self, other: " + name + ") -> bool {\n")
+	writeIndent(2*indent, writer)
+	writer.WriteString("self.0 & other.0 == other.0\n")
+	writeIndent(indent, writer)
+	writer.WriteString("}\n\n")
+	writeIndent(indent, writer)
+	writer.WriteString("fn insert(&mut self, other: " + name + ") {\n")
+	writeIndent(2*indent, writer)
+	writer.WriteString("self.0 |= other.0;\n")
+	writeIndent(indent, writer)
+	writer.WriteString("}\n")
+	writer.WriteString("}\n\n")
+
+	writer.WriteString("impl std::ops::BitOr for " + name + " {\n")
+	writeIndent(indent, writer)
+	writer.WriteString("type Output = " + name + ";\n\n")
+	writeIndent(indent, writer)
+	writer.WriteString("fn bitor(self, rhs: " + name + ") -> " + name + " {\n")
+	writeIndent(2*indent, writer)
+	writer.WriteString(name + "(self.0 | rhs.0)\n")
+	writeIndent(indent, writer)
+	writer.WriteString("}\n")
+	writer.WriteString("}\n")
 }
 
 func (js *JavascriptGenerator) writeMethods(methods []FuncDecl, writer *bytes.Buffer) {
 	indent := js.options.indent
 	for _, fn := range methods {
+		if needsJSDoc(fn.fields) || fn.returnTuple != nil {
+			js.writeJSDoc(fn.fields, fn.returnTuple, writer)
+		}
+
 		writeIndent(indent, writer)
 		writer.WriteString(fn.name + "(")
 
@@ -244,11 +1029,85 @@ func (js *JavascriptGenerator) writeMethods(methods []FuncDecl, writer *bytes.Bu
 		// TODO: optionally generate TODO("unimplemented")
 		writer.WriteString(") {}\n")
 	}
-	writer.WriteString("}\n")
+}
+
+// JSDoc is only written where plain JavaScript would lose the structure of the types,
+// which is the case for tuples and function types.
+func needsJSDoc(fields []Field) bool {
+	for _, field := range fields {
+		if field.hasModifier(FIELD_TUPLE) || field.hasModifier(FIELD_FUNCTION) {
+			return true
+		}
+	}
+	return false
+}
+
+// Writes callback typedefs describing function types, which are referenced by name from other JSDoc comments
+func (js *JavascriptGenerator) writeCallbacks(t TypeDecl, writer *bytes.Buffer) {
+	for _, fn := range collectGeneratedTypes(t, true) {
+		if !fn.hasModifier(FIELD_FUNCTION) {
+			continue
+		}
+
+		writer.WriteString("/**\n * @callback " + fn.generatedName + "\n")
+		for i, param := range fn.elements {
+			writer.WriteString(fmt.Sprintf(" * @param {%v} arg%v\n", js.jsDocType(param), i))
+		}
+		if fn.result != nil {
+			writer.WriteString(" * @returns {" + js.jsDocType(*fn.result) + "}\n")
+		}
+		writer.WriteString(" */\n")
+	}
+}
+
+func (js *JavascriptGenerator) writeJSDoc(params []Field, returnTuple *Field, writer *bytes.Buffer) {
+	indent := js.options.indent
+	writeIndent(indent, writer)
+	writer.WriteString("/**\n")
+	for _, param := range params {
+		writeIndent(indent, writer)
+		writer.WriteString(" * @param {" + js.jsDocType(param) + "} " + param.varName + "\n")
+	}
+	if returnTuple != nil {
+		writeIndent(indent, writer)
+		writer.WriteString(" * @returns {" + js.jsDocType(*returnTuple) + "}\n")
+	}
+	writeIndent(indent, writer)
+	writer.WriteString(" */\n")
+}
+
+func (js *JavascriptGenerator) jsDocType(field Field) string {
+	typeName := toJSDocType(field.typeName)
+	if field.hasModifier(FIELD_TUPLE) {
+		elements := make([]string, len(field.elements))
+		for i, element := range field.elements {
+			elements[i] = js.jsDocType(element)
+		}
+		typeName = "[" + strings.Join(elements, ", ") + "]"
+	} else if field.hasModifier(FIELD_FUNCTION) {
+		typeName = field.generatedName
+	}
+
+	if field.hasModifier(FIELD_NULLABLE) {
+		typeName = "?" + typeName
+		if field.hasModifier(FIELD_ARRAY) {
+			typeName = "(" + typeName + ")"
+		}
+	}
+
+	if field.hasModifier(FIELD_ARRAY) {
+		typeName += "[]"
+	}
+	return typeName
 }
 
 func (js *JavascriptGenerator) writeConstructor(fields []Field, writer *bytes.Buffer) {
 	indent := js.options.indent
+	if needsJSDoc(fields) {
+		js.writeJSDoc(fields, nil, writer)
+	}
+
+	writeIndent(indent, writer)
 	writer.WriteString("constructor(")
 	joiner := newJoiner()
 	for _, field := range fields {
@@ -281,23 +1140,190 @@ func (goGen *GoGenerator) writeField(field Field, inType bool, writer *bytes.Buf
 	if inType && goGen.options.jsonAnnotations {
 		varName = capitalizeFirstLetter(varName)
 	}
-	writer.WriteString(varName + " ")
-	if field.hasModifier(FIELD_ARRAY) {
-		writer.WriteString("[]")
-	}
-	writer.WriteString(field.typeName)
+	writer.WriteString(varName + " " + goGen.typeString(field))
 	if inType && goGen.options.jsonAnnotations {
 		snakeCase := toSnakeCase(field.varName)
 		writer.WriteString(" `json:\"" + snakeCase + "\"`")
 	}
 }
 
+func (goGen *GoGenerator) typeString(field Field) string {
+	typeName := field.typeName
+	if field.hasModifier(FIELD_TUPLE) {
+		typeName = field.generatedName
+	} else if field.hasModifier(FIELD_FUNCTION) {
+		typeName = "func(" + goGen.typeList(field.elements) + ")"
+		if field.result != nil && field.result.hasModifier(FIELD_TUPLE) {
+			typeName += " (" + goGen.typeList(field.result.elements) + ")"
+		} else if field.result != nil {
+			typeName += " " + goGen.typeString(*field.result)
+		}
+	}
+
+	if field.hasModifier(FIELD_BOXED) {
+		typeName = "*" + typeName
+	}
+
+	if field.hasModifier(FIELD_ARRAY) {
+		return "[]" + typeName
+	}
+	return typeName
+}
+
+func (goGen *GoGenerator) typeList(fields []Field) string {
+	types := make([]string, len(fields))
+	for i, field := range fields {
+		types[i] = goGen.typeString(field)
+	}
+	return strings.Join(types, ", ")
+}
+
+// Writes structs standing in for tuples, except for the returned ones, which are expressed as multiple return values
+func (goGen *GoGenerator) writeTuples(t TypeDecl, writer *bytes.Buffer) {
+	indent := goGen.options.indent
+	for _, tuple := range collectGeneratedTypes(t, false) {
+		if !tuple.hasModifier(FIELD_TUPLE) {
+			continue
+		}
+
+		writer.WriteString("\ntype " + tuple.generatedName + " struct {\n")
+		for i, element := range tuple.elements {
+			writeIndent(indent, writer)
+			writer.WriteString(fmt.Sprintf("Item%v %v\n", i, goGen.typeString(element)))
+		}
+		writer.WriteString("}\n")
+	}
+}
+
 func (java *JavaGenerator) writeField(field Field, writer *bytes.Buffer) {
-	writer.WriteString(field.typeName)
+	writer.WriteString(java.typeString(field) + " " + field.varName)
+}
+
+func (java *JavaGenerator) typeString(field Field) string {
+	typeName := field.typeName
+	if field.hasModifier(FIELD_TUPLE) {
+		typeName = field.generatedName
+	} else if field.hasModifier(FIELD_FUNCTION) {
+		typeName = java.functionType(field)
+	}
+
 	if field.hasModifier(FIELD_ARRAY) {
-		writer.WriteString("[]")
+		return typeName + "[]"
+	}
+	return typeName
+}
+
+// Function types are represented with the interfaces from java.util.function where one fits the signature,
+// otherwise with a generated functional interface.
+func (java *JavaGenerator) functionType(field Field) string {
+	interfaceName := javaFunctionalInterface(field)
+	if interfaceName == "" {
+		return field.generatedName
+	}
+
+	typeArgs := make([]string, 0, len(field.elements)+1)
+	for _, param := range field.elements {
+		typeArgs = append(typeArgs, toJavaBoxedType(java.typeString(param)))
+	}
+	if field.result != nil && !strings.HasSuffix(interfaceName, "Predicate") {
+		typeArgs = append(typeArgs, toJavaBoxedType(java.typeString(*field.result)))
+	}
+
+	if len(typeArgs) == 0 {
+		return interfaceName
+	}
+	return interfaceName + "<" + strings.Join(typeArgs, ", ") + ">"
+}
+
+// Returns the name of the java.util.function interface (or Runnable) matching the function type,
+// or an empty string when there is none.
+func javaFunctionalInterface(field Field) string {
+	if len(field.elements) > 2 {
+		return ""
+	}
+
+	result := field.result
+	isPredicate := result != nil && result.typeName == "boolean" && !result.hasModifier(FIELD_ARRAY)
+
+	prefix := []string{"", "", "Bi"}[len(field.elements)]
+	switch {
+	case len(field.elements) == 0 && result == nil:
+		return "Runnable"
+	case len(field.elements) == 0:
+		return "Supplier"
+	case result == nil:
+		return prefix + "Consumer"
+	case isPredicate:
+		return prefix + "Predicate"
+	default:
+		return prefix + "Function"
+	}
+}
+
+// Returns imports of the java.util.function interfaces used by the types, sorted by name
+func (java *JavaGenerator) functionImports(types []TypeDecl) []string {
+	imports := make([]string, 0)
+	for _, t := range types {
+		for _, fn := range collectGeneratedTypes(t, true) {
+			if !fn.hasModifier(FIELD_FUNCTION) {
+				continue
+			}
+
+			interfaceName := javaFunctionalInterface(fn)
+			if interfaceName == "" || interfaceName == "Runnable" {
+				continue
+			}
+
+			imported := "java.util.function." + interfaceName
+			if !slices.Contains(imports, imported) {
+				imports = append(imports, imported)
+			}
+		}
+	}
+
+	slices.Sort(imports)
+	return imports
+}
+
+// Writes records standing in for tuples and functional interfaces standing in for function types,
+// which don't fit any of the standard ones.
+func (java *JavaGenerator) writeGeneratedTypes(t TypeDecl, writer *bytes.Buffer) {
+	indent := java.options.indent
+	for _, tuple := range collectGeneratedTypes(t, true) {
+		if tuple.hasModifier(FIELD_FUNCTION) {
+			if javaFunctionalInterface(tuple) != "" {
+				continue
+			}
+
+			result := "void"
+			if tuple.result != nil {
+				result = java.typeString(*tuple.result)
+			}
+
+			writer.WriteString("\n@FunctionalInterface\ninterface " + tuple.generatedName + " {\n")
+			writeIndent(indent, writer)
+			writer.WriteString(result + " apply(")
+			joiner := newJoiner()
+			for i, param := range tuple.elements {
+				if joiner.join() {
+					writer.WriteString(", ")
+				}
+				writer.WriteString(fmt.Sprintf("%v arg%v", java.typeString(param), i))
+			}
+			writer.WriteString(");\n}\n")
+			continue
+		}
+
+		writer.WriteString("\nrecord " + tuple.generatedName + "(")
+		joiner := newJoiner()
+		for i, element := range tuple.elements {
+			if joiner.join() {
+				writer.WriteString(", ")
+			}
+			writer.WriteString(fmt.Sprintf("%v item%v", java.typeString(element), i))
+		}
+		writer.WriteString(") {}\n")
 	}
-	writer.WriteString(" " + field.varName)
 }
 
 func (kotlin *KotlinGenerator) writeField(field Field, writer *bytes.Buffer) {
@@ -306,34 +1332,75 @@ func (kotlin *KotlinGenerator) writeField(field Field, writer *bytes.Buffer) {
 	} else {
 		writer.WriteString("var ")
 	}
-	writer.WriteString(field.varName + ": ")
-	isList := field.hasModifier(FIELD_ARRAY)
-	if isList {
-		writer.WriteString("List<")
+	writer.WriteString(field.varName + ": " + kotlin.typeString(field))
+}
+
+func (kotlin *KotlinGenerator) writeMethodArgument(field Field, writer *bytes.Buffer) {
+	writer.WriteString(field.varName + ": " + kotlin.typeString(field))
+}
+
+// Pairs and triples are represented with the standard library types, larger tuples with generated data classes
+func (kotlin *KotlinGenerator) typeString(field Field) string {
+	typeName := field.typeName
+	if field.hasModifier(FIELD_TUPLE) {
+		elements := make([]string, len(field.elements))
+		for i, element := range field.elements {
+			elements[i] = kotlin.typeString(element)
+		}
+
+		switch len(elements) {
+		case 2:
+			typeName = "Pair<" + strings.Join(elements, ", ") + ">"
+		case 3:
+			typeName = "Triple<" + strings.Join(elements, ", ") + ">"
+		default:
+			typeName = field.generatedName
+		}
+	} else if field.hasModifier(FIELD_FUNCTION) {
+		params := make([]string, len(field.elements))
+		for i, param := range field.elements {
+			params[i] = kotlin.typeString(param)
+		}
+
+		result := "Unit"
+		if field.result != nil {
+			result = kotlin.typeString(*field.result)
+		}
+		typeName = "(" + strings.Join(params, ", ") + ") -> " + result
+
+		if field.hasModifier(FIELD_NULLABLE) {
+			typeName = "(" + typeName + ")"
+		}
 	}
-	writer.WriteString(field.typeName)
+
 	if field.hasModifier(FIELD_NULLABLE) {
-		writer.WriteString("?")
+		typeName += "?"
 	}
 
-	if isList {
-		writer.WriteString(">")
+	if field.hasModifier(FIELD_ARRAY) {
+		return "List<" + typeName + ">"
 	}
+	return typeName
 }
 
-func (kotlin *KotlinGenerator) writeMethodArgument(field Field, writer *bytes.Buffer) {
-	writer.WriteString(field.varName + ": ")
-	isList := field.hasModifier(FIELD_ARRAY)
-	if isList {
-		writer.WriteString("List<")
-	}
-	writer.WriteString(field.typeName)
-	if field.hasModifier(FIELD_NULLABLE) {
-		writer.WriteString("?")
-	}
+// Writes data classes standing in for tuples with more than three elements
+func (kotlin *KotlinGenerator) writeTuples(t TypeDecl, writer *bytes.Buffer) {
+	indent := kotlin.options.indent
+	for _, tuple := range collectGeneratedTypes(t, true) {
+		if !tuple.hasModifier(FIELD_TUPLE) || len(tuple.elements) <= 3 {
+			continue
+		}
 
-	if isList {
-		writer.WriteString(">")
+		writer.WriteString("\ndata class " + tuple.generatedName + "(\n")
+		joiner := newJoiner()
+		for i, element := range tuple.elements {
+			if joiner.join() {
+				writer.WriteString(",\n")
+			}
+			writeIndent(indent, writer)
+			writer.WriteString(fmt.Sprintf("val item%v: %v", i, kotlin.typeString(element)))
+		}
+		writer.WriteString("\n)\n")
 	}
 }
 
@@ -349,17 +1416,42 @@ func (rust *RustGenerator) writeFields(fields []Field, writer *bytes.Buffer) {
 }
 
 func (rust *RustGenerator) writeFieldType(field Field, writer *bytes.Buffer) {
-	isList := field.hasModifier(FIELD_ARRAY)
-	if isList {
-		writer.WriteString("Vec<")
+	writer.WriteString(rust.typeString(field))
+}
+
+func (rust *RustGenerator) typeString(field Field) string {
+	typeName := field.typeName
+	if field.hasModifier(FIELD_TUPLE) {
+		elements := make([]string, len(field.elements))
+		for i, element := range field.elements {
+			elements[i] = rust.typeString(element)
+		}
+		typeName = "(" + strings.Join(elements, ", ") + ")"
+	} else if field.hasModifier(FIELD_FUNCTION) {
+		params := make([]string, len(field.elements))
+		for i, param := range field.elements {
+			params[i] = rust.typeString(param)
+		}
+
+		typeName = "Fn(" + strings.Join(params, ", ") + ")"
+		if field.result != nil {
+			typeName += " -> " + rust.typeString(*field.result)
+		}
+		typeName = "Box<dyn " + typeName + ">"
 	}
-	writer.WriteString(field.typeName)
+
+	if field.hasModifier(FIELD_BOXED) {
+		typeName = "Box<" + typeName + ">"
+	}
+
 	if field.hasModifier(FIELD_NULLABLE) {
-		writer.WriteString("?")
+		typeName = "Option<" + typeName + ">"
 	}
-	if isList {
-		writer.WriteString(">")
+
+	if field.hasModifier(FIELD_ARRAY) {
+		return "Vec<" + typeName + ">"
 	}
+	return typeName
 }
 
 func (goGen *GoGenerator) writeMethods(typeDecl TypeDecl, writer *bytes.Buffer) {
@@ -377,7 +1469,9 @@ func (goGen *GoGenerator) writeMethods(typeDecl TypeDecl, writer *bytes.Buffer)
 			goGen.writeField(field, false, writer)
 		}
 		writer.WriteString(") ")
-		if fn.returnType != "" {
+		if fn.returnTuple != nil {
+			writer.WriteString("(" + goGen.typeList(fn.returnTuple.elements) + ") ")
+		} else if fn.returnType != "" {
 			writer.WriteString(fn.returnType + " ")
 		}
 		writer.WriteString("{\n")
@@ -400,7 +1494,9 @@ func (kotlin *KotlinGenerator) writeMethods(typeDecl TypeDecl, writer *bytes.Buf
 			kotlin.writeMethodArgument(field, writer)
 		}
 		writer.WriteString(")")
-		if fn.returnType != "" {
+		if fn.returnTuple != nil {
+			writer.WriteString(": " + kotlin.typeString(*fn.returnTuple))
+		} else if fn.returnType != "" {
 			// For now it's not possible to return lists
 			writer.WriteString(": " + fn.returnType)
 		}
@@ -424,7 +1520,9 @@ func (rust *RustGenerator) writeMethods(typeDecl TypeDecl, writer *bytes.Buffer)
 			rust.writeFieldType(field, writer)
 		}
 		writer.WriteString(") ")
-		if fn.returnType != "" {
+		if fn.returnTuple != nil {
+			writer.WriteString("-> " + rust.typeString(*fn.returnTuple) + " ")
+		} else if fn.returnType != "" {
 			// For now it's not possible to return lists
 			writer.WriteString("-> " + fn.returnType + " ")
 		}
@@ -460,6 +1558,20 @@ func capitalizeFirstLetter(str string) string {
 	return string(r) + str[size:]
 }
 
+// toPascalCase joins underscore separated words, capitalizing the first letter of each one.
+// Words written entirely in uppercase are lowercased first, for example GROUP_READ becomes GroupRead.
+func toPascalCase(str string) string {
+	pascalCase := strings.Builder{}
+	for _, word := range strings.Split(str, "_") {
+		if strings.ToUpper(word) == word {
+			word = strings.ToLower(word)
+		}
+		pascalCase.WriteString(capitalizeFirstLetter(word))
+	}
+
+	return pascalCase.String()
+}
+
 func isASCIILetter(r rune) bool {
 	return r <= 127 && unicode.IsLetter(r)
 }
@@ -471,18 +1583,15 @@ func (java *JavaGenerator) writeFields(fields []Field, writer *bytes.Buffer) {
 		if field.hasModifier(FIELD_CONST) {
 			writer.WriteString("final ")
 		}
-		writer.WriteString(field.typeName)
-		if field.hasModifier(FIELD_ARRAY) {
-			writer.WriteString("[]")
-		}
-		writer.WriteString(" " + field.varName + ";\n")
+		java.writeField(field, writer)
+		writer.WriteString(";\n")
 	}
 }
 
-func (java *JavaGenerator) writeConstructor(t TypeDecl, writer *bytes.Buffer) {
+func (java *JavaGenerator) writeConstructor(t TypeDecl, modifier string, writer *bytes.Buffer) {
 	indent := java.options.indent
 	writeIndent(indent, writer)
-	writer.WriteString(t.typeName + "(")
+	writer.WriteString(modifier + t.typeName + "(")
 	join := newJoiner()
 	for _, field := range t.fields {
 		if join.join() {
@@ -515,11 +1624,15 @@ func (kotlin *KotlinGenerator) writeConstructor(t TypeDecl, writer *bytes.Buffer
 	writer.WriteString("\n)")
 }
 
-func (java *JavaGenerator) writeMethods(typeDecl TypeDecl, writer *bytes.Buffer) {
+func (java *JavaGenerator) writeMethods(typeDecl TypeDecl, modifier string, writer *bytes.Buffer) {
 	indent := java.options.indent
 	for _, fn := range typeDecl.methods {
 		writeIndent(indent, writer)
-		writer.WriteString(fn.returnType + " " + fn.name)
+		returnType := fn.returnType
+		if fn.returnTuple != nil {
+			returnType = fn.returnTuple.generatedName
+		}
+		writer.WriteString(modifier + returnType + " " + fn.name)
 
 		writer.WriteByte('(')
 		fieldJoiner := newJoiner()
@@ -559,20 +1672,84 @@ func (j *Joiner) reset() {
 	j.firstCall = true
 }
 
-func writeIndent(indent int, writer *bytes.Buffer) {
-	for i := 0; i < indent; i++ {
-		writer.WriteByte(' ')
+// Writes the contents of code blocks verbatim, each followed by a newline. Only the rest of the line opening a block
+// and the line closing it are dropped when they're blank, so whitespace within the code (such as in raw strings)
+// is kept as written. The one exception is a block written on a single line, which is trimmed of the spaces
+// separating it from its delimiters and indented to match the generated output.
+func writeCodeBlocks(codeBlocks []CodeBlock, indent int, writer *bytes.Buffer) {
+	for _, codeBlock := range codeBlocks {
+		code := codeBlock.code
+		if !strings.Contains(code, "\n") {
+			code = strings.TrimSpace(code)
+			if code != "" {
+				writeIndent(indent, writer)
+			}
+		} else {
+			if first, rest, _ := strings.Cut(code, "\n"); strings.TrimSpace(first) == "" {
+				code = rest
+			}
+			if end := strings.LastIndex(code, "\n"); end >= 0 && strings.TrimSpace(code[end+1:]) == "" {
+				code = code[:end]
+			}
+		}
+
+		if code != "" {
+			writer.WriteString(code)
+			writer.WriteString("\n")
+		}
 	}
 }
 
-func openWriter(filename string) *bufio.Writer {
-	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
-	if err != nil {
-		fmt.Println("Error opening file:", err)
-		return nil
+// Writes file level code blocks placed before all types, followed by an empty line. When every declaration is written
+// to a file of its own, the blocks are written only to the file of the first one, so that they aren't repeated.
+func writeHeaderCodeBlocks(resolved *Schema, declaration string, writer *bytes.Buffer) {
+	first := ""
+	if len(resolved.flags) > 0 {
+		first = resolved.flags[0].name
+	} else if len(resolved.types) > 0 {
+		first = resolved.types[0].typeName
+	}
+	if declaration != "" && declaration != first {
+		return
+	}
+
+	header := codeBlocksAt(resolved.codeBlocks, 0)
+	if len(header) > 0 {
+		writeCodeBlocks(header, 0, writer)
+		writer.WriteString("\n")
+	}
+}
+
+// Writes file level code blocks placed after the type with the given index, preceded by an empty line.
+func writeTrailingCodeBlocks(codeBlocks []CodeBlock, typeIndex int, writer *bytes.Buffer) {
+	trailing := codeBlocksAt(codeBlocks, typeIndex+1)
+	if len(trailing) > 0 {
+		writer.WriteString("\n")
+		writeCodeBlocks(trailing, 0, writer)
+	}
+}
+
+// Tells whether the flags or type declaration is written to a file, which holds either every declaration
+// (when declaration is empty) or only the one named by declaration
+func writesDeclaration(declaration string, name string) bool {
+	return declaration == "" || declaration == name
+}
+
+func codeBlocksAt(codeBlocks []CodeBlock, position int) []CodeBlock {
+	result := make([]CodeBlock, 0)
+	for _, codeBlock := range codeBlocks {
+		if codeBlock.position == position {
+			result = append(result, codeBlock)
+		}
 	}
 
-	return bufio.NewWriter(file)
+	return result
+}
+
+func writeIndent(indent int, writer *bytes.Buffer) {
+	for i := 0; i < indent; i++ {
+		writer.WriteByte(' ')
+	}
 }
 
 // toSnakeCase separates uppercase letters with underscores, except for three or more adjacent ones.
@@ -671,6 +1848,30 @@ func toJavaType(typeName string) string {
 	}
 }
 
+// Generic type arguments in Java cannot be primitive types
+func toJavaBoxedType(typeName string) string {
+	switch typeName {
+	case "byte":
+		return "Byte"
+	case "short":
+		return "Short"
+	case "int":
+		return "Integer"
+	case "long":
+		return "Long"
+	case "float":
+		return "Float"
+	case "double":
+		return "Double"
+	case "char":
+		return "Character"
+	case "boolean":
+		return "Boolean"
+	default:
+		return typeName
+	}
+}
+
 func toKotlinType(typeName string) string {
 	switch typeName {
 	case "i8", "u8":
@@ -696,6 +1897,19 @@ func toKotlinType(typeName string) string {
 	}
 }
 
+func toJSDocType(typeName string) string {
+	switch typeName {
+	case "i8", "i16", "i32", "i64", "u8", "u16", "u32", "u64", "f32", "f64":
+		return "number"
+	case "string", "char":
+		return "string"
+	case "bool":
+		return "boolean"
+	default:
+		return typeName
+	}
+}
+
 func toRustType(typeName string) string {
 	switch typeName {
 	case "string":
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"strings"
 	"testing"
 )
@@ -57,7 +58,7 @@ func TestJSGen(t *testing.T) {
 	buffer := bytes.Buffer{}
 
 	js := JavascriptGenerator{defaultOptions()}
-	js.generate([]TypeDecl{catDecl}, &buffer)
+	js.write(&Schema{types: []TypeDecl{catDecl}}, "", &buffer)
 
 	output := buffer.String()
 	t.Log("\n" + output)
@@ -229,3 +230,403 @@ func compareLines(expectedLines []string, lines []string, t *testing.T) {
 		}
 	}
 }
+
+func TestTargetAttributes(t *testing.T) {
+	source := `
+@except(js)
+type Audit {
+    u64 at;
+}
+
+type Event {
+    @name(kotlin="kind") string object;
+    @only(go, rust) Audit audit;
+    @only(go) func flush();
+}
+`
+	parser, result := parseAndTypecheck(t, source)
+	if !result.success {
+		t.Fatal(result.message)
+	}
+	schema := parser.Schema()
+
+	buffer := bytes.Buffer{}
+	kotlin := KotlinGenerator{defaultOptions()}
+	err := kotlin.write(&schema, "", &buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := buffer.String()
+	if !strings.Contains(output, "var kind: String") {
+		t.Errorf("Expected field 'object' to be renamed to 'kind' in Kotlin:\n%v", output)
+	}
+	if strings.Contains(output, "audit") || strings.Contains(output, "flush") {
+		t.Errorf("Expected Go and Rust only declarations to be dropped in Kotlin:\n%v", output)
+	}
+
+	buffer.Reset()
+	goGen := GoGenerator{defaultOptions()}
+	err = goGen.write(&schema, "", &buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output = buffer.String()
+	if !strings.Contains(output, "object string") || !strings.Contains(output, "audit Audit") || !strings.Contains(output, "flush()") {
+		t.Errorf("Expected all Go declarations to be present:\n%v", output)
+	}
+
+	buffer.Reset()
+	js := JavascriptGenerator{defaultOptions()}
+	js.write(&schema, "", &buffer)
+	if strings.Contains(buffer.String(), "Audit") {
+		t.Errorf("Expected type Audit to be dropped in JavaScript:\n%v", buffer.String())
+	}
+}
+
+func TestTargetReferenceToExcludedType(t *testing.T) {
+	source := "@only(go) type Audit { u64 at; }\ntype Event { Audit audit; }\n"
+	_, result := parseAndTypecheck(t, source)
+	if result.success {
+		t.Fatal("Expected reference to a type excluded from some targets to be reported")
+	}
+}
+
+func TestTargetNameCollisions(t *testing.T) {
+	tests := []struct {
+		source   string
+		code     DiagnosticCode
+		expected string
+	}{
+		{"type B {\n    @name(go=\"y\") u32 x;\n    u32 y;\n}\n", CODE_REDECLARED_FIELD, "Field 'y' was declared multiple times for target(s): go."},
+		{"type B {\n    @name(rust=\"g\", js=\"g\") func f();\n    func g();\n}\n", CODE_REDECLARED_METHOD, "Method 'B::g' was declared multiple times for target(s): js, rust."},
+		{"type A {}\n@name(kotlin=\"A\") type B {}\n", CODE_REDECLARED_TYPE, "Type 'A' was declared multiple times for target(s): kotlin."},
+	}
+
+	for _, test := range tests {
+		parser, result := parseAndTypecheck(t, test.source)
+		if result.success || len(parser.diagnostics) != 1 {
+			t.Errorf("Expected a single redeclaration in %q, found:\n%v", test.source, result.message)
+			continue
+		}
+
+		diagnostic := parser.diagnostics[0]
+		if diagnostic.code != test.code || diagnostic.message != test.expected || len(diagnostic.related) != 1 {
+			t.Errorf("Expected '%v' with the first declaration as a related location, found: %v", test.expected, diagnostic.Error())
+		}
+	}
+
+	// Renames are compared only for targets both declarations are generated for
+	source := "type B {\n    @only(go) @name(go=\"y\") u32 x;\n    @except(go) u32 y;\n}\n"
+	if _, result := parseAndTypecheck(t, source); !result.success {
+		t.Errorf("Expected no collision between fields of disjoint targets:\n%v", result.message)
+	}
+}
+
+func TestTargetNameCases(t *testing.T) {
+	source := "flags access_mode : u8 {\n    READ;\n}\ntype pet_shop {\n    u32 owner_id;\n    @name(kotlin=\"SKU\") string sku_code;\n    access_mode mode;\n    func open_at(u8 opening_hour);\n}\n"
+	parser, result := parseAndTypecheck(t, source)
+	if !result.success {
+		t.Fatal(result.message)
+	}
+	schema := parser.Schema()
+
+	options := defaultOptions()
+	options.typeCase = CASE_PASCAL
+	options.fieldCase = CASE_CAMEL
+	buffer := bytes.Buffer{}
+	kotlin := KotlinGenerator{options}
+	err := kotlin.write(&schema, "", &buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := buffer.String()
+	for _, expected := range []string{"class PetShop", "var ownerId: Int", "var SKU: String", "var mode: AccessMode", "openingHour: Byte"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected '%v' in:\n%v", expected, output)
+		}
+	}
+
+	// Names converted to the same case collide in the generated code
+	parser, result = parseAndTypecheck(t, "type Order {\n    u32 itemCount;\n    u32 item_count;\n}\n")
+	if !result.success {
+		t.Fatal(result.message)
+	}
+	schema = parser.Schema()
+
+	buffer.Reset()
+	err = kotlin.write(&schema, "", &buffer)
+	var diagnostic Diagnostic
+	if !errors.As(err, &diagnostic) || diagnostic.code != CODE_NAME_COLLISION || diagnostic.start.number != 3 || len(diagnostic.related) != 1 {
+		t.Errorf("Expected field 'item_count' to collide with 'itemCount', found %v", err)
+	}
+}
+
+func TestGoCodeBlocks(t *testing.T) {
+	source := `%go{ import "time" }%
+%rust{ use std::time::Instant; }%
+
+type Session {
+    u64 started;
+    %go{
+        Deadline time.Time
+    }%
+}
+
+%go{
+func (session *Session) Expired() bool {
+    return time.Now().After(session.Deadline)
+}
+}%
+`
+	parser, result := parseAndTypecheck(t, source)
+	if !result.success {
+		t.Fatal(result.message)
+	}
+	schema := parser.Schema()
+
+	buffer := bytes.Buffer{}
+	goGen := GoGenerator{defaultOptions()}
+	err := goGen.write(&schema, "", &buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `package main
+
+import "time"
+
+type Session struct {
+    started uint64
+        Deadline time.Time
+}
+
+func (session *Session) Expired() bool {
+    return time.Now().After(session.Deadline)
+}
+`
+	// Code is copied verbatim, without re-indenting it
+	if buffer.String() != expected {
+		t.Errorf("Expected:\n%v\nGot:\n%v", expected, buffer.String())
+	}
+}
+
+func TestGoFlags(t *testing.T) {
+	source := "flags Perm : u8 { READ; WRITE; GROUP_EXEC; }\ntype File { Perm perm; }\n"
+	parser, result := parseAndTypecheck(t, source)
+	if !result.success {
+		t.Fatal(result.message)
+	}
+	schema := parser.Schema()
+
+	buffer := bytes.Buffer{}
+	goGen := GoGenerator{defaultOptions()}
+	err := goGen.write(&schema, "", &buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := buffer.String()
+	t.Log("\n" + output)
+	lines := strings.Split(output, "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSpace(lines[i])
+	}
+
+	expectedLines := []string{
+		"package main",
+		"",
+		"type Perm uint8",
+		"",
+		"const (",
+		"PermRead Perm = 1 << iota",
+		"PermWrite",
+		"PermGroupExec",
+		")",
+		"",
+		"func (this Perm) Has(flag Perm) bool {",
+		"return this&flag == flag",
+		"}",
+		"",
+		"func (this *Perm) Set(flag Perm) {",
+		"*this |= flag",
+		"}",
+		"",
+		"type File struct {",
+		"perm Perm",
+		"}",
+		"",
+	}
+
+	compareLines(expectedLines, lines, t)
+}
+
+func TestJavaFlagsAsEnumSet(t *testing.T) {
+	source := "flags Perm : u64 { READ; }\ntype File { Perm perm; }\n"
+	parser, result := parseAndTypecheck(t, source)
+	if !result.success {
+		t.Fatal(result.message)
+	}
+	schema := parser.Schema()
+
+	buffer := bytes.Buffer{}
+	java := JavaGenerator{defaultOptions()}
+	err := java.write(&schema, "", &buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := buffer.String()
+	if !strings.HasPrefix(output, "import java.util.EnumSet;") {
+		t.Errorf("Expected EnumSet to be imported:\n%v", output)
+	}
+	if !strings.Contains(output, "final long mask = 1L << ordinal();") || !strings.Contains(output, "EnumSet<Perm> perm;") {
+		t.Errorf("Expected 64-bit masks and EnumSet field:\n%v", output)
+	}
+}
+
+func TestJavaRecords(t *testing.T) {
+	source := "type Cat {\n    const string name;\n    u32 age;\n    func meow(u32 volume) string;\n}\n"
+	parser, result := parseAndTypecheck(t, source)
+	if !result.success {
+		t.Fatal(result.message)
+	}
+	schema := parser.Schema()
+
+	options := defaultOptions()
+	options.records = true
+	buffer := bytes.Buffer{}
+	java := JavaGenerator{options}
+	err := java.write(&schema, "", &buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "record Cat(String name, int age) {\n" +
+		"    String meow(int volume) {\n" +
+		"        throw new RuntimeException(\"TODO: Unimplemented method\");\n" +
+		"    }\n" +
+		"}\n"
+	if buffer.String() != expected {
+		t.Errorf("Expected:\n%v\nfound:\n%v", expected, buffer.String())
+	}
+}
+
+func TestToPascalCase(t *testing.T) {
+	inputs := []string{"READ", "GROUP_READ", "readOnly", "a_b"}
+	expected := []string{"Read", "GroupRead", "ReadOnly", "AB"}
+	for i, input := range inputs {
+		actual := toPascalCase(input)
+		if actual != expected[i] {
+			t.Error("Input: ", input, "  Expected:", expected[i], "  Got:", actual)
+		}
+	}
+}
+
+func TestGoTuples(t *testing.T) {
+	source := "type Car {\n    (u32, string) position;\n    func locate() (u32, string);\n}\n"
+	parser, result := parseAndTypecheck(t, source)
+	if !result.success {
+		t.Fatal(result.message)
+	}
+	schema := parser.Schema()
+
+	buffer := bytes.Buffer{}
+	goGen := GoGenerator{defaultOptions()}
+	err := goGen.write(&schema, "", &buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := buffer.String()
+	t.Log("\n" + output)
+	lines := strings.Split(output, "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSpace(lines[i])
+	}
+
+	expectedLines := []string{
+		"package main",
+		"",
+		"type Car struct {",
+		"position CarPosition",
+		"}",
+		"func (this *Car) locate() (uint32, string) {",
+		"panic(\"TODO: Unimplemented method\")",
+		"}",
+		"",
+		"type CarPosition struct {",
+		"Item0 uint32",
+		"Item1 string",
+		"}",
+		"",
+	}
+
+	compareLines(expectedLines, lines, t)
+
+	// Generating must not modify the parsed declarations
+	if parser.structs[0].fields[0].elements[0].typeName != "u32" {
+		t.Errorf("Expected tuple elements of the parsed declarations to remain untranslated")
+	}
+}
+
+func TestFunctionTypeStrings(t *testing.T) {
+	source := "type Widget {\n    fn(string, u32) bool onChange;\n    fn(u32) (u32, string) split;\n    fn(u8, u8, u8) onMix;\n}\n"
+	parser, result := parseAndTypecheck(t, source)
+	if !result.success {
+		t.Fatal(result.message)
+	}
+	schema := parser.Schema()
+
+	expectations := map[string][]string{
+		"go":     {"func(string, uint32) bool", "func(uint32) (uint32, string)", "func(uint8, uint8, uint8)"},
+		"java":   {"BiPredicate<String, Integer>", "Function<Integer, WidgetSplitResult>", "WidgetOnMix"},
+		"kotlin": {"(String, Int) -> Boolean", "(Int) -> Pair<Int, String>", "(Byte, Byte, Byte) -> Unit"},
+		"rust":   {"Box<dyn Fn(String, u32) -> bool>", "Box<dyn Fn(u32) -> (u32, String)>", "Box<dyn Fn(u8, u8, u8)>"},
+	}
+
+	for name, expected := range expectations {
+		generator := findGenerator(name, defaultOptions())
+		resolved := resolveTarget(&schema, generator.language(), defaultOptions())
+		types := translateTypes(resolved.types, generator.mapType)
+
+		for i, field := range types[0].fields {
+			if actual := generator.typeString(field); actual != expected[i] {
+				t.Errorf("[%v] Expected type of '%v' to be '%v', found '%v'", name, field.varName, expected[i], actual)
+			}
+		}
+	}
+}
+
+func TestRustNullableTypeStrings(t *testing.T) {
+	parser, result := parseAndTypecheck(t, "type Owner {}\ntype Cat {\n    Owner? owner;\n    [string?] names;\n    fn(u32?) u8? pick;\n}\n")
+	if !result.success {
+		t.Fatal(result.message)
+	}
+	schema := parser.Schema()
+
+	rust := RustGenerator{defaultOptions()}
+	types := translateTypes(resolveTarget(&schema, RUST, defaultOptions()).types, rust.mapType)
+	expected := []string{"Option<Owner>", "Vec<Option<String>>", "Box<dyn Fn(Option<u32>) -> Option<u8>>"}
+	for i, field := range types[1].fields {
+		if actual := rust.typeString(field); actual != expected[i] {
+			t.Errorf("Expected type of '%v' to be '%v', found '%v'", field.varName, expected[i], actual)
+		}
+	}
+}
+
+func TestBoxedTypeStrings(t *testing.T) {
+	field := Field{varName: "next", typeName: "Node", modifiers: FIELD_BOXED}
+
+	goGen := GoGenerator{defaultOptions()}
+	if actual := goGen.typeString(field); actual != "*Node" {
+		t.Errorf("[go] Expected '*Node', found '%v'", actual)
+	}
+
+	rust := RustGenerator{defaultOptions()}
+	if actual := rust.typeString(field); actual != "Box<Node>" {
+		t.Errorf("[rust] Expected 'Box<Node>', found '%v'", actual)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"slices"
+	"strings"
 	"unicode"
 	"unicode/utf8"
 )
@@ -25,6 +26,11 @@ const (
 	TOKEN_ROUND_CLOSE
 	TOKEN_SQUARE_OPEN
 	TOKEN_SQUARE_CLOSE
+	TOKEN_AT
+	TOKEN_EQUALS
+	TOKEN_STRING
+	TOKEN_CODE_BLOCK
+	TOKEN_COLON
 )
 
 type TokenAsString struct {
@@ -48,6 +54,11 @@ var TOKEN_LOOKUP = []TokenAsString{
 	{"TOKEN_ROUND_CLOSE", "close parentheses", ")"},
 	{"TOKEN_SQUARE_OPEN", "open bracket", "["},
 	{"TOKEN_SQUARE_CLOSE", "close bracket", "]"},
+	{"TOKEN_AT", "at sign", "@"},
+	{"TOKEN_EQUALS", "equals sign", "="},
+	{"TOKEN_STRING", "string", "string"},
+	{"TOKEN_CODE_BLOCK", "code block", "code block"},
+	{"TOKEN_COLON", "colon", ":"},
 }
 
 func TokenTypeToString(tokenType TokenType) string {
@@ -73,7 +84,7 @@ func TokenValueToString(token Token) string {
 	}
 
 	switch tokenType {
-	case TOKEN_KEYWORD, TOKEN_IDENTIFIER:
+	case TOKEN_KEYWORD, TOKEN_IDENTIFIER, TOKEN_STRING:
 		return token.tokenValue.string
 
 	case TOKEN_UNKNOWN_SYMBOL:
@@ -90,6 +101,7 @@ const (
 	ERROR_INVALID_RUNE_ENCODING TokenErrorType = iota
 	ERROR_UNCLOSED_STRING
 	ERROR_UNCLOSED_BLOCK_COMMENT
+	ERROR_UNCLOSED_CODE_BLOCK
 )
 
 type KeywordType = string
@@ -98,15 +110,23 @@ const (
 	KEYWORD_TYPE  KeywordType = "type"
 	KEYWORD_CONST KeywordType = "const"
 	KEYWORD_FUNC  KeywordType = "func"
+	KEYWORD_MIXIN KeywordType = "mixin"
+	KEYWORD_USE   KeywordType = "use"
+	KEYWORD_FLAGS KeywordType = "flags"
+	KEYWORD_FN    KeywordType = "fn"
 )
 
 var KEYWORD_LOOKUP = []string{
 	"type",
 	"const",
 	"func",
+	"mixin",
+	"use",
+	"flags",
+	"fn",
 }
 
-var KEYWORDS = []KeywordType{KEYWORD_TYPE, KEYWORD_CONST, KEYWORD_FUNC}
+var KEYWORDS = []KeywordType{KEYWORD_TYPE, KEYWORD_CONST, KEYWORD_FUNC, KEYWORD_MIXIN, KEYWORD_USE, KEYWORD_FLAGS, KEYWORD_FN}
 
 var PRIMITIVES = []string{
 	"i8", "i16", "i32", "i64",
@@ -120,10 +140,16 @@ type TokenValue struct {
 	string string
 	rune   rune
 	int    int
+	// Language identifier of a code block, its contents are stored in the string
+	tag string
 }
 
 type Token struct {
-	line       LinePos
+	line LinePos
+	// Position of the last character of the token, the same as line for tokens consisting of a single character
+	end LinePos
+	// Byte offset of the first character of the token within the lexed data
+	pos        int
 	tokenType  TokenType
 	tokenValue TokenValue
 }
@@ -134,12 +160,29 @@ type LinePos struct {
 	offset int
 }
 
+// Comment is a '#' comment skipped by the lexer. Comments are kept for tools working with the source text.
+type Comment struct {
+	line LinePos
+	// Text following the '#', up to the end of the line
+	text string
+	// Set when the comment follows a token on the same line
+	trailing bool
+}
+
 type Lexer struct {
-	data     []byte
-	line     LinePos
+	data []byte
+	line LinePos
+	// Position of the last consumed rune
+	lineLast LinePos
 	pos      int
+	// Byte offset at which the token being lexed starts
+	tokenPos int
 	runeNow  rune
 	runeSize int
+	// Every comment skipped so far, in order of appearance
+	comments []Comment
+	// Line number on which the last returned token ends
+	lastTokenLine int
 }
 
 func CreateLexer(data []byte) Lexer {
@@ -159,12 +202,18 @@ func CreateLexer(data []byte) Lexer {
 }
 
 func (lexer *Lexer) startRune() {
+	// Same as the end of the data in nextRune, instead of an invalid rune
+	if len(lexer.data) == 0 {
+		return
+	}
+
 	rune, runeSize := utf8.DecodeRune(lexer.data)
 	lexer.runeNow = rune
 	lexer.runeSize = runeSize
 }
 
 func (lexer *Lexer) nextRune() rune {
+	lexer.lineLast = lexer.line
 	if lexer.runeNow == '\n' {
 		lexer.line.number += 1
 		lexer.line.offset = 1
@@ -232,6 +281,28 @@ func makeKeyword(keyword KeywordType, line LinePos) Token {
 	return token
 }
 
+func makeString(str string, line LinePos) Token {
+	value := TokenValue{string: str}
+	token := Token{
+		line:       line,
+		tokenType:  TOKEN_STRING,
+		tokenValue: value,
+	}
+
+	return token
+}
+
+func makeCodeBlock(tag string, code string, line LinePos) Token {
+	value := TokenValue{string: code, tag: tag}
+	token := Token{
+		line:       line,
+		tokenType:  TOKEN_CODE_BLOCK,
+		tokenValue: value,
+	}
+
+	return token
+}
+
 func makeIdentifier(identifier string, line LinePos) Token {
 	value := TokenValue{string: identifier}
 	token := Token{
@@ -276,11 +347,82 @@ func parseWord(lexer *Lexer) (string, bool) {
 	return string(wordSlice), true
 }
 
+// Parses a double-quoted string literal. Only the '\"' and '\\' escape sequences are recognized.
+// Strings cannot span multiple lines.
+func parseString(lexer *Lexer) (string, bool) {
+	lexer.nextRune()
+
+	str := make([]rune, 0)
+	for {
+		rune := lexer.peekRune()
+		switch rune {
+		case '"':
+			lexer.nextRune()
+			return string(str), true
+
+		case 0, '\n':
+			return string(str), false
+
+		case '\\':
+			next := lexer.nextRune()
+			if next != '"' && next != '\\' {
+				str = append(str, '\\')
+				continue
+			}
+		}
+
+		str = append(str, lexer.peekRune())
+		lexer.nextRune()
+	}
+}
+
+// Parses a verbatim code block in the form of '%lang{ ... }%'. The contents are not interpreted in any way,
+// the block only ends at the first occurrence of '}%'. Returns false as the second value when the block
+// header is malformed and false as the third value when the block is never closed.
+func parseCodeBlock(lexer *Lexer) (Token, bool, bool) {
+	line := lexer.line
+	lexer.nextRune()
+
+	tag, ok := parseWord(lexer)
+	if !ok || lexer.peekRune() != '{' {
+		return Token{}, false, true
+	}
+
+	lexer.nextRune()
+	startPos := lexer.pos
+
+	for {
+		rune := lexer.peekRune()
+		if rune == 0 {
+			return Token{}, true, false
+		}
+
+		if rune == '}' && lexer.pos+1 < len(lexer.data) && lexer.data[lexer.pos+1] == '%' {
+			code := string(lexer.data[startPos:lexer.pos])
+			lexer.nextRune()
+			lexer.nextRune()
+			return makeCodeBlock(tag, code, line), true, true
+		}
+
+		lexer.nextRune()
+	}
+}
+
 func skipComment(lexer *Lexer) {
+	line := lexer.line
+	startPos := lexer.pos
+
 	rune := lexer.peekRune()
 	for rune != '\n' && rune != 0 {
 		rune = lexer.nextRune()
 	}
+
+	comment := Comment{
+		line:     line,
+		text:     strings.TrimRight(string(lexer.data[startPos+1:lexer.pos]), "\r"),
+		trailing: lexer.lastTokenLine == line.number,
+	}
+	lexer.comments = append(lexer.comments, comment)
 }
 
 func IsType(token Token, tokenType TokenType) bool {
@@ -292,13 +434,28 @@ func IsKeyword(token Token, keywordType KeywordType) bool {
 }
 
 func (lexer *Lexer) NextToken() Token {
-	return nextToken(lexer)
+	token := nextToken(lexer)
+	token.pos = lexer.tokenPos
+	if token.end == (LinePos{}) {
+		token.end = token.line
+		// Tokens which consumed any runes end at the last of them
+		if linePosBefore(token.line, lexer.lineLast) {
+			token.end = lexer.lineLast
+		}
+	}
+	lexer.lastTokenLine = token.end.number
+	return token
+}
+
+func linePosBefore(a LinePos, b LinePos) bool {
+	return a.number < b.number || (a.number == b.number && a.offset < b.offset)
 }
 
 var nextToken = func(lexer *Lexer) Token {
 	for {
 		rune := lexer.peekRune()
 		line := lexer.line
+		lexer.tokenPos = lexer.pos
 
 		switch rune {
 
@@ -306,6 +463,8 @@ var nextToken = func(lexer *Lexer) Token {
 			return makeToken(TOKEN_EOF, line)
 
 		case utf8.RuneError:
+			// Skip the invalid byte, so that lexing continues past it
+			lexer.nextRune()
 			return makeError(ERROR_INVALID_RUNE_ENCODING, line)
 
 		// TODO(kihau): Maybe add other white-space characters?
@@ -345,16 +504,49 @@ var nextToken = func(lexer *Lexer) Token {
 			lexer.nextRune()
 			return makeToken(TOKEN_SEMICOLON, line)
 
+		case ':':
+			lexer.nextRune()
+			return makeToken(TOKEN_COLON, line)
+
 		case '?':
 			lexer.nextRune()
 			return makeToken(TOKEN_NULLABLE, line)
 
+		case '@':
+			lexer.nextRune()
+			return makeToken(TOKEN_AT, line)
+
+		case '=':
+			lexer.nextRune()
+			return makeToken(TOKEN_EQUALS, line)
+
+		case '"':
+			str, ok := parseString(lexer)
+			if !ok {
+				return makeError(ERROR_UNCLOSED_STRING, line)
+			}
+
+			return makeString(str, line)
+
 		case '#':
 			skipComment(lexer)
 
+		case '%':
+			token, wellFormed, closed := parseCodeBlock(lexer)
+			if !wellFormed {
+				return makeUnknownSymbol(rune, line)
+			}
+
+			if !closed {
+				return makeError(ERROR_UNCLOSED_CODE_BLOCK, line)
+			}
+
+			return token
+
 		default:
 			word, ok := parseWord(lexer)
 			if !ok {
+				lexer.nextRune()
 				return makeUnknownSymbol(rune, line)
 			}
 
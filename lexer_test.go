@@ -146,3 +146,104 @@ func compareTokens(t *testing.T, expected Token, actual Token, tokenIndex int) b
 
 	return true
 }
+
+func TestStringLiteral(t *testing.T) {
+	lexer := CreateLexer([]byte(`@name(kotlin="ki\"nd") "open`))
+
+	expectedTokens := makeTestTokens(
+		testToken(TOKEN_AT, "", 0, 1, 1),
+		testToken(TOKEN_IDENTIFIER, "name", 0, 1, 2),
+		testToken(TOKEN_ROUND_OPEN, "", 0, 1, 6),
+		testToken(TOKEN_IDENTIFIER, "kotlin", 0, 1, 7),
+		testToken(TOKEN_EQUALS, "", 0, 1, 13),
+		testToken(TOKEN_STRING, "ki\"nd", 0, 1, 14),
+		testToken(TOKEN_ROUND_CLOSE, "", 0, 1, 22),
+		testToken(TOKEN_ERROR, "", ERROR_UNCLOSED_STRING, 1, 24),
+	)
+
+	for i, expected := range expectedTokens {
+		if !compareTokens(t, expected, lexer.NextToken(), i) {
+			break
+		}
+	}
+}
+
+func TestLexerContinuesPastErrors(t *testing.T) {
+	lexer := CreateLexer([]byte("a $ b\xffc"))
+
+	expectedTokens := makeTestTokens(
+		testToken(TOKEN_IDENTIFIER, "a", 0, 1, 1),
+		testToken(TOKEN_UNKNOWN_SYMBOL, "", 0, 1, 3),
+		testToken(TOKEN_IDENTIFIER, "b", 0, 1, 5),
+		testToken(TOKEN_ERROR, "", ERROR_INVALID_RUNE_ENCODING, 1, 6),
+		testToken(TOKEN_IDENTIFIER, "c", 0, 1, 7),
+		testToken(TOKEN_EOF, "", 0, 1, 8),
+	)
+	expectedPositions := []int{0, 2, 4, 5, 6, 7}
+
+	for i, expected := range expectedTokens {
+		token := lexer.NextToken()
+		if !compareTokens(t, expected, token, i) {
+			break
+		}
+
+		if token.pos != expectedPositions[i] {
+			t.Errorf("Token %v: expected byte offset %v, found %v", i, expectedPositions[i], token.pos)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	lexer := CreateLexer([]byte("# header\ntype Cat { # trailing\r\n}\n"))
+	for !IsType(lexer.NextToken(), TOKEN_EOF) {
+	}
+
+	expected := []Comment{
+		{line: LinePos{number: 1, offset: 1}, text: " header", trailing: false},
+		{line: LinePos{number: 2, offset: 12}, text: " trailing", trailing: true},
+	}
+	if len(lexer.comments) != len(expected) {
+		t.Fatalf("Expected %v comments, found %v", len(expected), len(lexer.comments))
+	}
+
+	for i, comment := range lexer.comments {
+		if comment != expected[i] {
+			t.Errorf("Expected comment %+v, found %+v", expected[i], comment)
+		}
+	}
+}
+
+func TestTokenEnd(t *testing.T) {
+	lexer := CreateLexer([]byte("type Cat {\n    u32 age;\n}"))
+
+	expectedEnds := []LinePos{
+		{number: 1, offset: 4},
+		{number: 1, offset: 8},
+		{number: 1, offset: 10},
+		{number: 2, offset: 7},
+		{number: 2, offset: 11},
+		{number: 2, offset: 12},
+		{number: 3, offset: 1},
+	}
+
+	for i, expected := range expectedEnds {
+		token := lexer.NextToken()
+		if token.end.number != expected.number || token.end.offset != expected.offset {
+			t.Errorf("Token %v: expected end %v:%v, found %v:%v", i, expected.number, expected.offset, token.end.number, token.end.offset)
+		}
+	}
+}
+
+func TestCodeBlock(t *testing.T) {
+	lexer := CreateLexer([]byte("%go{ a{} % b }%\n%rust{ "))
+
+	token := lexer.NextToken()
+	if !IsType(token, TOKEN_CODE_BLOCK) || token.tokenValue.tag != "go" || token.tokenValue.string != " a{} % b " {
+		t.Errorf("Expected go code block, found: %v (tag %v)", TokenToString(token), token.tokenValue.tag)
+	}
+
+	token = lexer.NextToken()
+	if !IsType(token, TOKEN_ERROR) || token.tokenValue.int != ERROR_UNCLOSED_CODE_BLOCK {
+		t.Errorf("Expected unclosed code block error, found: %v", TokenToString(token))
+	}
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// LintRule is a style or portability check run by the lint command over a typechecked schema.
+// Rules report problems through the linter, which applies the configured severity and suppressions.
+type LintRule struct {
+	name        string
+	code        DiagnosticCode
+	description string
+	// Severity used unless configured otherwise
+	severity Severity
+	check    func(linter *Linter)
+}
+
+var LINT_RULES = []LintRule{
+	{"type-case", "TG5001", "Names of types, mixins and flags are PascalCase.", SEVERITY_WARNING, lintTypeCase},
+	{"field-case", "TG5002", "Names of fields are camelCase.", SEVERITY_WARNING, lintFieldCase},
+	{"unused-type", "TG5003", "Every type is referenced by another type.", SEVERITY_NOTE, lintUnusedType},
+	{"field-shadows-method", "TG5004", "No field shares its name with a method of the same type.", SEVERITY_ERROR, lintFieldShadowsMethod},
+	{"js-large-integer", "TG5005", "No u64 or i64 values are generated for JavaScript, where numbers lose precision above 2^53.", SEVERITY_WARNING, lintJSLargeInteger},
+	{"max-fields", "TG5006", "Types have at most the configured number of fields.", SEVERITY_WARNING, lintMaxFields},
+}
+
+// Comments in the form of '# tg:ignore rule-a, rule-b' suppress the listed rules (or every rule, when none is listed).
+// A trailing comment applies to its own line, a comment on a line of its own applies to the following line.
+const LINT_IGNORE_PREFIX = "tg:ignore"
+
+func findLintRule(name string) *LintRule {
+	for i := range LINT_RULES {
+		if LINT_RULES[i].name == name {
+			return &LINT_RULES[i]
+		}
+	}
+	return nil
+}
+
+func lintRuleNames() []string {
+	names := make([]string, len(LINT_RULES))
+	for i, rule := range LINT_RULES {
+		names[i] = rule.name
+	}
+	return names
+}
+
+type LintOptions struct {
+	// Severities overriding the defaults of the rules, keyed by rule name
+	severities map[string]Severity
+	// Names of rules which are not run at all
+	disabled  *StringSet
+	maxFields int
+}
+
+func defaultLintOptions() LintOptions {
+	return LintOptions{
+		severities: make(map[string]Severity),
+		disabled:   NewSet(0),
+		maxFields:  32,
+	}
+}
+
+type Linter struct {
+	parser  *Parser
+	options LintOptions
+	// Rule being run at the moment
+	rule *LintRule
+	// Rules suppressed on a line, keyed by line number. An empty list suppresses every rule.
+	ignored     map[int][]string
+	diagnostics []Diagnostic
+}
+
+func newLinter(parser *Parser, options LintOptions) Linter {
+	linter := Linter{
+		parser:  parser,
+		options: options,
+		ignored: make(map[int][]string),
+	}
+
+	for _, comment := range parser.lexer.comments {
+		text := strings.TrimSpace(comment.text)
+		rules, found := strings.CutPrefix(text, LINT_IGNORE_PREFIX)
+		if !found {
+			continue
+		}
+
+		line := comment.line.number
+		if !comment.trailing {
+			line++
+		}
+
+		names := strings.FieldsFunc(rules, func(r rune) bool { return r == ',' || unicode.IsSpace(r) })
+		linter.ignored[line] = append(make([]string, 0), names...)
+	}
+
+	return linter
+}
+
+// Runs every enabled rule and returns the problems found, sorted by position
+func (linter *Linter) run() []Diagnostic {
+	for i := range LINT_RULES {
+		rule := &LINT_RULES[i]
+		if linter.options.disabled.Contains(rule.name) {
+			continue
+		}
+
+		linter.rule = rule
+		rule.check(linter)
+	}
+
+	sortDiagnostics(linter.diagnostics)
+	return linter.diagnostics
+}
+
+func (linter *Linter) isIgnored(line int) bool {
+	names, found := linter.ignored[line]
+	if !found {
+		return false
+	}
+
+	for _, name := range names {
+		if name == linter.rule.name {
+			return true
+		}
+	}
+	return len(names) == 0
+}
+
+// Reports a problem spanning the name at the given position. Problems reported twice at the same position,
+// which happens for members spliced from mixins, are only kept once.
+func (linter *Linter) report(pos LinePos, name string, message string) *Diagnostic {
+	if linter.isIgnored(pos.number) {
+		return nil
+	}
+
+	for _, diagnostic := range linter.diagnostics {
+		if diagnostic.code == linter.rule.code && diagnostic.start == pos {
+			return nil
+		}
+	}
+
+	diagnostic := newNameDiagnostic(linter.rule.code, linter.parser.filepath, pos, name, fmt.Sprintf("%s [%s]", message, linter.rule.name))
+	diagnostic.severity = linter.rule.severity
+	if severity, found := linter.options.severities[linter.rule.name]; found {
+		diagnostic.severity = severity
+	}
+
+	linter.diagnostics = append(linter.diagnostics, diagnostic)
+	return &linter.diagnostics[len(linter.diagnostics)-1]
+}
+
+// Mixins followed by types declared with 'type'. Fields spliced from mixins keep the positions from the mixin body,
+// so problems with them are reported only once.
+func (linter *Linter) typesAndMixins() []TypeDecl {
+	decls := make([]TypeDecl, 0, len(linter.parser.structs)+len(linter.parser.mixins))
+	decls = append(decls, linter.parser.mixins...)
+	return append(decls, linter.parser.structs...)
+}
+
+func isPascalCase(name string) bool {
+	for i, rune := range name {
+		if (i == 0 && !unicode.IsUpper(rune)) || rune == '_' {
+			return false
+		}
+	}
+	return name != ""
+}
+
+func isCamelCase(name string) bool {
+	for i, rune := range name {
+		if (i == 0 && !unicode.IsLower(rune)) || rune == '_' {
+			return false
+		}
+	}
+	return name != ""
+}
+
+func lintTypeCase(linter *Linter) {
+	for _, decl := range linter.typesAndMixins() {
+		if !isPascalCase(decl.typeName) {
+			linter.report(decl.typeLine, decl.typeName, fmt.Sprintf("Name of type '%s' is not PascalCase.", decl.typeName))
+		}
+	}
+
+	for _, flagsDecl := range linter.parser.flags {
+		if !isPascalCase(flagsDecl.name) {
+			linter.report(flagsDecl.nameLine, flagsDecl.name, fmt.Sprintf("Name of flags '%s' is not PascalCase.", flagsDecl.name))
+		}
+	}
+}
+
+func lintFieldCase(linter *Linter) {
+	for _, decl := range linter.typesAndMixins() {
+		for _, field := range decl.fields {
+			if !isCamelCase(field.varName) {
+				linter.report(field.varLine, field.varName, fmt.Sprintf("Name of field '%s' is not camelCase.", field.varName))
+			}
+		}
+	}
+}
+
+// Collects names of declared types held by the field, including the element, parameter and result types
+func collectReferencedTypes(field Field, referenced *StringSet) {
+	if field.typeName != "" {
+		referenced.Add(field.typeName)
+	}
+
+	for _, element := range field.elements {
+		collectReferencedTypes(element, referenced)
+	}
+
+	if field.result != nil {
+		collectReferencedTypes(*field.result, referenced)
+	}
+}
+
+// Collects names of types referenced by fields and methods of the type, which may include the type itself
+func typeReferences(decl TypeDecl) *StringSet {
+	referenced := NewSet(len(decl.fields))
+	for _, field := range decl.fields {
+		collectReferencedTypes(field, referenced)
+	}
+
+	for _, method := range decl.methods {
+		referenced.Add(method.returnType)
+		if method.returnTuple != nil {
+			collectReferencedTypes(*method.returnTuple, referenced)
+		}
+		for _, param := range method.fields {
+			collectReferencedTypes(param, referenced)
+		}
+	}
+	return referenced
+}
+
+func lintUnusedType(linter *Linter) {
+	// References from within the type itself don't count
+	referencedBy := make(map[string]*StringSet)
+	for _, decl := range linter.parser.structs {
+		referencedBy[decl.typeName] = typeReferences(decl)
+	}
+
+	isReferenced := func(name string) bool {
+		for owner, referenced := range referencedBy {
+			if owner != name && referenced.Contains(name) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, decl := range linter.parser.structs {
+		if !isReferenced(decl.typeName) {
+			linter.report(decl.typeLine, decl.typeName, fmt.Sprintf("Type '%s' is never referenced by other types.", decl.typeName))
+		}
+	}
+
+	for _, flagsDecl := range linter.parser.flags {
+		if !isReferenced(flagsDecl.name) {
+			linter.report(flagsDecl.nameLine, flagsDecl.name, fmt.Sprintf("Flags '%s' are never referenced by any type.", flagsDecl.name))
+		}
+	}
+}
+
+func lintFieldShadowsMethod(linter *Linter) {
+	for _, decl := range linter.parser.structs {
+		for _, field := range decl.fields {
+			for _, method := range decl.methods {
+				// Languages which capitalize exported names (Go) would turn 'name' and 'Name' into the same identifier
+				if !strings.EqualFold(field.varName, method.name) {
+					continue
+				}
+
+				message := fmt.Sprintf("Field '%s' shadows method '%s' of type '%s'.", field.varName, method.name, decl.typeName)
+				diagnostic := linter.report(field.varLine, field.varName, message)
+				if diagnostic != nil {
+					diagnostic.addRelated(method.line, nameEnd(method.line, method.name), fmt.Sprintf("Method '%s' declared here.", method.name))
+				}
+				break
+			}
+		}
+	}
+}
+
+// Reports u64 and i64 values held by the field, including the element, parameter and result types
+func (linter *Linter) reportLargeIntegers(field Field) {
+	if field.typeName == "u64" || field.typeName == "i64" {
+		message := fmt.Sprintf("Type '%s' is generated for JavaScript, where numbers above 2^53 lose precision.", field.typeName)
+		linter.report(field.typeLine, field.typeName, message)
+	}
+
+	for _, element := range field.elements {
+		linter.reportLargeIntegers(element)
+	}
+
+	if field.result != nil {
+		linter.reportLargeIntegers(*field.result)
+	}
+}
+
+func lintJSLargeInteger(linter *Linter) {
+	js := targetBit(JAVASCRIPT)
+	for _, decl := range linter.parser.structs {
+		typeMask := targetMask(decl.attributes)
+		if typeMask&js == 0 {
+			continue
+		}
+
+		for _, field := range decl.fields {
+			if targetMask(field.attributes)&js != 0 {
+				linter.reportLargeIntegers(field)
+			}
+		}
+
+		for _, method := range decl.methods {
+			if targetMask(method.attributes)&js == 0 {
+				continue
+			}
+
+			linter.reportLargeIntegers(Field{typeName: method.returnType, typeLine: method.returnLine})
+			if method.returnTuple != nil {
+				linter.reportLargeIntegers(*method.returnTuple)
+			}
+			for _, param := range method.fields {
+				linter.reportLargeIntegers(param)
+			}
+		}
+	}
+
+	// Masks of flags backed by 'u64' are generated as BigInts, which keep every bit
+}
+
+func lintMaxFields(linter *Linter) {
+	for _, decl := range linter.parser.structs {
+		if len(decl.fields) > linter.options.maxFields {
+			message := fmt.Sprintf("Type '%s' has %v fields, more than the maximum of %v.", decl.typeName, len(decl.fields), linter.options.maxFields)
+			linter.report(decl.typeLine, decl.typeName, message)
+		}
+	}
+}
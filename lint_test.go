@@ -0,0 +1,91 @@
+package main
+
+import (
+	"testing"
+)
+
+func lintSource(t *testing.T, source string, options LintOptions) []Diagnostic {
+	parser, result := parseAndTypecheck(t, source)
+	if !result.success {
+		t.Fatal(result.message)
+	}
+
+	linter := newLinter(&parser, options)
+	return linter.run()
+}
+
+func diagnosticCodes(diagnostics []Diagnostic) []DiagnosticCode {
+	codes := make([]DiagnosticCode, len(diagnostics))
+	for i, diagnostic := range diagnostics {
+		codes[i] = diagnostic.code
+	}
+	return codes
+}
+
+func TestLintRules(t *testing.T) {
+	options := defaultLintOptions()
+	options.maxFields = 2
+	source := "type cat {\n    string Name;\n    u64 age;\n    u8 meow;\n    func meow();\n}\ntype Owner {\n    cat pet;\n}\n"
+	diagnostics := lintSource(t, source, options)
+
+	expected := []DiagnosticCode{"TG5001", "TG5006", "TG5002", "TG5005", "TG5004", "TG5003"}
+	codes := diagnosticCodes(diagnostics)
+	if len(codes) != len(expected) {
+		t.Fatalf("Expected codes %v, found %v", expected, codes)
+	}
+
+	for i := range expected {
+		if codes[i] != expected[i] {
+			t.Errorf("Expected codes %v, found %v", expected, codes)
+			break
+		}
+	}
+
+	shadowed := diagnostics[4]
+	if shadowed.severity != SEVERITY_ERROR || len(shadowed.related) != 1 || shadowed.related[0].start.number != 5 {
+		t.Errorf("Expected the shadowed method as a related location: %v", shadowed.Error())
+	}
+}
+
+func TestLintIgnoreComments(t *testing.T) {
+	source := "type Cat {\n    string Name; # tg:ignore field-case\n    # tg:ignore\n    u64 big_value;\n    u8 other_value; # tg:ignore type-case\n}\n"
+	options := defaultLintOptions()
+	options.disabled.Add("unused-type")
+	diagnostics := lintSource(t, source, options)
+
+	if len(diagnostics) != 1 || diagnostics[0].code != "TG5002" || diagnostics[0].start.number != 5 {
+		t.Errorf("Expected only the field on line 5 to be reported, found %v", diagnosticCodes(diagnostics))
+	}
+}
+
+func TestLintSeverityOverride(t *testing.T) {
+	options := defaultLintOptions()
+	options.severities["type-case"] = SEVERITY_ERROR
+	options.disabled.Add("unused-type")
+	diagnostics := lintSource(t, "type cat {}\n", options)
+
+	if len(diagnostics) != 1 || diagnostics[0].severity != SEVERITY_ERROR {
+		t.Errorf("Expected type-case to be reported as an error, found %v", diagnosticCodes(diagnostics))
+	}
+}
+
+func TestLintMixinFieldsReportedOnce(t *testing.T) {
+	source := "mixin Audited {\n    u32 created_at;\n}\ntype A {\n    use Audited;\n}\ntype B {\n    use Audited;\n}\n"
+	options := defaultLintOptions()
+	options.disabled.Add("unused-type")
+	diagnostics := lintSource(t, source, options)
+
+	if len(diagnostics) != 1 || diagnostics[0].start.number != 2 {
+		t.Errorf("Expected a single report at the mixin field, found %v", diagnosticCodes(diagnostics))
+	}
+}
+
+func TestLintU64FlagsForJavascript(t *testing.T) {
+	// Masks of u64 flags are BigInts in JavaScript, so no precision is lost
+	diagnostics := lintSource(t, "flags F : u64 {\n    A;\n}\ntype Cat {\n    F f;\n}\n", defaultLintOptions())
+	for _, diagnostic := range diagnostics {
+		if diagnostic.code == "TG5005" {
+			t.Errorf("Expected u64 flags not to be reported: %v", diagnostic.Error())
+		}
+	}
+}
@@ -0,0 +1,778 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// LanguageServer speaks the Language Server Protocol over a pair of streams (stdin and stdout in practice).
+// Documents are synchronized in full on every change, then parsed and typechecked to publish diagnostics.
+// Positions are converted between LinePos (1-indexed, counted in runes) and LSP positions (0-indexed, counted
+// in UTF-16 code units).
+type LanguageServer struct {
+	reader *bufio.Reader
+	writer io.Writer
+	// Open documents keyed by URI
+	documents map[string]*lspDocument
+	// Set once the client requested a shutdown, the server exits successfully only afterwards
+	shutdown bool
+}
+
+// lspDocument is an open document together with the results of its latest analysis
+type lspDocument struct {
+	uri    string
+	text   string
+	lines  []string
+	parser Parser
+	// Declarations and references of types, collected before typechecking expanded the mixins
+	references []lspReference
+	symbols    []lspDocumentSymbol
+}
+
+// lspReference is a name of a type, mixin or flags, either where it's declared or where it's referenced
+type lspReference struct {
+	name        string
+	start       LinePos
+	end         LinePos
+	declaration bool
+	// Kind of the declared or referenced declaration: type, mixin or flags
+	kind string
+}
+
+// Structures of the protocol, only the fields used by the server are declared
+
+type lspMessage struct {
+	Jsonrpc string          `json:"jsonrpc"`
+	Id      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type lspResponse struct {
+	Jsonrpc string          `json:"jsonrpc"`
+	Id      json.RawMessage `json:"id"`
+	Result  any             `json:"result"`
+}
+
+type lspErrorResponse struct {
+	Jsonrpc string          `json:"jsonrpc"`
+	Id      json.RawMessage `json:"id"`
+	Error   lspError        `json:"error"`
+}
+
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type lspNotification struct {
+	Jsonrpc string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params"`
+}
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspLocation struct {
+	Uri   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+type lspTextDocumentItem struct {
+	Uri  string `json:"uri"`
+	Text string `json:"text"`
+}
+
+type lspTextDocumentIdentifier struct {
+	Uri string `json:"uri"`
+}
+
+type lspDidOpenParams struct {
+	TextDocument lspTextDocumentItem `json:"textDocument"`
+}
+
+type lspDidChangeParams struct {
+	TextDocument   lspTextDocumentIdentifier `json:"textDocument"`
+	ContentChanges []struct {
+		Text string `json:"text"`
+	} `json:"contentChanges"`
+}
+
+type lspDidCloseParams struct {
+	TextDocument lspTextDocumentIdentifier `json:"textDocument"`
+}
+
+type lspTextDocumentPositionParams struct {
+	TextDocument lspTextDocumentIdentifier `json:"textDocument"`
+	Position     lspPosition               `json:"position"`
+	// Only sent with references requests
+	Context struct {
+		IncludeDeclaration bool `json:"includeDeclaration"`
+	} `json:"context"`
+}
+
+type lspDocumentSymbolParams struct {
+	TextDocument lspTextDocumentIdentifier `json:"textDocument"`
+}
+
+type lspDiagnostic struct {
+	Range              lspRange                       `json:"range"`
+	Severity           int                            `json:"severity"`
+	Code               string                         `json:"code"`
+	Source             string                         `json:"source"`
+	Message            string                         `json:"message"`
+	RelatedInformation []lspDiagnosticRelatedLocation `json:"relatedInformation,omitempty"`
+}
+
+type lspDiagnosticRelatedLocation struct {
+	Location lspLocation `json:"location"`
+	Message  string      `json:"message"`
+}
+
+type lspPublishDiagnosticsParams struct {
+	Uri         string          `json:"uri"`
+	Diagnostics []lspDiagnostic `json:"diagnostics"`
+}
+
+type lspMarkupContent struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+type lspHover struct {
+	Contents lspMarkupContent `json:"contents"`
+	Range    lspRange         `json:"range"`
+}
+
+type lspCompletionItem struct {
+	Label  string `json:"label"`
+	Kind   int    `json:"kind"`
+	Detail string `json:"detail"`
+}
+
+type lspDocumentSymbol struct {
+	Name           string              `json:"name"`
+	Detail         string              `json:"detail,omitempty"`
+	Kind           int                 `json:"kind"`
+	Range          lspRange            `json:"range"`
+	SelectionRange lspRange            `json:"selectionRange"`
+	Children       []lspDocumentSymbol `json:"children,omitempty"`
+}
+
+// Values of the CompletionItemKind and SymbolKind enums of the protocol
+const (
+	LSP_COMPLETION_ENUM    = 13
+	LSP_COMPLETION_KEYWORD = 14
+	LSP_COMPLETION_STRUCT  = 22
+	LSP_COMPLETION_MODULE  = 9
+
+	LSP_SYMBOL_METHOD      = 6
+	LSP_SYMBOL_FIELD       = 8
+	LSP_SYMBOL_ENUM        = 10
+	LSP_SYMBOL_INTERFACE   = 11
+	LSP_SYMBOL_ENUM_MEMBER = 22
+	LSP_SYMBOL_STRUCT      = 23
+)
+
+// Error codes of JSON-RPC
+const (
+	LSP_METHOD_NOT_FOUND = -32601
+	LSP_INVALID_PARAMS   = -32602
+)
+
+// Upper bound of the Content-Length of a message, so a malformed header cannot exhaust the memory
+const LSP_MAX_MESSAGE_LENGTH = 64 << 20
+
+func newLanguageServer(reader io.Reader, writer io.Writer) LanguageServer {
+	return LanguageServer{
+		reader:    bufio.NewReader(reader),
+		writer:    writer,
+		documents: make(map[string]*lspDocument),
+	}
+}
+
+// Handles messages until the client asks the server to exit or closes the input. Returns the exit code.
+func (server *LanguageServer) serve() int {
+	for {
+		message, err := server.readMessage()
+		if err != nil {
+			return 1
+		}
+
+		if message.Method == "exit" {
+			if server.shutdown {
+				return 0
+			}
+			return 1
+		}
+
+		server.handle(message)
+	}
+}
+
+// Reads a single message, which is preceded by headers such as 'Content-Length: 42' and an empty line
+func (server *LanguageServer) readMessage() (lspMessage, error) {
+	headers, err := textproto.NewReader(server.reader).ReadMIMEHeader()
+	if err != nil {
+		return lspMessage{}, err
+	}
+
+	length, err := strconv.Atoi(headers.Get("Content-Length"))
+	if err != nil {
+		return lspMessage{}, fmt.Errorf("invalid Content-Length header: %v", err)
+	}
+	if length < 0 || length > LSP_MAX_MESSAGE_LENGTH {
+		return lspMessage{}, fmt.Errorf("invalid Content-Length header: %v is out of range", length)
+	}
+
+	body := make([]byte, length)
+	_, err = io.ReadFull(server.reader, body)
+	if err != nil {
+		return lspMessage{}, err
+	}
+
+	var message lspMessage
+	err = json.Unmarshal(body, &message)
+	return message, err
+}
+
+func (server *LanguageServer) write(message any) {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(server.writer, "Content-Length: %v\r\n\r\n%s", len(body), body)
+}
+
+func (server *LanguageServer) respond(id json.RawMessage, result any) {
+	server.write(lspResponse{Jsonrpc: "2.0", Id: id, Result: result})
+}
+
+func (server *LanguageServer) respondError(id json.RawMessage, code int, message string) {
+	server.write(lspErrorResponse{Jsonrpc: "2.0", Id: id, Error: lspError{Code: code, Message: message}})
+}
+
+func (server *LanguageServer) notify(method string, params any) {
+	server.write(lspNotification{Jsonrpc: "2.0", Method: method, Params: params})
+}
+
+func (server *LanguageServer) handle(message lspMessage) {
+	isRequest := len(message.Id) > 0
+	switch message.Method {
+	case "initialize":
+		server.respond(message.Id, map[string]any{
+			"capabilities": map[string]any{
+				// Documents are sent in full on every change
+				"textDocumentSync":       1,
+				"definitionProvider":     true,
+				"referencesProvider":     true,
+				"hoverProvider":          true,
+				"documentSymbolProvider": true,
+				"completionProvider":     map[string]any{},
+			},
+			"serverInfo": map[string]any{"name": "pocketgen"},
+		})
+
+	case "shutdown":
+		server.shutdown = true
+		server.respond(message.Id, nil)
+
+	case "textDocument/didOpen":
+		var params lspDidOpenParams
+		if json.Unmarshal(message.Params, &params) == nil {
+			server.analyze(params.TextDocument.Uri, params.TextDocument.Text)
+		}
+
+	case "textDocument/didChange":
+		var params lspDidChangeParams
+		if json.Unmarshal(message.Params, &params) == nil && len(params.ContentChanges) > 0 {
+			changes := params.ContentChanges
+			server.analyze(params.TextDocument.Uri, changes[len(changes)-1].Text)
+		}
+
+	case "textDocument/didClose":
+		var params lspDidCloseParams
+		if json.Unmarshal(message.Params, &params) == nil {
+			delete(server.documents, params.TextDocument.Uri)
+			server.notify("textDocument/publishDiagnostics", lspPublishDiagnosticsParams{Uri: params.TextDocument.Uri, Diagnostics: []lspDiagnostic{}})
+		}
+
+	case "textDocument/definition", "textDocument/references", "textDocument/hover", "textDocument/completion":
+		var params lspTextDocumentPositionParams
+		if json.Unmarshal(message.Params, &params) != nil {
+			server.respondError(message.Id, LSP_INVALID_PARAMS, "Invalid parameters.")
+			return
+		}
+
+		document, found := server.documents[params.TextDocument.Uri]
+		if !found {
+			server.respond(message.Id, nil)
+			return
+		}
+
+		pos := document.fromLSPPosition(params.Position)
+		switch message.Method {
+		case "textDocument/definition":
+			server.respond(message.Id, document.definition(pos))
+		case "textDocument/references":
+			server.respond(message.Id, document.findReferences(pos, params.Context.IncludeDeclaration))
+		case "textDocument/hover":
+			server.respond(message.Id, document.hover(pos))
+		default:
+			server.respond(message.Id, document.completion())
+		}
+
+	case "textDocument/documentSymbol":
+		var params lspDocumentSymbolParams
+		if json.Unmarshal(message.Params, &params) != nil {
+			server.respondError(message.Id, LSP_INVALID_PARAMS, "Invalid parameters.")
+			return
+		}
+
+		document, found := server.documents[params.TextDocument.Uri]
+		if !found {
+			server.respond(message.Id, nil)
+			return
+		}
+		server.respond(message.Id, document.symbols)
+
+	default:
+		// Notifications which aren't supported are ignored, requests have to be answered
+		if isRequest {
+			server.respondError(message.Id, LSP_METHOD_NOT_FOUND, fmt.Sprintf("Method '%v' is not supported.", message.Method))
+		}
+	}
+}
+
+// Parses and typechecks the new text of a document, then publishes the diagnostics
+func (server *LanguageServer) analyze(uri string, text string) {
+	document := &lspDocument{
+		uri:    uri,
+		text:   text,
+		lines:  strings.Split(text, "\n"),
+		parser: CreateParserFromData(uri, []byte(text)),
+	}
+
+	parser := &document.parser
+	result := ParseFile(parser)
+	document.references = collectReferences(parser)
+	document.symbols = document.documentSymbols()
+	if result.success {
+		TypecheckFile(parser)
+	}
+	server.documents[uri] = document
+
+	diagnostics := make([]lspDiagnostic, 0, len(parser.diagnostics))
+	for _, diagnostic := range parser.diagnostics {
+		diagnostics = append(diagnostics, document.toLSPDiagnostic(diagnostic))
+	}
+	server.notify("textDocument/publishDiagnostics", lspPublishDiagnosticsParams{Uri: uri, Diagnostics: diagnostics})
+}
+
+// Converts a position to the protocol, where lines and characters are counted from 0 and characters
+// are counted in UTF-16 code units
+func (document *lspDocument) toLSPPosition(pos LinePos) lspPosition {
+	position := lspPosition{Line: max(0, pos.number-1)}
+	if pos.number < 1 || pos.number > len(document.lines) {
+		return position
+	}
+
+	column := 1
+	for _, r := range document.lines[pos.number-1] {
+		if column >= pos.offset {
+			break
+		}
+		position.Character += utf16.RuneLen(r)
+		column++
+	}
+	// Positions past the end of the line (such as the line break itself) keep counting single characters
+	position.Character += max(0, pos.offset-column)
+	return position
+}
+
+func (document *lspDocument) fromLSPPosition(position lspPosition) LinePos {
+	pos := LinePos{number: position.Line + 1, offset: 1}
+	if position.Line < 0 || position.Line >= len(document.lines) {
+		return pos
+	}
+
+	units := 0
+	for _, r := range document.lines[position.Line] {
+		if units >= position.Character {
+			break
+		}
+		units += utf16.RuneLen(r)
+		pos.offset++
+	}
+	return pos
+}
+
+// Converts an inclusive span of positions to a range, which excludes its end
+func (document *lspDocument) toLSPRange(start LinePos, end LinePos) lspRange {
+	end.offset++
+	return lspRange{Start: document.toLSPPosition(start), End: document.toLSPPosition(end)}
+}
+
+func toLSPSeverity(severity Severity) int {
+	switch severity {
+	case SEVERITY_ERROR:
+		return 1
+	case SEVERITY_WARNING:
+		return 2
+	default:
+		return 3
+	}
+}
+
+func (document *lspDocument) toLSPDiagnostic(diagnostic Diagnostic) lspDiagnostic {
+	converted := lspDiagnostic{
+		Range:    document.toLSPRange(diagnostic.start, diagnostic.end),
+		Severity: toLSPSeverity(diagnostic.severity),
+		Code:     diagnostic.code,
+		Source:   "pocketgen",
+		Message:  diagnostic.message,
+	}
+
+	for _, related := range diagnostic.related {
+		converted.RelatedInformation = append(converted.RelatedInformation, lspDiagnosticRelatedLocation{
+			Location: lspLocation{Uri: document.uri, Range: document.toLSPRange(related.start, related.end)},
+			Message:  related.message,
+		})
+	}
+
+	if diagnostic.fix != nil {
+		converted.Message += " Help: " + diagnostic.fix.message
+	}
+	return converted
+}
+
+func newReference(name string, pos LinePos, declaration bool, kind string) lspReference {
+	return lspReference{name: name, start: pos, end: nameEnd(pos, name), declaration: declaration, kind: kind}
+}
+
+// Collects references to declared types held by the field, including element, parameter and result types
+func collectFieldReferences(field Field, references []lspReference) []lspReference {
+	if field.hasModifier(FIELD_TUPLE) || field.hasModifier(FIELD_FUNCTION) {
+		for _, element := range field.elements {
+			references = collectFieldReferences(element, references)
+		}
+		if field.result != nil {
+			references = collectFieldReferences(*field.result, references)
+		}
+		return references
+	}
+
+	return append(references, newReference(field.typeName, field.typeLine, false, ""))
+}
+
+// Collects every declaration and reference of a type, mixin or flags in a parsed (not yet typechecked) file.
+// References to primitives and to names which were never declared are left out.
+func collectReferences(parser *Parser) []lspReference {
+	kinds := make(map[string]string)
+	references := make([]lspReference, 0)
+	for _, decl := range parser.structs {
+		kinds[decl.typeName] = KEYWORD_TYPE
+		references = append(references, newReference(decl.typeName, decl.typeLine, true, KEYWORD_TYPE))
+	}
+	for _, mixin := range parser.mixins {
+		kinds[mixin.typeName] = KEYWORD_MIXIN
+		references = append(references, newReference(mixin.typeName, mixin.typeLine, true, KEYWORD_MIXIN))
+	}
+	for _, flagsDecl := range parser.flags {
+		kinds[flagsDecl.name] = KEYWORD_FLAGS
+		references = append(references, newReference(flagsDecl.name, flagsDecl.nameLine, true, KEYWORD_FLAGS))
+	}
+
+	decls := append(append(make([]TypeDecl, 0), parser.structs...), parser.mixins...)
+	for _, decl := range decls {
+		for _, field := range decl.fields {
+			references = collectFieldReferences(field, references)
+		}
+
+		for _, method := range decl.methods {
+			if method.returnType != "" {
+				references = append(references, newReference(method.returnType, method.returnLine, false, ""))
+			}
+			if method.returnTuple != nil {
+				references = collectFieldReferences(*method.returnTuple, references)
+			}
+			for _, param := range method.fields {
+				references = collectFieldReferences(param, references)
+			}
+		}
+
+		for _, use := range decl.uses {
+			references = append(references, newReference(use.name, use.nameLine, false, ""))
+		}
+	}
+
+	declared := make([]lspReference, 0, len(references))
+	for _, reference := range references {
+		kind, found := kinds[reference.name]
+		if found {
+			reference.kind = kind
+			declared = append(declared, reference)
+		}
+	}
+	return declared
+}
+
+func spanContains(start LinePos, end LinePos, pos LinePos) bool {
+	return !linePosBefore(pos, start) && !linePosBefore(end, pos)
+}
+
+func (document *lspDocument) referenceAt(pos LinePos) *lspReference {
+	for i, reference := range document.references {
+		if spanContains(reference.start, reference.end, pos) {
+			return &document.references[i]
+		}
+	}
+	return nil
+}
+
+func (document *lspDocument) location(reference lspReference) lspLocation {
+	return lspLocation{Uri: document.uri, Range: document.toLSPRange(reference.start, reference.end)}
+}
+
+// Returns the location of the declaration of the name at the position, nil when there is none
+func (document *lspDocument) definition(pos LinePos) *lspLocation {
+	reference := document.referenceAt(pos)
+	if reference == nil {
+		return nil
+	}
+
+	for _, candidate := range document.references {
+		if candidate.declaration && candidate.name == reference.name {
+			location := document.location(candidate)
+			return &location
+		}
+	}
+	return nil
+}
+
+func (document *lspDocument) findReferences(pos LinePos, includeDeclaration bool) []lspLocation {
+	locations := make([]lspLocation, 0)
+	reference := document.referenceAt(pos)
+	if reference == nil {
+		return locations
+	}
+
+	for _, candidate := range document.references {
+		if candidate.name == reference.name && (includeDeclaration || !candidate.declaration) {
+			locations = append(locations, document.location(candidate))
+		}
+	}
+	return locations
+}
+
+// Finds the field or method parameter spanning the position, from its type up to its name
+func (document *lspDocument) fieldAt(pos LinePos) (*TypeDecl, *Field) {
+	covers := func(field Field) bool {
+		start := field.typeLine
+		if field.typeLine.number != field.varLine.number {
+			start = field.varLine
+		}
+		return spanContains(start, nameEnd(field.varLine, field.varName), pos)
+	}
+
+	types := document.parser.structs
+	for i := range types {
+		for j := range types[i].fields {
+			if covers(types[i].fields[j]) {
+				return &types[i], &types[i].fields[j]
+			}
+		}
+
+		for _, method := range types[i].methods {
+			for j := range method.fields {
+				if covers(method.fields[j]) {
+					return &types[i], &method.fields[j]
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+// Describes the field at the position as generated for every target, or the type referenced at the position
+func (document *lspDocument) hover(pos LinePos) *lspHover {
+	decl, field := document.fieldAt(pos)
+	if field != nil {
+		builder := strings.Builder{}
+		builder.WriteString(fmt.Sprintf("**field** `%s` of `%s`\n\n", field.varName, decl.typeName))
+
+		schema := document.parser.Schema()
+		for _, generator := range allGenerators(defaultOptions()) {
+			line := fmt.Sprintf("- %s: excluded\n", generator.name())
+			resolved := resolveTarget(&schema, generator.language(), defaultOptions())
+			if generated := findResolvedField(resolved.types, decl.typeLine, field.varLine); generated != nil {
+				translated := translateField(*generated, generator.mapType)
+				line = fmt.Sprintf("- %s: `%s`\n", generator.name(), generator.typeString(translated))
+			}
+			builder.WriteString(line)
+		}
+
+		return &lspHover{
+			Contents: lspMarkupContent{Kind: "markdown", Value: builder.String()},
+			Range:    document.toLSPRange(field.typeLine, nameEnd(field.varLine, field.varName)),
+		}
+	}
+
+	reference := document.referenceAt(pos)
+	if reference == nil {
+		return nil
+	}
+
+	value := fmt.Sprintf("**%s** `%s`", reference.kind, reference.name)
+	for _, decl := range document.parser.structs {
+		if decl.typeName == reference.name {
+			value += fmt.Sprintf("\n\n%v fields, %v methods, generated for: %s", len(decl.fields), len(decl.methods), targetMaskToString(targetMask(decl.attributes)))
+			break
+		}
+	}
+
+	return &lspHover{
+		Contents: lspMarkupContent{Kind: "markdown", Value: value},
+		Range:    document.toLSPRange(reference.start, reference.end),
+	}
+}
+
+// Finds a field (or method parameter) of a resolved type by positions, which are kept by the resolution.
+// Returns a copy which can be translated freely, nil when the field is excluded from the target.
+func findResolvedField(types []TypeDecl, typeLine LinePos, varLine LinePos) *Field {
+	for _, decl := range types {
+		if decl.typeLine != typeLine {
+			continue
+		}
+
+		fields := append(make([]Field, 0), decl.fields...)
+		for _, method := range decl.methods {
+			fields = append(fields, method.fields...)
+		}
+
+		for _, field := range fields {
+			if field.varLine == varLine {
+				field = cloneField(field)
+				return &field
+			}
+		}
+	}
+	return nil
+}
+
+func (document *lspDocument) completion() []lspCompletionItem {
+	items := make([]lspCompletionItem, 0)
+	for _, primitive := range PRIMITIVES {
+		items = append(items, lspCompletionItem{Label: primitive, Kind: LSP_COMPLETION_KEYWORD, Detail: "primitive"})
+	}
+	for _, keyword := range KEYWORDS {
+		items = append(items, lspCompletionItem{Label: keyword, Kind: LSP_COMPLETION_KEYWORD, Detail: "keyword"})
+	}
+
+	for _, reference := range document.references {
+		if !reference.declaration {
+			continue
+		}
+
+		kind := LSP_COMPLETION_STRUCT
+		switch reference.kind {
+		case KEYWORD_MIXIN:
+			kind = LSP_COMPLETION_MODULE
+		case KEYWORD_FLAGS:
+			kind = LSP_COMPLETION_ENUM
+		}
+		items = append(items, lspCompletionItem{Label: reference.name, Kind: kind, Detail: reference.kind})
+	}
+	return items
+}
+
+// Lists declarations of the parsed document together with their members
+func (document *lspDocument) documentSymbols() []lspDocumentSymbol {
+	parser := &document.parser
+	symbols := make([]lspDocumentSymbol, 0)
+
+	typeSymbol := func(decl TypeDecl, kind int) lspDocumentSymbol {
+		end := decl.endLine
+		if linePosBefore(end, decl.line) {
+			end = nameEnd(decl.typeLine, decl.typeName)
+		}
+
+		symbol := lspDocumentSymbol{
+			Name:           decl.typeName,
+			Kind:           kind,
+			Range:          document.toLSPRange(attributesStart(decl.attributes, decl.line), end),
+			SelectionRange: document.toLSPRange(decl.typeLine, nameEnd(decl.typeLine, decl.typeName)),
+		}
+
+		for _, field := range decl.fields {
+			nameRange := document.toLSPRange(field.varLine, nameEnd(field.varLine, field.varName))
+			symbol.Children = append(symbol.Children, lspDocumentSymbol{
+				Name:           field.varName,
+				Detail:         formatFieldType(field),
+				Kind:           LSP_SYMBOL_FIELD,
+				Range:          document.toLSPRange(attributesStart(field.attributes, field.typeLine), nameEnd(field.varLine, field.varName)),
+				SelectionRange: nameRange,
+			})
+		}
+
+		for _, method := range decl.methods {
+			nameRange := document.toLSPRange(method.line, nameEnd(method.line, method.name))
+			symbol.Children = append(symbol.Children, lspDocumentSymbol{
+				Name:           method.name,
+				Detail:         formatMethod(method),
+				Kind:           LSP_SYMBOL_METHOD,
+				Range:          nameRange,
+				SelectionRange: nameRange,
+			})
+		}
+		return symbol
+	}
+
+	for _, decl := range parser.structs {
+		symbols = append(symbols, typeSymbol(decl, LSP_SYMBOL_STRUCT))
+	}
+	for _, mixin := range parser.mixins {
+		symbols = append(symbols, typeSymbol(mixin, LSP_SYMBOL_INTERFACE))
+	}
+
+	for _, flagsDecl := range parser.flags {
+		end := flagsDecl.endLine
+		if linePosBefore(end, flagsDecl.line) {
+			end = nameEnd(flagsDecl.nameLine, flagsDecl.name)
+		}
+
+		symbol := lspDocumentSymbol{
+			Name:           flagsDecl.name,
+			Detail:         flagsDecl.backingType,
+			Kind:           LSP_SYMBOL_ENUM,
+			Range:          document.toLSPRange(flagsDecl.line, end),
+			SelectionRange: document.toLSPRange(flagsDecl.nameLine, nameEnd(flagsDecl.nameLine, flagsDecl.name)),
+		}
+		for _, member := range flagsDecl.members {
+			memberRange := document.toLSPRange(member.line, nameEnd(member.line, member.name))
+			symbol.Children = append(symbol.Children, lspDocumentSymbol{
+				Name:           member.name,
+				Kind:           LSP_SYMBOL_ENUM_MEMBER,
+				Range:          memberRange,
+				SelectionRange: memberRange,
+			})
+		}
+		symbols = append(symbols, symbol)
+	}
+
+	return symbols
+}
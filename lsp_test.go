@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Splits the output of the server into messages
+func readReplies(t *testing.T, output []byte) []map[string]any {
+	replies := make([]map[string]any, 0)
+	for len(output) > 0 {
+		header, rest, found := bytes.Cut(output, []byte("\r\n\r\n"))
+		if !found {
+			t.Fatalf("Malformed output: %q", output)
+		}
+
+		var length int
+		fmt.Sscanf(string(header), "Content-Length: %d", &length)
+		var reply map[string]any
+		err := json.Unmarshal(rest[:length], &reply)
+		if err != nil {
+			t.Fatal(err)
+		}
+		replies = append(replies, reply)
+		output = rest[length:]
+	}
+	return replies
+}
+
+// Runs the server over the messages and returns the exit code and every message written by the server
+func serveMessages(t *testing.T, messages ...string) (int, []map[string]any) {
+	input := bytes.Buffer{}
+	for _, message := range messages {
+		fmt.Fprintf(&input, "Content-Length: %v\r\n\r\n%s", len(message), message)
+	}
+
+	output := bytes.Buffer{}
+	server := newLanguageServer(&input, &output)
+	code := server.serve()
+	return code, readReplies(t, output.Bytes())
+}
+
+func didOpen(text string) string {
+	params, _ := json.Marshal(lspDidOpenParams{TextDocument: lspTextDocumentItem{Uri: "file:///cat.tg", Text: text}})
+	return fmt.Sprintf(`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":%s}`, params)
+}
+
+func positionRequest(id int, method string, line int, character int) string {
+	return fmt.Sprintf(`{"jsonrpc":"2.0","id":%v,"method":"%v","params":{"textDocument":{"uri":"file:///cat.tg"},"position":{"line":%v,"character":%v},"context":{"includeDeclaration":true}}}`, id, method, line, character)
+}
+
+// Finds the response to the request with the id
+func findResponse(t *testing.T, replies []map[string]any, id int) map[string]any {
+	for _, reply := range replies {
+		if reply["id"] == float64(id) {
+			return reply
+		}
+	}
+	t.Fatalf("No response to request %v in %v", id, replies)
+	return nil
+}
+
+func TestLSPPositionConversion(t *testing.T) {
+	// 'é' is a single UTF-16 unit, '𝄞' is a surrogate pair
+	document := lspDocument{lines: []string{"type Cat {", "    é𝄞 x;", "}"}}
+	tests := []struct {
+		pos      LinePos
+		position lspPosition
+	}{
+		{LinePos{number: 1, offset: 6}, lspPosition{Line: 0, Character: 5}},
+		{LinePos{number: 2, offset: 6}, lspPosition{Line: 1, Character: 5}},
+		{LinePos{number: 2, offset: 7}, lspPosition{Line: 1, Character: 7}},
+		{LinePos{number: 2, offset: 8}, lspPosition{Line: 1, Character: 8}},
+	}
+
+	for _, test := range tests {
+		position := document.toLSPPosition(test.pos)
+		if position != test.position {
+			t.Errorf("Expected %v to be converted to %v, found %v", test.pos, test.position, position)
+		}
+
+		pos := document.fromLSPPosition(test.position)
+		if pos.number != test.pos.number || pos.offset != test.pos.offset {
+			t.Errorf("Expected %v to be converted to %v, found %v", test.position, test.pos, pos)
+		}
+	}
+}
+
+func TestLSPLifecycle(t *testing.T) {
+	initialize := `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`
+	unknown := `{"jsonrpc":"2.0","id":2,"method":"workspace/symbol","params":{}}`
+	shutdown := `{"jsonrpc":"2.0","id":3,"method":"shutdown"}`
+	exit := `{"jsonrpc":"2.0","method":"exit"}`
+
+	code, replies := serveMessages(t, initialize, unknown, shutdown, exit)
+	if code != 0 {
+		t.Errorf("Expected exit code 0 after shutdown, found %v", code)
+	}
+
+	result, _ := findResponse(t, replies, 1)["result"].(map[string]any)
+	capabilities, _ := result["capabilities"].(map[string]any)
+	if capabilities["textDocumentSync"] != float64(1) || capabilities["hoverProvider"] != true {
+		t.Errorf("Unexpected capabilities: %v", result)
+	}
+
+	responseError, _ := findResponse(t, replies, 2)["error"].(map[string]any)
+	if responseError["code"] != float64(LSP_METHOD_NOT_FOUND) {
+		t.Errorf("Expected unknown requests to fail: %v", replies)
+	}
+
+	code, _ = serveMessages(t, exit)
+	if code != 1 {
+		t.Errorf("Expected exit code 1 without shutdown, found %v", code)
+	}
+}
+
+func TestLSPMalformedHeaders(t *testing.T) {
+	inputs := []string{
+		"Content-Length: -1\r\n\r\n",
+		"Content-Length: 99999999999\r\n\r\n{}",
+		"Content-Length: many\r\n\r\n{}",
+		"Content-Type: application/json\r\n\r\n{}",
+		"Content-Length: 10\r\n\r\n{}",
+	}
+
+	for _, input := range inputs {
+		output := bytes.Buffer{}
+		server := newLanguageServer(strings.NewReader(input), &output)
+		if code := server.serve(); code != 1 {
+			t.Errorf("Expected exit code 1 for %q, found %v", input, code)
+		}
+	}
+}
+
+func TestLSPDiagnostics(t *testing.T) {
+	_, replies := serveMessages(t, didOpen("type Cat {\n    Dgo dog;\n}\ntype Dog {}\n"))
+	if len(replies) != 1 || replies[0]["method"] != "textDocument/publishDiagnostics" {
+		t.Fatalf("Expected diagnostics to be published: %v", replies)
+	}
+
+	params := replies[0]["params"].(map[string]any)
+	diagnostics := params["diagnostics"].([]any)
+	if len(diagnostics) != 1 {
+		t.Fatalf("Expected a single diagnostic: %v", params)
+	}
+
+	diagnostic := diagnostics[0].(map[string]any)
+	expectedRange := map[string]any{
+		"start": map[string]any{"line": float64(1), "character": float64(4)},
+		"end":   map[string]any{"line": float64(1), "character": float64(7)},
+	}
+	if diagnostic["code"] != string(CODE_UNDECLARED_TYPE) || diagnostic["severity"] != float64(1) || fmt.Sprint(diagnostic["range"]) != fmt.Sprint(expectedRange) {
+		t.Errorf("Unexpected diagnostic: %v", diagnostic)
+	}
+}
+
+func TestLSPNavigation(t *testing.T) {
+	source := "mixin Named {\n    string name;\n}\ntype Cat {\n    use Named;\n    Dog friend;\n    func chase() Dog;\n}\ntype Dog {}\n"
+	_, replies := serveMessages(t,
+		didOpen(source),
+		positionRequest(1, "textDocument/definition", 5, 5),
+		positionRequest(2, "textDocument/references", 8, 6),
+		positionRequest(3, "textDocument/definition", 4, 9),
+		positionRequest(4, "textDocument/definition", 1, 5),
+	)
+
+	location, _ := findResponse(t, replies, 1)["result"].(map[string]any)
+	start := location["range"].(map[string]any)["start"].(map[string]any)
+	if start["line"] != float64(8) || start["character"] != float64(5) {
+		t.Errorf("Expected the definition of 'Dog' on line 9: %v", location)
+	}
+
+	references, _ := findResponse(t, replies, 2)["result"].([]any)
+	if len(references) != 3 {
+		t.Errorf("Expected the declaration and two references of 'Dog': %v", references)
+	}
+
+	location, _ = findResponse(t, replies, 3)["result"].(map[string]any)
+	start = location["range"].(map[string]any)["start"].(map[string]any)
+	if start["line"] != float64(0) || start["character"] != float64(6) {
+		t.Errorf("Expected the definition of mixin 'Named' on line 1: %v", location)
+	}
+
+	if findResponse(t, replies, 4)["result"] != nil {
+		t.Errorf("Expected no definition of a primitive type")
+	}
+}
+
+func TestLSPHover(t *testing.T) {
+	source := "type Cat {\n    u32 age;\n    @except(js) [string] names;\n}\n"
+	_, replies := serveMessages(t,
+		didOpen(source),
+		positionRequest(1, "textDocument/hover", 1, 5),
+		positionRequest(2, "textDocument/hover", 2, 20),
+	)
+
+	contents := findResponse(t, replies, 1)["result"].(map[string]any)["contents"].(map[string]any)["value"].(string)
+	for _, expected := range []string{"go: `uint32`", "rust: `u32`", "java: `int`"} {
+		if !strings.Contains(contents, expected) {
+			t.Errorf("Expected %q in hover:\n%v", expected, contents)
+		}
+	}
+
+	contents = findResponse(t, replies, 2)["result"].(map[string]any)["contents"].(map[string]any)["value"].(string)
+	if !strings.Contains(contents, "js: excluded") || !strings.Contains(contents, "go: `[]string`") {
+		t.Errorf("Expected field to be excluded from JavaScript:\n%v", contents)
+	}
+}
+
+func TestLSPCompletionAndSymbols(t *testing.T) {
+	source := "type Cat {\n    u32 age;\n    func meow();\n}\nflags Perm : u8 {\n    READ;\n}\n"
+	_, replies := serveMessages(t,
+		didOpen(source),
+		positionRequest(1, "textDocument/completion", 1, 4),
+		`{"jsonrpc":"2.0","id":2,"method":"textDocument/documentSymbol","params":{"textDocument":{"uri":"file:///cat.tg"}}}`,
+	)
+
+	labels := make([]string, 0)
+	for _, item := range findResponse(t, replies, 1)["result"].([]any) {
+		labels = append(labels, item.(map[string]any)["label"].(string))
+	}
+	for _, expected := range []string{"u32", "string", "Cat", "Perm", "type"} {
+		if !strings.Contains(strings.Join(labels, " "), expected) {
+			t.Errorf("Expected completion of '%v': %v", expected, labels)
+		}
+	}
+
+	symbols := findResponse(t, replies, 2)["result"].([]any)
+	if len(symbols) != 2 {
+		t.Fatalf("Expected two symbols: %v", symbols)
+	}
+
+	cat := symbols[0].(map[string]any)
+	end := cat["range"].(map[string]any)["end"].(map[string]any)
+	if cat["name"] != "Cat" || cat["kind"] != float64(LSP_SYMBOL_STRUCT) || len(cat["children"].([]any)) != 2 || end["line"] != float64(3) {
+		t.Errorf("Unexpected symbol of 'Cat': %v", cat)
+	}
+
+	perm := symbols[1].(map[string]any)
+	if perm["name"] != "Perm" || perm["kind"] != float64(LSP_SYMBOL_ENUM) || len(perm["children"].([]any)) != 1 {
+		t.Errorf("Unexpected symbol of 'Perm': %v", perm)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"slices"
+	"strings"
+	"unicode"
 )
 
 type Parser struct {
@@ -11,16 +13,118 @@ type Parser struct {
 	lexer    Lexer
 	// Instead of storing types in an array, maybe a hash map lookup would be better?
 	// Redeclaration error could then happen during the parsing stage (and not type checking) and be a 'soft' error.
-	structs  []TypeDecl
-	tokenNow Token
+	structs    []TypeDecl
+	mixins     []TypeDecl
+	flags      []FlagsDecl
+	codeBlocks []CodeBlock
+	tokenNow   Token
+	// Token following tokenNow, only valid when hasTokenNext is set
+	tokenNext    Token
+	hasTokenNext bool
+	// Last token consumed with AdvanceToken, used to synchronize after syntax errors
+	tokenPrev Token
+	// Every error reported so far. Parsing continues after an error, so a single run reports all of them.
+	diagnostics []Diagnostic
+	// Parsing stops once this many errors were reported, 0 means there is no limit
+	maxErrors int
+	// Types containing themselves by value get one field of every cycle boxed, instead of being reported as errors
+	boxRecursiveTypes bool
+	// Types as they were parsed. Typechecking splices mixins into copies of them, which replace structs.
+	parsedStructs []TypeDecl
+}
+
+// Schema holds every top-level declaration of a single parsed file. It is the input of all generators.
+type Schema struct {
+	filepath   string
+	types      []TypeDecl
+	flags      []FlagsDecl
+	codeBlocks []CodeBlock
+}
+
+func (parser *Parser) Schema() Schema {
+	schema := Schema{
+		filepath:   parser.filepath,
+		types:      parser.structs,
+		flags:      parser.flags,
+		codeBlocks: parser.codeBlocks,
+	}
+
+	return schema
+}
+
+// CodeBlock is target language code that is copied verbatim by the generator of the matching language.
+// Blocks declared at file level are placed in the file header when 'position' is 0, otherwise right after
+// the type with the index 'position - 1'. Blocks declared within a type are placed inside of its body.
+type CodeBlock struct {
+	line     LinePos
+	tag      string
+	code     string
+	position int
 }
 
 type TypeDecl struct {
 	line     LinePos
 	typeName string
 	typeLine LinePos
-	fields   []Field
-	methods  []FuncDecl
+	// Position of the closing brace
+	endLine    LinePos
+	fields     []Field
+	methods    []FuncDecl
+	uses       []MixinUse
+	attributes []Attribute
+	codeBlocks []CodeBlock
+}
+
+// Attribute such as @only(go, rust), @except(js) or @name(kotlin="kind"), attached to the declaration that follows it.
+type Attribute struct {
+	line     LinePos
+	name     string
+	nameLine LinePos
+	args     []AttributeArg
+}
+
+// AttributeArg is either a plain identifier (go) or an identifier assigned a string value (kotlin="kind").
+type AttributeArg struct {
+	line     LinePos
+	key      string
+	value    string
+	hasValue bool
+}
+
+// MixinUse marks the place within a type body where members of a mixin are spliced in.
+// Positions are indices into fields and methods of the type at the moment 'use' was parsed.
+type MixinUse struct {
+	line      LinePos
+	name      string
+	nameLine  LinePos
+	fieldPos  int
+	methodPos int
+}
+
+// FlagsDecl is a set of bit flags stored in an unsigned integer of the backing type.
+// Members are assigned consecutive powers of two in the order of declaration.
+type FlagsDecl struct {
+	line        LinePos
+	name        string
+	nameLine    LinePos
+	backingType string
+	backingLine LinePos
+	members     []FlagMember
+	// Position of the closing brace
+	endLine LinePos
+}
+
+type FlagMember struct {
+	name string
+	line LinePos
+}
+
+// Bit sizes of types allowed to back flags
+var FLAGS_BACKING_TYPES = map[string]int{
+	"u8":  8,
+	"u16": 16,
+	"u32": 32,
+	"u64": 64,
 }
 
 type FuncDecl struct {
@@ -29,6 +133,9 @@ type FuncDecl struct {
 	fields     []Field
 	returnType string
 	returnLine LinePos
+	// Set instead of returnType when multiple values are returned. It is a nameless field of a tuple type.
+	returnTuple *Field
+	attributes  []Attribute
 }
 
 type FieldModifier = uint32
@@ -39,14 +146,49 @@ const (
 	FIELD_ARRAY
 	FIELD_NULLABLE
 	FIELD_PRIMITIVE
+	FIELD_TUPLE
+	FIELD_FUNCTION
+	// Stored behind a pointer in languages which otherwise store fields inline, set to break recursive types
+	FIELD_BOXED
 )
 
 type Field struct {
-	varName   string
-	varLine   LinePos
-	typeName  string
-	typeLine  LinePos
-	modifiers FieldModifier
+	varName    string
+	varLine    LinePos
+	typeName   string
+	typeLine   LinePos
+	modifiers  FieldModifier
+	attributes []Attribute
+	// Element types of a tuple or parameter types of a function. Elements are fields without a name,
+	// typeName of the tuple or function itself is empty.
+	elements []Field
+	// Return type of a function, nil when nothing is returned.
+	result *Field
+	// Name of the type generated for a tuple or a function, in languages which cannot express them inline.
+	generatedName string
+}
+
+// Copies the field together with the element and result types, so that the copy can be modified independently.
+func cloneField(field Field) Field {
+	field.elements = cloneFields(field.elements)
+	if field.result != nil {
+		result := cloneField(*field.result)
+		field.result = &result
+	}
+	return field
+}
+
+func cloneFields(fields []Field) []Field {
+	if fields == nil {
+		return nil
+	}
+
+	cloned := make([]Field, len(fields))
+	for i, field := range fields {
+		cloned[i] = cloneField(field)
+	}
+
+	return cloned
 }
 
 func CreateField(varName string, varLine LinePos, typeName string, typeLine LinePos, modifiers FieldModifier) Field {
@@ -69,22 +211,74 @@ func CreateParser(path string) (Parser, bool) {
 		return Parser{}, false
 	}
 
-	lexer := CreateLexer(data)
-	token := lexer.NextToken()
+	return CreateParserFromData(path, data), true
+}
 
+// Same as CreateParser, but the file contents are supplied by the caller. The path is only used for reporting.
+func CreateParserFromData(path string, data []byte) Parser {
 	parser := Parser{
 		filepath: path,
-		lexer:    lexer,
+		lexer:    CreateLexer(data),
 		structs:  make([]TypeDecl, 0),
-		tokenNow: token,
+		mixins:   make([]TypeDecl, 0),
+	}
+	parser.tokenNow = parser.scanToken()
+
+	return parser
+}
+
+// Takes the next token from the lexer. Error tokens and unknown symbols are reported as lexer errors
+// and skipped, so the parser only ever sees well-formed tokens and can carry on past them.
+func (parser *Parser) scanToken() Token {
+	for {
+		token := parser.lexer.NextToken()
+		if !IsType(token, TOKEN_ERROR) && !IsType(token, TOKEN_UNKNOWN_SYMBOL) {
+			return token
+		}
+		if !parser.errorLimitReached() {
+			parser.reportError(parser.lexerError(token))
+		}
+	}
+}
+
+// Builds a diagnostic describing an error token or an unknown symbol produced by the lexer
+func (parser *Parser) lexerError(token Token) ParserResult {
+	var code DiagnosticCode
+	var message string
+	if IsType(token, TOKEN_UNKNOWN_SYMBOL) {
+		code = CODE_UNKNOWN_SYMBOL
+		message = fmt.Sprintf("Unknown symbol '%c' at byte offset %v.", token.tokenValue.rune, token.pos)
+	} else {
+		switch token.tokenValue.int {
+		case ERROR_INVALID_RUNE_ENCODING:
+			code = CODE_INVALID_RUNE_ENCODING
+			message = fmt.Sprintf("Invalid UTF-8 encoding, byte 0x%02X at byte offset %v does not begin a valid character.", parser.lexer.data[token.pos], token.pos)
+		case ERROR_UNCLOSED_STRING:
+			code = CODE_UNCLOSED_STRING
+			message = fmt.Sprintf("String starting at byte offset %v is never closed. Strings cannot span multiple lines.", token.pos)
+		case ERROR_UNCLOSED_BLOCK_COMMENT:
+			code = CODE_UNCLOSED_BLOCK_COMMENT
+			message = fmt.Sprintf("Block comment starting at byte offset %v is never closed.", token.pos)
+		default:
+			code = CODE_UNCLOSED_CODE_BLOCK
+			message = fmt.Sprintf("Code block starting at byte offset %v is never closed with '}%%'.", token.pos)
+		}
 	}
 
-	return parser, true
+	diagnostic := newDiagnostic(code, parser.filepath, token.line, message)
+	diagnostic.end = token.end
+	return parserFailure(diagnostic)
 }
 
 func AdvanceToken(parser *Parser) Token {
 	previous := parser.tokenNow
-	parser.tokenNow = parser.lexer.NextToken()
+	parser.tokenPrev = previous
+	if parser.hasTokenNext {
+		parser.tokenNow = parser.tokenNext
+		parser.hasTokenNext = false
+	} else {
+		parser.tokenNow = parser.scanToken()
+	}
 	return previous
 }
 
@@ -92,6 +286,15 @@ func PeekToken(parser *Parser) Token {
 	return parser.tokenNow
 }
 
+// Returns the token following the one returned by PeekToken, without consuming any of them.
+func PeekNextToken(parser *Parser) Token {
+	if !parser.hasTokenNext {
+		parser.tokenNext = parser.scanToken()
+		parser.hasTokenNext = true
+	}
+	return parser.tokenNext
+}
+
 // Returns true if modifier was already applied. This check might be useful when more modifier keywords are added
 // and we don't want a modifier to repeat multiple times. For example:
 //
@@ -111,6 +314,8 @@ func (field *Field) hasModifier(modifier FieldModifier) bool {
 type ParserResult struct {
 	success bool
 	message string
+	// Describes the error, unset for successful results
+	diagnostic Diagnostic
 }
 
 func parserOk() ParserResult {
@@ -122,15 +327,110 @@ func parserOk() ParserResult {
 	return result
 }
 
-func (parser *Parser) formatExpectedToken(found Token, format string, args ...any) string {
-	// TODO(kihau): Also handle lexer errors (error tokens).
+func parserFailure(diagnostic Diagnostic) ParserResult {
+	result := ParserResult{
+		success:    false,
+		message:    diagnostic.Error(),
+		diagnostic: diagnostic,
+	}
+
+	return result
+}
+
+// Records an error and lets the caller carry on. Use errorLimitReached to find out whether it should stop.
+func (parser *Parser) reportError(result ParserResult) {
+	parser.diagnostics = append(parser.diagnostics, result.diagnostic)
+}
+
+func (parser *Parser) errorLimitReached() bool {
+	return parser.maxErrors > 0 && len(parser.diagnostics) >= parser.maxErrors
+}
+
+// Returns a note telling that errors past the limit were not reported, or an empty string when the limit wasn't reached
+func (parser *Parser) errorLimitNote() string {
+	if !parser.errorLimitReached() {
+		return ""
+	}
+	return fmt.Sprintf("Stopped after %v errors.", parser.maxErrors)
+}
+
+// Merges every reported error into a single result, which is successful when nothing was reported.
+func (parser *Parser) errorsResult() ParserResult {
+	if len(parser.diagnostics) == 0 {
+		return parserOk()
+	}
+
+	messages := make([]string, 0, len(parser.diagnostics)+1)
+	for _, diagnostic := range parser.diagnostics {
+		messages = append(messages, diagnostic.Error())
+	}
+
+	if note := parser.errorLimitNote(); note != "" {
+		messages = append(messages, note)
+	}
+
+	result := ParserResult{
+		success: false,
+		message: strings.Join(messages, "\n"),
+	}
+	return result
+}
+
+// Skips tokens up to the end of the member in which an error occurred, that is past the next semicolon
+// or closing brace. Returns true when the closing brace of the enclosing block was consumed.
+func synchronizeMember(parser *Parser) bool {
+	// The offending token might have been the terminator itself
+	if IsType(parser.tokenPrev, TOKEN_SEMICOLON) {
+		return false
+	}
+	if IsType(parser.tokenPrev, TOKEN_CURLY_CLOSE) {
+		return true
+	}
+
+	for {
+		token := PeekToken(parser)
+		if IsType(token, TOKEN_EOF) {
+			return false
+		}
+
+		AdvanceToken(parser)
+		if IsType(token, TOKEN_SEMICOLON) {
+			return false
+		}
+		if IsType(token, TOKEN_CURLY_CLOSE) {
+			return true
+		}
+	}
+}
+
+// Skips tokens up to the start of the next top-level declaration, after an error in the current one.
+func synchronizeDeclaration(parser *Parser) {
+	if IsType(parser.tokenPrev, TOKEN_CURLY_CLOSE) {
+		return
+	}
+
+	for {
+		token := PeekToken(parser)
+		if IsType(token, TOKEN_EOF) {
+			return
+		}
+
+		if IsKeyword(token, KEYWORD_TYPE) || IsKeyword(token, KEYWORD_MIXIN) || IsKeyword(token, KEYWORD_FLAGS) {
+			return
+		}
 
+		AdvanceToken(parser)
+		if IsType(token, TOKEN_CURLY_CLOSE) {
+			return
+		}
+	}
+}
+
+func (parser *Parser) formatExpectedToken(found Token, format string, args ...any) string {
 	foundString := fmt.Sprintf("%v '%s'", TokenTypeToStringPretty(found.tokenType), TokenValueToString(found))
 	expectedString := fmt.Sprintf(format, args...)
 
-	line := found.line
-
-	message := fmt.Sprintf("ERROR @ %s:%v:%v Expected %s, but instead %s was found.", parser.filepath, line.number, line.offset, expectedString, foundString)
+	message := fmt.Sprintf("Expected %s, but instead %s was found.", expectedString, foundString)
 	return message
 }
 
@@ -145,12 +445,18 @@ func (parser *Parser) expectedToken(expected Token, found Token) ParserResult {
 		message = parser.formatExpectedToken(found, "%s", expectedTypeString)
 	}
 
-	result := ParserResult{
-		success: false,
-		message: message,
+	diagnostic := newDiagnostic(CODE_UNEXPECTED_TOKEN, parser.filepath, found.line, message)
+	diagnostic.end = found.end
+	if IsType(expected, TOKEN_SEMICOLON) {
+		diagnostic.fix = &FixIt{
+			message:     "insert ';'",
+			start:       found.line,
+			end:         found.line,
+			replacement: ";",
+		}
 	}
 
-	return result
+	return parserFailure(diagnostic)
 }
 
 func (parser *Parser) expectedTokenType(expectedType TokenType, found Token) ParserResult {
@@ -170,82 +476,120 @@ func (parser *Parser) expectedKeyword(keywordType KeywordType, found Token) Pars
 	return parser.expectedToken(expected, found)
 }
 
-func (parser *Parser) parserErrorMessage(line LinePos, message string) ParserResult {
-	message = fmt.Sprintf("ERROR @ %s:%v:%v %s", parser.filepath, line.number, line.offset, message)
+func (parser *Parser) parserErrorMessage(code DiagnosticCode, line LinePos, message string) ParserResult {
+	return parserFailure(newDiagnostic(code, parser.filepath, line, message))
+}
 
-	result := ParserResult{
-		success: false,
-		message: message,
+func parseAttributeArg(parser *Parser, attribute *Attribute) ParserResult {
+	token := AdvanceToken(parser)
+	if !IsType(token, TOKEN_IDENTIFIER) {
+		return parser.expectedTokenType(TOKEN_IDENTIFIER, token)
 	}
 
-	return result
-}
-
-func parseTypeField(parser *Parser, field *Field) ParserResult {
-	token := PeekToken(parser)
-	if IsKeyword(token, KEYWORD_CONST) {
-		addModifier(field, FIELD_CONST)
-		AdvanceToken(parser)
+	arg := AttributeArg{
+		line: token.line,
+		key:  token.tokenValue.string,
 	}
 
-	//
-	// Parse the field type
-	//
-	is_array_type := false
 	token = PeekToken(parser)
-	if IsType(token, TOKEN_SQUARE_OPEN) {
-		is_array_type = true
-		addModifier(field, FIELD_ARRAY)
+	if IsType(token, TOKEN_EQUALS) {
 		AdvanceToken(parser)
-	}
 
-	token = AdvanceToken(parser)
-	if !IsType(token, TOKEN_IDENTIFIER) {
-		return parser.expectedTokenType(TOKEN_IDENTIFIER, token)
+		token = AdvanceToken(parser)
+		if !IsType(token, TOKEN_STRING) {
+			return parser.expectedTokenType(TOKEN_STRING, token)
+		}
+
+		arg.value = token.tokenValue.string
+		arg.hasValue = true
 	}
 
-	field.typeLine = token.line
-	field.typeName = token.tokenValue.string
+	attribute.args = append(attribute.args, arg)
+	return parserOk()
+}
 
-	token = PeekToken(parser)
-	if IsType(token, TOKEN_NULLABLE) {
-		addModifier(field, FIELD_NULLABLE)
+// Parses any number of attributes preceding a declaration. Each attribute takes the form '@name(arg, key="value")'.
+func parseAttributes(parser *Parser, attributes *[]Attribute) ParserResult {
+	for {
+		token := PeekToken(parser)
+		if !IsType(token, TOKEN_AT) {
+			return parserOk()
+		}
 		AdvanceToken(parser)
-	}
 
-	if is_array_type {
+		attribute := Attribute{line: token.line}
+
 		token = AdvanceToken(parser)
-		if !IsType(token, TOKEN_SQUARE_CLOSE) {
-			return parser.expectedTokenType(TOKEN_SQUARE_CLOSE, token)
+		if !IsType(token, TOKEN_IDENTIFIER) {
+			return parser.expectedTokenType(TOKEN_IDENTIFIER, token)
 		}
-	}
 
-	//
-	// Parse the field variable name
-	//
-	token = AdvanceToken(parser)
-	if !IsType(token, TOKEN_IDENTIFIER) {
-		return parser.expectedTokenType(TOKEN_IDENTIFIER, token)
-	}
+		attribute.name = token.tokenValue.string
+		attribute.nameLine = token.line
 
-	field.varLine = token.line
-	field.varName = token.tokenValue.string
+		token = AdvanceToken(parser)
+		if !IsType(token, TOKEN_ROUND_OPEN) {
+			return parser.expectedTokenType(TOKEN_ROUND_OPEN, token)
+		}
 
-	return parserOk()
+		for {
+			result := parseAttributeArg(parser, &attribute)
+			if !result.success {
+				return result
+			}
+
+			token = PeekToken(parser)
+			if !IsType(token, TOKEN_COMMA) {
+				break
+			}
+
+			AdvanceToken(parser)
+		}
+
+		token = AdvanceToken(parser)
+		if !IsType(token, TOKEN_ROUND_CLOSE) {
+			return parser.expectedTokenType(TOKEN_ROUND_CLOSE, token)
+		}
+
+		*attributes = append(*attributes, attribute)
+	}
 }
 
-func parseFunctionDeclaration(parser *Parser, funcDecl *FuncDecl) ParserResult {
-	AdvanceToken(parser)
+// Parses tuple element types in the form of '(type, type, ...)', the opening parenthesis is already consumed.
+func parseTupleElements(parser *Parser, field *Field) ParserResult {
+	for {
+		element := Field{}
+		result := parseFieldType(parser, &element, false)
+		field.elements = append(field.elements, element)
+		if !result.success {
+			return result
+		}
+
+		token := PeekToken(parser)
+		if !IsType(token, TOKEN_COMMA) {
+			break
+		}
+
+		AdvanceToken(parser)
+	}
 
 	token := AdvanceToken(parser)
-	if !IsType(token, TOKEN_IDENTIFIER) {
-		return parser.expectedTokenType(TOKEN_IDENTIFIER, token)
+	if !IsType(token, TOKEN_ROUND_CLOSE) {
+		return parser.expectedTokenType(TOKEN_ROUND_CLOSE, token)
 	}
 
-	funcDecl.line = token.line
-	funcDecl.name = token.tokenValue.string
+	if len(field.elements) < 2 {
+		return parser.parserErrorMessage(CODE_INVALID_TUPLE, field.typeLine, "Tuples must consist of at least two element types.")
+	}
 
-	token = AdvanceToken(parser)
+	return parserOk()
+}
+
+// Parses a function type in the form of 'fn(type, ...) type', the 'fn' keyword is already consumed.
+// Because a field name follows the type, the return type is only recognized when another identifier follows it,
+// so that both 'fn(u32) bool onChange' and 'fn(u32) onChange' are valid.
+func parseFunctionType(parser *Parser, field *Field, followedByName bool) ParserResult {
+	token := AdvanceToken(parser)
 	if !IsType(token, TOKEN_ROUND_OPEN) {
 		return parser.expectedTokenType(TOKEN_ROUND_OPEN, token)
 	}
@@ -255,9 +599,9 @@ func parseFunctionDeclaration(parser *Parser, funcDecl *FuncDecl) ParserResult {
 		AdvanceToken(parser)
 	} else {
 		for {
-			field := Field{}
-			result := parseTypeField(parser, &field)
-			funcDecl.fields = append(funcDecl.fields, field)
+			param := Field{}
+			result := parseFieldType(parser, &param, false)
+			field.elements = append(field.elements, param)
 			if !result.success {
 				return result
 			}
@@ -270,67 +614,313 @@ func parseFunctionDeclaration(parser *Parser, funcDecl *FuncDecl) ParserResult {
 			AdvanceToken(parser)
 		}
 
-		token := AdvanceToken(parser)
+		token = AdvanceToken(parser)
 		if !IsType(token, TOKEN_ROUND_CLOSE) {
 			return parser.expectedTokenType(TOKEN_ROUND_CLOSE, token)
 		}
 	}
 
 	token = PeekToken(parser)
+	hasResult := IsType(token, TOKEN_SQUARE_OPEN) || IsType(token, TOKEN_ROUND_OPEN) || IsKeyword(token, KEYWORD_FN)
 	if IsType(token, TOKEN_IDENTIFIER) {
-		funcDecl.returnLine = token.line
-		funcDecl.returnType = token.tokenValue.string
-		AdvanceToken(parser)
+		next := PeekNextToken(parser)
+		hasResult = !followedByName || IsType(next, TOKEN_IDENTIFIER) || IsType(next, TOKEN_NULLABLE)
+	}
+
+	if hasResult {
+		field.result = &Field{}
+		return parseFieldType(parser, field.result, followedByName)
 	}
 
 	return parserOk()
 }
 
-func parseTypeDeclaration(parser *Parser, typeDecl *TypeDecl) ParserResult {
-	token := AdvanceToken(parser)
-	typeDecl.line = token.line
+func parseFieldType(parser *Parser, field *Field, followedByName bool) ParserResult {
+	is_array_type := false
+	token := PeekToken(parser)
+	if IsType(token, TOKEN_SQUARE_OPEN) {
+		is_array_type = true
+		addModifier(field, FIELD_ARRAY)
+		AdvanceToken(parser)
+	}
 
 	token = AdvanceToken(parser)
-	if !IsType(token, TOKEN_IDENTIFIER) {
-		return parser.expectedTokenType(TOKEN_IDENTIFIER, token)
-	}
+	if IsType(token, TOKEN_ROUND_OPEN) {
+		field.typeLine = token.line
+		addModifier(field, FIELD_TUPLE)
 
-	typeDecl.typeLine = token.line
-	typeDecl.typeName = token.tokenValue.string
+		result := parseTupleElements(parser, field)
+		if !result.success {
+			return result
+		}
+	} else if IsKeyword(token, KEYWORD_FN) {
+		field.typeLine = token.line
+		addModifier(field, FIELD_FUNCTION)
 
-	token = AdvanceToken(parser)
-	if !IsType(token, TOKEN_CURLY_OPEN) {
-		return parser.expectedTokenType(TOKEN_CURLY_OPEN, token)
+		result := parseFunctionType(parser, field, followedByName && !is_array_type)
+		if !result.success {
+			return result
+		}
+	} else if IsType(token, TOKEN_IDENTIFIER) {
+		field.typeLine = token.line
+		field.typeName = token.tokenValue.string
+	} else {
+		return parser.expectedTokenType(TOKEN_IDENTIFIER, token)
 	}
 
 	token = PeekToken(parser)
-	if IsType(token, TOKEN_CURLY_CLOSE) {
+	if IsType(token, TOKEN_NULLABLE) {
+		addModifier(field, FIELD_NULLABLE)
 		AdvanceToken(parser)
-		return parserOk()
 	}
 
-	for {
-		var result ParserResult
-
-		token := PeekToken(parser)
-		if IsKeyword(token, KEYWORD_FUNC) {
-			funcDecl := FuncDecl{}
-			result = parseFunctionDeclaration(parser, &funcDecl)
-			typeDecl.methods = append(typeDecl.methods, funcDecl)
-		} else {
+	if is_array_type {
+		token = AdvanceToken(parser)
+		if !IsType(token, TOKEN_SQUARE_CLOSE) {
+			return parser.expectedTokenType(TOKEN_SQUARE_CLOSE, token)
+		}
+	}
+
+	return parserOk()
+}
+
+func parseTypeField(parser *Parser, field *Field) ParserResult {
+	token := PeekToken(parser)
+	if IsKeyword(token, KEYWORD_CONST) {
+		addModifier(field, FIELD_CONST)
+		AdvanceToken(parser)
+	}
+
+	//
+	// Parse the field type
+	//
+	result := parseFieldType(parser, field, true)
+	if !result.success {
+		return result
+	}
+
+	//
+	// Parse the field variable name
+	//
+	token = AdvanceToken(parser)
+	if !IsType(token, TOKEN_IDENTIFIER) {
+		return parser.expectedTokenType(TOKEN_IDENTIFIER, token)
+	}
+
+	field.varLine = token.line
+	field.varName = token.tokenValue.string
+
+	return parserOk()
+}
+
+func parseFunctionDeclaration(parser *Parser, funcDecl *FuncDecl) ParserResult {
+	AdvanceToken(parser)
+
+	token := AdvanceToken(parser)
+	if !IsType(token, TOKEN_IDENTIFIER) {
+		return parser.expectedTokenType(TOKEN_IDENTIFIER, token)
+	}
+
+	funcDecl.line = token.line
+	funcDecl.name = token.tokenValue.string
+
+	token = AdvanceToken(parser)
+	if !IsType(token, TOKEN_ROUND_OPEN) {
+		return parser.expectedTokenType(TOKEN_ROUND_OPEN, token)
+	}
+
+	token = PeekToken(parser)
+	if IsType(token, TOKEN_ROUND_CLOSE) {
+		AdvanceToken(parser)
+	} else {
+		for {
 			field := Field{}
-			result = parseTypeField(parser, &field)
-			typeDecl.fields = append(typeDecl.fields, field)
+			result := parseTypeField(parser, &field)
+			funcDecl.fields = append(funcDecl.fields, field)
+			if !result.success {
+				return result
+			}
+
+			token = PeekToken(parser)
+			if !IsType(token, TOKEN_COMMA) {
+				break
+			}
+
+			AdvanceToken(parser)
 		}
 
-		if !result.success {
-			return result
+		token := AdvanceToken(parser)
+		if !IsType(token, TOKEN_ROUND_CLOSE) {
+			return parser.expectedTokenType(TOKEN_ROUND_CLOSE, token)
+		}
+	}
+
+	token = PeekToken(parser)
+	if IsType(token, TOKEN_IDENTIFIER) {
+		funcDecl.returnLine = token.line
+		funcDecl.returnType = token.tokenValue.string
+		AdvanceToken(parser)
+	} else if IsType(token, TOKEN_ROUND_OPEN) {
+		AdvanceToken(parser)
+		funcDecl.returnLine = token.line
+		funcDecl.returnTuple = &Field{typeLine: token.line, modifiers: FIELD_TUPLE}
+		return parseTupleElements(parser, funcDecl.returnTuple)
+	}
+
+	return parserOk()
+}
+
+func parseCodeBlockDeclaration(parser *Parser, position int) CodeBlock {
+	token := AdvanceToken(parser)
+
+	codeBlock := CodeBlock{
+		line:     token.line,
+		tag:      token.tokenValue.tag,
+		code:     token.tokenValue.string,
+		position: position,
+	}
+
+	return codeBlock
+}
+
+func parseFlagsDeclaration(parser *Parser, flagsDecl *FlagsDecl) ParserResult {
+	token := AdvanceToken(parser)
+	flagsDecl.line = token.line
+
+	token = AdvanceToken(parser)
+	if !IsType(token, TOKEN_IDENTIFIER) {
+		return parser.expectedTokenType(TOKEN_IDENTIFIER, token)
+	}
+
+	flagsDecl.name = token.tokenValue.string
+	flagsDecl.nameLine = token.line
+
+	token = AdvanceToken(parser)
+	if !IsType(token, TOKEN_COLON) {
+		return parser.expectedTokenType(TOKEN_COLON, token)
+	}
+
+	token = AdvanceToken(parser)
+	if !IsType(token, TOKEN_IDENTIFIER) {
+		return parser.expectedTokenType(TOKEN_IDENTIFIER, token)
+	}
+
+	flagsDecl.backingType = token.tokenValue.string
+	flagsDecl.backingLine = token.line
+
+	token = AdvanceToken(parser)
+	if !IsType(token, TOKEN_CURLY_OPEN) {
+		return parser.expectedTokenType(TOKEN_CURLY_OPEN, token)
+	}
+
+	for {
+		token = AdvanceToken(parser)
+		if IsType(token, TOKEN_CURLY_CLOSE) {
+			break
+		}
+
+		if !IsType(token, TOKEN_IDENTIFIER) {
+			return parser.expectedTokenType(TOKEN_IDENTIFIER, token)
+		}
+
+		member := FlagMember{
+			name: token.tokenValue.string,
+			line: token.line,
 		}
+		flagsDecl.members = append(flagsDecl.members, member)
 
 		token = AdvanceToken(parser)
 		if !IsType(token, TOKEN_SEMICOLON) {
 			return parser.expectedTokenType(TOKEN_SEMICOLON, token)
 		}
+	}
+
+	return parserOk()
+}
+
+func parseMixinUse(parser *Parser, typeDecl *TypeDecl) ParserResult {
+	token := AdvanceToken(parser)
+
+	use := MixinUse{
+		line:      token.line,
+		fieldPos:  len(typeDecl.fields),
+		methodPos: len(typeDecl.methods),
+	}
+
+	token = AdvanceToken(parser)
+	if !IsType(token, TOKEN_IDENTIFIER) {
+		return parser.expectedTokenType(TOKEN_IDENTIFIER, token)
+	}
+
+	use.name = token.tokenValue.string
+	use.nameLine = token.line
+	typeDecl.uses = append(typeDecl.uses, use)
+
+	return parserOk()
+}
+
+// Parses both type and mixin declarations, since their bodies share the same syntax.
+func parseTypeDeclaration(parser *Parser, typeDecl *TypeDecl) ParserResult {
+	token := AdvanceToken(parser)
+	typeDecl.line = token.line
+
+	token = AdvanceToken(parser)
+	if !IsType(token, TOKEN_IDENTIFIER) {
+		return parser.expectedTokenType(TOKEN_IDENTIFIER, token)
+	}
+
+	typeDecl.typeLine = token.line
+	typeDecl.typeName = token.tokenValue.string
+
+	token = AdvanceToken(parser)
+	if !IsType(token, TOKEN_CURLY_OPEN) {
+		return parser.expectedTokenType(TOKEN_CURLY_OPEN, token)
+	}
+
+	token = PeekToken(parser)
+	if IsType(token, TOKEN_CURLY_CLOSE) {
+		AdvanceToken(parser)
+		return parserOk()
+	}
+
+	for {
+		token := PeekToken(parser)
+		if IsType(token, TOKEN_EOF) {
+			return parser.expectedTokenType(TOKEN_CURLY_CLOSE, token)
+		}
+
+		if IsType(token, TOKEN_CODE_BLOCK) {
+			codeBlock := parseCodeBlockDeclaration(parser, len(typeDecl.fields))
+			typeDecl.codeBlocks = append(typeDecl.codeBlocks, codeBlock)
+
+			token = PeekToken(parser)
+			if IsType(token, TOKEN_CURLY_CLOSE) {
+				AdvanceToken(parser)
+				break
+			}
+
+			continue
+		}
+
+		result := parseTypeMember(parser, typeDecl)
+		if !result.success {
+			// Members which failed to parse are left out, the rest of the type is still parsed
+			parser.reportError(result)
+			if parser.errorLimitReached() {
+				return parserOk()
+			}
+
+			if synchronizeMember(parser) {
+				break
+			}
+
+			token = PeekToken(parser)
+			if IsType(token, TOKEN_CURLY_CLOSE) {
+				AdvanceToken(parser)
+				break
+			}
+			continue
+		}
 
 		token = PeekToken(parser)
 		if IsType(token, TOKEN_CURLY_CLOSE) {
@@ -342,6 +932,68 @@ func parseTypeDeclaration(parser *Parser, typeDecl *TypeDecl) ParserResult {
 	return parserOk()
 }
 
+// Parses a single field, method or mixin use together with the terminating semicolon
+func parseTypeMember(parser *Parser, typeDecl *TypeDecl) ParserResult {
+	var attributes []Attribute
+	result := parseAttributes(parser, &attributes)
+	if !result.success {
+		return result
+	}
+
+	token := PeekToken(parser)
+	if IsKeyword(token, KEYWORD_FUNC) {
+		funcDecl := FuncDecl{attributes: attributes}
+		result = parseFunctionDeclaration(parser, &funcDecl)
+		if result.success {
+			result = parseSemicolon(parser)
+		}
+		if result.success {
+			typeDecl.methods = append(typeDecl.methods, funcDecl)
+		}
+		return result
+	}
+
+	if IsKeyword(token, KEYWORD_USE) {
+		if len(attributes) > 0 {
+			return parser.parserErrorMessage(CODE_ATTRIBUTES_ON_USE, attributes[0].line, "Attributes cannot be applied to mixin uses.")
+		}
+
+		useCount := len(typeDecl.uses)
+		result = parseMixinUse(parser, typeDecl)
+		if result.success {
+			result = parseSemicolon(parser)
+		}
+		if !result.success {
+			typeDecl.uses = typeDecl.uses[:useCount]
+		}
+		return result
+	}
+
+	field := Field{attributes: attributes}
+	result = parseTypeField(parser, &field)
+	if result.success {
+		result = parseSemicolon(parser)
+	}
+	// A parameter list following the name hints at a misspelled method keyword
+	if !result.success && IsType(token, TOKEN_IDENTIFIER) && IsType(parser.tokenPrev, TOKEN_ROUND_OPEN) {
+		result = withSuggestion(result, token.line, token.tokenValue.string, KEYWORDS)
+	}
+	if result.success {
+		typeDecl.fields = append(typeDecl.fields, field)
+	}
+	return result
+}
+
+func parseSemicolon(parser *Parser) ParserResult {
+	token := AdvanceToken(parser)
+	if !IsType(token, TOKEN_SEMICOLON) {
+		return parser.expectedTokenType(TOKEN_SEMICOLON, token)
+	}
+	return parserOk()
+}
+
+// Parses the whole file. After a syntax error the parser skips to the next member or declaration
+// and carries on, so the returned result describes every error found (up to maxErrors).
 func ParseFile(parser *Parser) ParserResult {
 	for {
 		token := PeekToken(parser)
@@ -349,62 +1001,148 @@ func ParseFile(parser *Parser) ParserResult {
 			break
 		}
 
-		var result ParserResult
-		if IsKeyword(token, KEYWORD_TYPE) {
-			var typeDecl TypeDecl
-			result = parseTypeDeclaration(parser, &typeDecl)
-			parser.structs = append(parser.structs, typeDecl)
-		} else {
-			return parser.expectedKeyword(KEYWORD_TYPE, token)
+		if IsType(token, TOKEN_CODE_BLOCK) {
+			codeBlock := parseCodeBlockDeclaration(parser, len(parser.structs))
+			parser.codeBlocks = append(parser.codeBlocks, codeBlock)
+			continue
+		}
+
+		result := parseDeclaration(parser)
+		if parser.errorLimitReached() {
+			break
 		}
 
 		if !result.success {
-			return result
+			parser.reportError(result)
+			if parser.errorLimitReached() {
+				break
+			}
+			synchronizeDeclaration(parser)
 		}
 	}
 
-	return parserOk()
+	// Lexer errors are reported as soon as the tokens are peeked, possibly ahead of syntax errors found before them
+	sortDiagnostics(parser.diagnostics)
+	return parser.errorsResult()
+}
+
+func parseDeclaration(parser *Parser) ParserResult {
+	var attributes []Attribute
+	result := parseAttributes(parser, &attributes)
+	if !result.success {
+		return result
+	}
+
+	token := PeekToken(parser)
+	if len(attributes) > 0 && (IsKeyword(token, KEYWORD_MIXIN) || IsKeyword(token, KEYWORD_FLAGS)) {
+		// The declaration is still parsed, so that its members are checked as usual
+		message := fmt.Sprintf("Attributes cannot be applied to %s declarations.", token.tokenValue.string)
+		parser.reportError(parser.parserErrorMessage(CODE_ATTRIBUTES_ON_DECLARATION, attributes[0].line, message))
+	}
+
+	if IsKeyword(token, KEYWORD_TYPE) {
+		typeDecl := TypeDecl{attributes: attributes}
+		result = parseTypeDeclaration(parser, &typeDecl)
+		typeDecl.endLine = parser.tokenPrev.line
+		parser.structs = append(parser.structs, typeDecl)
+	} else if IsKeyword(token, KEYWORD_MIXIN) {
+		var mixinDecl TypeDecl
+		result = parseTypeDeclaration(parser, &mixinDecl)
+		mixinDecl.endLine = parser.tokenPrev.line
+		parser.mixins = append(parser.mixins, mixinDecl)
+	} else if IsKeyword(token, KEYWORD_FLAGS) {
+		var flagsDecl FlagsDecl
+		result = parseFlagsDeclaration(parser, &flagsDecl)
+		flagsDecl.endLine = parser.tokenPrev.line
+		if result.success {
+			parser.flags = append(parser.flags, flagsDecl)
+		}
+	} else {
+		// Skip the unexpected token, so that synchronizing always makes progress
+		AdvanceToken(parser)
+		result = parser.expectedKeyword(KEYWORD_TYPE, token)
+		if IsType(token, TOKEN_IDENTIFIER) {
+			result = withSuggestion(result, token.line, token.tokenValue.string, KEYWORDS)
+		}
+		return result
+	}
+
+	return result
+}
+
+// Builds the error reported when a name is declared more than once. The error is positioned at the second declaration.
+// Positions point at the declared names, which are qualified by the enclosing type in case of methods (Type::method).
+func (parser *Parser) redeclarationError(code DiagnosticCode, kind string, name string, first LinePos, second LinePos) ParserResult {
+	identifier := name[strings.LastIndex(name, ":")+1:]
+	message := fmt.Sprintf("%s '%s' was declared multiple times.", kind, name)
+	diagnostic := newNameDiagnostic(code, parser.filepath, second, identifier, message)
+	diagnostic.addRelated(first, nameEnd(first, identifier), fmt.Sprintf("First declaration of '%s'.", name))
+
+	return parserFailure(diagnostic)
+}
+
+// Same as parserErrorMessage, but the error spans the whole name starting at the given position
+func (parser *Parser) nameErrorMessage(code DiagnosticCode, line LinePos, name string, message string) ParserResult {
+	return parserFailure(newNameDiagnostic(code, parser.filepath, line, name, message))
+}
+
+// Attaches a "did you mean" fix to the failure, when one of the candidates is close to the name at the given position
+func withSuggestion(result ParserResult, pos LinePos, name string, candidates []string) ParserResult {
+	diagnostic := result.diagnostic
+	diagnostic.suggestName(pos, name, candidates)
+	return parserFailure(diagnostic)
+}
+
+// Names which can be used as a type of a field, primitives first and then declarations in order
+func (parser *Parser) typeNames() []string {
+	names := slices.Clone(PRIMITIVES)
+	for _, decl := range parser.structs {
+		names = append(names, decl.typeName)
+	}
+	for _, flagsDecl := range parser.flags {
+		names = append(names, flagsDecl.name)
+	}
+	return names
 }
 
-func CheckForTypeRedeclarations(parser *Parser, decl TypeDecl, structSet *StringSet) ParserResult {
+func CheckForTypeRedeclarations(parser *Parser, decl TypeDecl, structSet *StringSet) {
 	if structSet.Contains(decl.typeName) {
 		// Find the first declaration to display more detailed information about it
 		for _, firstDecl := range parser.structs {
 
 			if firstDecl.typeName == decl.typeName {
-				firstDeclare := fmt.Sprintf("  %s:%v:%v First declaration of '%s'.", parser.filepath, firstDecl.line.number, firstDecl.line.offset, firstDecl.typeName)
-				secondDeclare := fmt.Sprintf("  %s:%v:%v Second declaration of '%s'.", parser.filepath, decl.line.number, decl.line.offset, decl.typeName)
-				message := fmt.Sprintf("ERROR: Type '%s' was declared multiple times:\n%s\n%s\n", decl.typeName, firstDeclare, secondDeclare)
-				result := ParserResult{
-					success: false,
-					message: message,
-				}
-				return result
+				parser.reportError(parser.redeclarationError(CODE_REDECLARED_TYPE, "Type", decl.typeName, firstDecl.typeLine, decl.typeLine))
+				return
 			}
 		}
 		// Unreachable
 	}
-
-	return parserOk()
 }
 
-func VerifyFieldType(parser *Parser, field *Field, structSet *StringSet) ParserResult {
+func VerifyFieldType(parser *Parser, field *Field, structSet *StringSet) {
+	if field.hasModifier(FIELD_TUPLE) || field.hasModifier(FIELD_FUNCTION) {
+		for i := range field.elements {
+			VerifyFieldType(parser, &field.elements[i], structSet)
+		}
+
+		if field.result != nil {
+			VerifyFieldType(parser, field.result, structSet)
+		}
+		return
+	}
+
 	if slices.Contains(PRIMITIVES, field.typeName) {
 		addModifier(field, FIELD_PRIMITIVE)
-		return parserOk()
+		return
 	}
 
 	if structSet.Contains(field.typeName) {
-		return parserOk()
-	}
-
-	message := fmt.Sprintf("ERROR @ %s:%v:%v Type of field '%s' was never declared.", parser.filepath, field.typeLine.number, field.typeLine.offset, field.typeName)
-	result := ParserResult{
-		success: false,
-		message: message,
+		return
 	}
 
-	return result
+	message := fmt.Sprintf("Type of field '%s' was never declared.", field.typeName)
+	result := parser.nameErrorMessage(CODE_UNDECLARED_TYPE, field.typeLine, field.typeName, message)
+	parser.reportError(withSuggestion(result, field.typeLine, field.typeName, parser.typeNames()))
 }
 
 func VerifyType(structSet *StringSet, typename string) bool {
@@ -415,95 +1153,673 @@ func VerifyType(structSet *StringSet, typename string) bool {
 	return structSet.Contains(typename)
 }
 
-func VerifyFunctionDeclaration(parser *Parser, parentType TypeDecl, funcDecl *FuncDecl, structSet *StringSet) ParserResult {
+func VerifyFunctionDeclaration(parser *Parser, parentType TypeDecl, funcDecl *FuncDecl, structSet *StringSet) {
 	if funcDecl.returnType != "" && !VerifyType(structSet, funcDecl.returnType) {
-		message := fmt.Sprintf("ERROR @ %s:%v:%v Return type '%s', of method '%s::%s' is undeclared.", parser.filepath, funcDecl.returnLine.number, funcDecl.returnLine.offset, funcDecl.returnType, parentType.typeName, funcDecl.name)
-		result := ParserResult{
-			success: false,
-			message: message,
-		}
+		message := fmt.Sprintf("Return type '%s', of method '%s::%s' is undeclared.", funcDecl.returnType, parentType.typeName, funcDecl.name)
+		result := parser.nameErrorMessage(CODE_UNDECLARED_TYPE, funcDecl.returnLine, funcDecl.returnType, message)
+		parser.reportError(withSuggestion(result, funcDecl.returnLine, funcDecl.returnType, parser.typeNames()))
+	}
 
-		return result
+	if funcDecl.returnTuple != nil {
+		VerifyFieldType(parser, funcDecl.returnTuple, structSet)
 	}
 
 	for i := range funcDecl.fields {
-		field := &funcDecl.fields[i]
-		result := VerifyFieldType(parser, field, structSet)
-		if !result.success {
-			return result
+		VerifyFieldType(parser, &funcDecl.fields[i], structSet)
+	}
+
+	CheckForFieldRedeclarations(parser, funcDecl.fields)
+}
+
+// Reports every field which reuses the name of a field declared before it.
+func CheckForFieldRedeclarations(parser *Parser, fields []Field) {
+	for pos, field := range fields {
+		for _, firstField := range fields[:pos] {
+			if field.varName == firstField.varName {
+				parser.reportError(parser.redeclarationError(CODE_REDECLARED_FIELD, "Field", field.varName, firstField.varLine, field.varLine))
+				break
+			}
+		}
+	}
+}
+
+// Reports every method which reuses the name of a method declared before it in the same type.
+func CheckForMethodRedeclarations(parser *Parser, decl TypeDecl) {
+	for pos, method := range decl.methods {
+		for _, firstMethod := range decl.methods[:pos] {
+			if method.name == firstMethod.name {
+				parser.reportError(parser.redeclarationError(CODE_REDECLARED_METHOD, "Method", decl.typeName+"::"+method.name, firstMethod.line, method.line))
+				break
+			}
 		}
+	}
+}
 
-		result = CheckForFieldRedeclarations(parser, funcDecl.fields, *field, i)
-		if !result.success {
-			return result
+func mixinNames(parser *Parser) []string {
+	names := make([]string, len(parser.mixins))
+	for i, mixin := range parser.mixins {
+		names[i] = mixin.typeName
+	}
+	return names
+}
+
+func findMixin(parser *Parser, name string) *TypeDecl {
+	for i := range parser.mixins {
+		if parser.mixins[i].typeName == name {
+			return &parser.mixins[i]
 		}
 	}
 
-	return parserOk()
+	return nil
+}
+
+// Splices members of every used mixin into copies of the parsed types, which replace parser.structs. Spliced fields
+// keep the positions from the mixin body, so that redeclaration errors point at both the mixin field and the type field.
+func ExpandMixins(parser *Parser) {
+	mixinSet := NewSet(len(parser.mixins))
+	for _, mixin := range parser.mixins {
+		if mixinSet.Contains(mixin.typeName) {
+			for _, firstDecl := range parser.mixins {
+				if firstDecl.typeName == mixin.typeName {
+					parser.reportError(parser.redeclarationError(CODE_REDECLARED_MIXIN, "Mixin", mixin.typeName, firstDecl.typeLine, mixin.typeLine))
+					break
+				}
+			}
+		}
+
+		// Mixins and types share the namespace, whichever is declared later is reported
+		for _, decl := range parser.structs {
+			if decl.typeName == mixin.typeName && !mixinSet.Contains(mixin.typeName) {
+				first, second := decl.typeLine, mixin.typeLine
+				if isBefore(second, first) {
+					first, second = second, first
+				}
+				parser.reportError(parser.redeclarationError(CODE_REDECLARED_TYPE, "Type", mixin.typeName, first, second))
+				break
+			}
+		}
+
+		if len(mixin.uses) > 0 {
+			use := mixin.uses[0]
+			parser.reportError(parser.parserErrorMessage(CODE_NESTED_MIXIN, use.line, fmt.Sprintf("Mixin '%s' cannot use other mixins.", mixin.typeName)))
+		}
+
+		mixinSet.Add(mixin.typeName)
+	}
+
+	if parser.parsedStructs == nil {
+		parser.parsedStructs = parser.structs
+	}
+
+	expanded := make([]TypeDecl, len(parser.parsedStructs))
+	for i, decl := range parser.parsedStructs {
+		decl.fields = cloneFields(decl.fields)
+		decl.methods = cloneMethods(decl.methods)
+		decl.codeBlocks = slices.Clone(decl.codeBlocks)
+		expanded[i] = decl
+		typeDecl := &expanded[i]
+
+		usedSet := NewSet(len(typeDecl.uses))
+		fieldShift, methodShift := 0, 0
+		for _, use := range typeDecl.uses {
+			mixin := findMixin(parser, use.name)
+			if mixin == nil {
+				message := fmt.Sprintf("Mixin '%s' used in type '%s' was never declared.", use.name, typeDecl.typeName)
+				result := parser.nameErrorMessage(CODE_UNDECLARED_MIXIN, use.nameLine, use.name, message)
+				parser.reportError(withSuggestion(result, use.nameLine, use.name, mixinNames(parser)))
+				continue
+			}
+
+			if usedSet.Contains(use.name) {
+				message := fmt.Sprintf("Mixin '%s' is used multiple times in type '%s'.", use.name, typeDecl.typeName)
+				parser.reportError(parser.nameErrorMessage(CODE_DUPLICATE_MIXIN_USE, use.nameLine, use.name, message))
+				continue
+			}
+			usedSet.Add(use.name)
+
+			fieldPos := use.fieldPos + fieldShift
+			typeDecl.fields = slices.Insert(typeDecl.fields, fieldPos, cloneFields(mixin.fields)...)
+			fieldShift += len(mixin.fields)
+
+			methodPos := use.methodPos + methodShift
+			typeDecl.methods = slices.Insert(typeDecl.methods, methodPos, cloneMethods(mixin.methods)...)
+			methodShift += len(mixin.methods)
+
+			typeDecl.codeBlocks = append(typeDecl.codeBlocks, mixin.codeBlocks...)
+		}
+	}
+	parser.structs = expanded
+}
+
+// Copies the methods together with their parameters and returned tuples
+func cloneMethods(methods []FuncDecl) []FuncDecl {
+	cloned := make([]FuncDecl, len(methods))
+	for i, method := range methods {
+		cloned[i] = method
+		cloned[i].fields = cloneFields(method.fields)
+		if method.returnTuple != nil {
+			returnTuple := cloneField(*method.returnTuple)
+			cloned[i].returnTuple = &returnTuple
+		}
+	}
+	return cloned
+}
+
+const (
+	ATTRIBUTE_ONLY   = "only"
+	ATTRIBUTE_EXCEPT = "except"
+	ATTRIBUTE_NAME   = "name"
+)
+
+var ATTRIBUTES = []string{ATTRIBUTE_ONLY, ATTRIBUTE_EXCEPT, ATTRIBUTE_NAME}
+
+// TargetMask is a set of target languages, where each bit corresponds to a value of the Language enum.
+type TargetMask = uint32
+
+const ALL_TARGETS TargetMask = (1<<(PYTHON+1) - 1) &^ (1 << NONE)
+
+func targetBit(language Language) TargetMask {
+	return 1 << language
+}
+
+// Computes the set of targets a declaration is generated for, based on its @only and @except attributes.
+func targetMask(attributes []Attribute) TargetMask {
+	mask := ALL_TARGETS
+	for _, attribute := range attributes {
+		switch attribute.name {
+		case ATTRIBUTE_ONLY:
+			only := TargetMask(0)
+			for _, arg := range attribute.args {
+				only |= targetBit(languageIdentifierToLanguage(arg.key))
+			}
+			mask &= only
+
+		case ATTRIBUTE_EXCEPT:
+			for _, arg := range attribute.args {
+				mask &^= targetBit(languageIdentifierToLanguage(arg.key))
+			}
+		}
+	}
+
+	return mask
+}
+
+func targetMaskToString(mask TargetMask) string {
+	names := make([]string, 0)
+	for language := GO; language <= PYTHON; language++ {
+		if mask&targetBit(language) != 0 {
+			names = append(names, languageToName(language))
+		}
+	}
+
+	return strings.Join(names, ", ")
+}
+
+func isIdentifier(name string) bool {
+	for i, rune := range name {
+		if i == 0 && !unicode.IsLetter(rune) {
+			return false
+		}
+
+		if !unicode.IsLetter(rune) && !unicode.IsDigit(rune) && rune != '_' {
+			return false
+		}
+	}
+
+	return name != ""
 }
 
-func CheckForFieldRedeclarations(parser *Parser, fields []Field, field Field, pos int) ParserResult {
-	for i, otherField := range fields {
-		if i == pos {
+func VerifyAttributes(parser *Parser, attributes []Attribute) {
+	attributeSet := NewSet(len(attributes))
+	for _, attribute := range attributes {
+		if !slices.Contains(ATTRIBUTES, attribute.name) {
+			message := fmt.Sprintf("Unknown attribute '@%s'. Expected one of: %s.", attribute.name, strings.Join(ATTRIBUTES, ", "))
+			result := parser.nameErrorMessage(CODE_UNKNOWN_ATTRIBUTE, attribute.nameLine, attribute.name, message)
+			parser.reportError(withSuggestion(result, attribute.nameLine, attribute.name, ATTRIBUTES))
+			continue
+		}
+
+		if attributeSet.Contains(attribute.name) {
+			parser.reportError(parser.parserErrorMessage(CODE_DUPLICATE_ATTRIBUTE, attribute.nameLine, fmt.Sprintf("Attribute '@%s' is applied multiple times.", attribute.name)))
 			continue
 		}
+		attributeSet.Add(attribute.name)
 
-		if field.varName == otherField.varName {
-			firstDeclare := fmt.Sprintf("  %s:%v:%v First declaration of '%s'.", parser.filepath, field.varLine.number, field.varLine.offset, field.varName)
-			secondDeclare := fmt.Sprintf("  %s:%v:%v Second declaration of '%s'.", parser.filepath, otherField.varLine.number, otherField.varLine.offset, otherField.varName)
-			message := fmt.Sprintf("ERROR: Field '%s' was declared multiple times:\n%s\n%s\n", field.varName, firstDeclare, secondDeclare)
-			result := ParserResult{
-				success: false,
-				message: message,
+		for _, arg := range attribute.args {
+			result := verifyAttributeArg(parser, attribute, arg)
+			if !result.success {
+				parser.reportError(result)
 			}
+		}
+	}
 
-			return result
+	if attributeSet.Contains(ATTRIBUTE_ONLY) && attributeSet.Contains(ATTRIBUTE_EXCEPT) {
+		parser.reportError(parser.parserErrorMessage(CODE_CONFLICTING_ATTRIBUTES, attributes[0].line, "Attributes '@only' and '@except' cannot be applied to the same declaration."))
+	}
+}
+
+func verifyAttributeArg(parser *Parser, attribute Attribute, arg AttributeArg) ParserResult {
+	if languageIdentifierToLanguage(arg.key) == NONE {
+		result := parser.nameErrorMessage(CODE_INVALID_ATTRIBUTE_ARG, arg.line, arg.key, fmt.Sprintf("Unrecognized language identifier '%s' in attribute '@%s'.", arg.key, attribute.name))
+		return withSuggestion(result, arg.line, arg.key, LANGUAGE_IDENTIFIERS)
+	}
+
+	if attribute.name == ATTRIBUTE_NAME {
+		if !arg.hasValue {
+			return parser.parserErrorMessage(CODE_INVALID_ATTRIBUTE_ARG, arg.line, fmt.Sprintf("Attribute '@name' expects a name assigned to language '%s', for example %s=\"name\".", arg.key, arg.key))
+		}
+
+		if !isIdentifier(arg.value) {
+			return parser.parserErrorMessage(CODE_INVALID_ATTRIBUTE_ARG, arg.line, fmt.Sprintf("Name '%s' assigned to language '%s' is not a valid identifier.", arg.value, arg.key))
 		}
+	} else if arg.hasValue {
+		return parser.parserErrorMessage(CODE_INVALID_ATTRIBUTE_ARG, arg.line, fmt.Sprintf("Attribute '@%s' expects only language identifiers.", attribute.name))
 	}
 
 	return parserOk()
 }
 
+// Verifies that a declaration generated for the given targets never references a type excluded from any of them.
+func verifyTargetReference(parser *Parser, mask TargetMask, field Field, typeMasks map[string]TargetMask) {
+	for _, element := range field.elements {
+		verifyTargetReference(parser, mask, element, typeMasks)
+	}
+
+	if field.result != nil {
+		verifyTargetReference(parser, mask, *field.result, typeMasks)
+	}
+
+	typeName := field.typeName
+	line := field.typeLine
+	referencedMask, isDeclared := typeMasks[typeName]
+	if !isDeclared {
+		return
+	}
+
+	missing := mask &^ referencedMask
+	if missing == 0 {
+		return
+	}
+
+	message := fmt.Sprintf("Type '%s' is referenced, but it is excluded from target(s): %s.", typeName, targetMaskToString(missing))
+	parser.reportError(parser.nameErrorMessage(CODE_EXCLUDED_TYPE_REFERENCE, line, typeName, message))
+}
+
+func CheckTargetReferences(parser *Parser) {
+	typeMasks := make(map[string]TargetMask, len(parser.structs))
+	for _, decl := range parser.structs {
+		typeMasks[decl.typeName] = targetMask(decl.attributes)
+	}
+
+	for _, decl := range parser.structs {
+		typeMask := typeMasks[decl.typeName]
+
+		for _, field := range decl.fields {
+			mask := typeMask & targetMask(field.attributes)
+			verifyTargetReference(parser, mask, field, typeMasks)
+		}
+
+		for _, method := range decl.methods {
+			mask := typeMask & targetMask(method.attributes)
+			returnField := Field{typeName: method.returnType, typeLine: method.returnLine}
+			if method.returnTuple != nil {
+				returnField = *method.returnTuple
+			}
+
+			verifyTargetReference(parser, mask, returnField, typeMasks)
+			for _, field := range method.fields {
+				verifyTargetReference(parser, mask, field, typeMasks)
+			}
+		}
+	}
+}
+
+// A declaration as seen by the targets it is generated for, used to find names which collide once @name is applied
+type targetDecl struct {
+	name       string
+	line       LinePos
+	attributes []Attribute
+	mask       TargetMask
+}
+
+// Reports declarations of the same scope which end up with the same name for some target. Declarations sharing
+// the name in the schema are reported by the other redeclaration checks, so only names changed with @name are compared.
+func checkTargetRedeclarations(parser *Parser, code DiagnosticCode, kind string, scope string, decls []targetDecl) {
+	for pos, decl := range decls {
+		for _, firstDecl := range decls[:pos] {
+			if decl.name == firstDecl.name {
+				continue
+			}
+
+			collisions, name := TargetMask(0), ""
+			for language := GO; language <= PYTHON; language++ {
+				if decl.mask&firstDecl.mask&targetBit(language) == 0 {
+					continue
+				}
+
+				resolved := targetName(decl.attributes, language, decl.name)
+				if resolved == targetName(firstDecl.attributes, language, firstDecl.name) {
+					collisions |= targetBit(language)
+					name = resolved
+				}
+			}
+
+			if collisions != 0 {
+				message := fmt.Sprintf("%s '%s' was declared multiple times for target(s): %s.", kind, scope+name, targetMaskToString(collisions))
+				diagnostic := newNameDiagnostic(code, parser.filepath, decl.line, decl.name, message)
+				related := fmt.Sprintf("First declaration of '%s', declared as '%s'.", scope+name, firstDecl.name)
+				diagnostic.addRelated(firstDecl.line, nameEnd(firstDecl.line, firstDecl.name), related)
+				parser.reportError(parserFailure(diagnostic))
+				break
+			}
+		}
+	}
+}
+
+// Verifies that names overridden with @name don't collide with other types, fields or methods.
+func CheckTargetNames(parser *Parser) {
+	types := make([]targetDecl, 0, len(parser.structs)+len(parser.flags))
+	for _, decl := range parser.structs {
+		typeMask := targetMask(decl.attributes)
+		types = append(types, targetDecl{decl.typeName, decl.typeLine, decl.attributes, typeMask})
+
+		fields := make([]targetDecl, len(decl.fields))
+		for i, field := range decl.fields {
+			fields[i] = targetDecl{field.varName, field.varLine, field.attributes, typeMask & targetMask(field.attributes)}
+		}
+		checkTargetRedeclarations(parser, CODE_REDECLARED_FIELD, "Field", "", fields)
+
+		methods := make([]targetDecl, len(decl.methods))
+		for i, method := range decl.methods {
+			methods[i] = targetDecl{method.name, method.line, method.attributes, typeMask & targetMask(method.attributes)}
+		}
+		checkTargetRedeclarations(parser, CODE_REDECLARED_METHOD, "Method", decl.typeName+"::", methods)
+	}
+
+	for _, flagsDecl := range parser.flags {
+		types = append(types, targetDecl{flagsDecl.name, flagsDecl.nameLine, nil, ALL_TARGETS})
+	}
+	checkTargetRedeclarations(parser, CODE_REDECLARED_TYPE, "Type", "", types)
+}
+
+// A field of a type holding another type by value. For tuples the field is the element holding the type.
+type valueReference struct {
+	owner     string
+	fieldName string
+	field     *Field
+}
+
+// Calls the function for the field, or for the elements of a tuple, which hold a declared type by value.
+// Arrays and function types keep their contents behind a pointer, so nothing within them is held by value.
+func forEachValueReference(field *Field, fn func(field *Field)) {
+	if field.hasModifier(FIELD_ARRAY) || field.hasModifier(FIELD_FUNCTION) {
+		return
+	}
+
+	if field.hasModifier(FIELD_TUPLE) {
+		for i := range field.elements {
+			forEachValueReference(&field.elements[i], fn)
+		}
+		return
+	}
+
+	if !field.hasModifier(FIELD_PRIMITIVE) {
+		fn(field)
+	}
+}
+
+// Targets which store fields inline, where a type containing itself by value has infinite size
+const INLINE_TARGETS TargetMask = 1<<GO | 1<<RUST
+
+// Finds types containing themselves by value, directly or through other types. These have infinite size
+// in languages which store fields inline (Go, Rust), so declarations excluded from both are skipped.
+// Each cycle is reported once, at the reference closing it, or the reference is boxed when boxRecursiveTypes is set.
+func CheckForRecursiveTypes(parser *Parser) {
+	const (
+		UNVISITED = iota
+		VISITING
+		VISITED
+	)
+
+	typeIndices := make(map[string]int, len(parser.structs))
+	for i := len(parser.structs) - 1; i >= 0; i-- {
+		if targetMask(parser.structs[i].attributes)&INLINE_TARGETS != 0 {
+			typeIndices[parser.structs[i].typeName] = i
+		}
+	}
+
+	states := make(map[string]int, len(parser.structs))
+	path := make([]valueReference, 0)
+
+	var visit func(decl *TypeDecl)
+	visit = func(decl *TypeDecl) {
+		states[decl.typeName] = VISITING
+		typeMask := targetMask(decl.attributes)
+		for i := range decl.fields {
+			if typeMask&targetMask(decl.fields[i].attributes)&INLINE_TARGETS == 0 {
+				continue
+			}
+
+			fieldName := decl.fields[i].varName
+			forEachValueReference(&decl.fields[i], func(field *Field) {
+				index, found := typeIndices[field.typeName]
+				if !found {
+					return
+				}
+
+				reference := valueReference{owner: decl.typeName, fieldName: fieldName, field: field}
+				switch states[field.typeName] {
+				case VISITING:
+					if parser.boxRecursiveTypes {
+						addModifier(field, FIELD_BOXED)
+						return
+					}
+					// The cycle starts at the first reference made by the type the current field refers to.
+					// References of the current type aren't on the path yet, so a type referring to itself isn't found.
+					start := slices.IndexFunc(path, func(ref valueReference) bool { return ref.owner == field.typeName })
+					if start < 0 {
+						start = len(path)
+					}
+					cycle := append(slices.Clone(path[start:]), reference)
+					parser.reportError(parser.recursiveTypeError(cycle))
+
+				case UNVISITED:
+					path = append(path, reference)
+					visit(&parser.structs[index])
+					path = path[:len(path)-1]
+				}
+			})
+		}
+		states[decl.typeName] = VISITED
+	}
+
+	for i := range parser.structs {
+		retained := targetMask(parser.structs[i].attributes)&INLINE_TARGETS != 0
+		if retained && states[parser.structs[i].typeName] == UNVISITED {
+			visit(&parser.structs[i])
+		}
+	}
+}
+
+// Builds the error reported for a cycle of by-value references. The error is positioned at the last reference,
+// the other ones are listed as related locations.
+func (parser *Parser) recursiveTypeError(cycle []valueReference) ParserResult {
+	steps := make([]string, 0, len(cycle)+1)
+	for _, reference := range cycle {
+		steps = append(steps, reference.owner+"."+reference.fieldName)
+	}
+	steps = append(steps, cycle[0].owner)
+
+	last := cycle[len(cycle)-1]
+	message := fmt.Sprintf("Type '%s' contains itself by value and would have infinite size: %s. "+
+		"Store one of the references in an array or pass --box-recursive to box it.", last.field.typeName, strings.Join(steps, " -> "))
+	diagnostic := newNameDiagnostic(CODE_RECURSIVE_TYPE, parser.filepath, last.field.typeLine, last.field.typeName, message)
+
+	for _, reference := range cycle[:len(cycle)-1] {
+		field := reference.field
+		related := fmt.Sprintf("'%s.%s' holds '%s' by value.", reference.owner, reference.fieldName, field.typeName)
+		diagnostic.addRelated(field.typeLine, nameEnd(field.typeLine, field.typeName), related)
+	}
+
+	return parserFailure(diagnostic)
+}
+
+func VerifyCodeBlocks(parser *Parser, codeBlocks []CodeBlock) {
+	for _, codeBlock := range codeBlocks {
+		if languageIdentifierToLanguage(codeBlock.tag) == NONE {
+			// The tag follows the opening '%'
+			tagLine := codeBlock.line
+			tagLine.offset++
+
+			message := fmt.Sprintf("Unrecognized language identifier '%s' of a code block.", codeBlock.tag)
+			result := parser.nameErrorMessage(CODE_UNKNOWN_LANGUAGE, tagLine, codeBlock.tag, message)
+			parser.reportError(withSuggestion(result, tagLine, codeBlock.tag, LANGUAGE_IDENTIFIERS))
+		}
+	}
+}
+
+func VerifyFlagsDeclaration(parser *Parser, flagsDecl FlagsDecl, structSet *StringSet) {
+	if slices.Contains(PRIMITIVES, flagsDecl.name) {
+		parser.reportError(parser.nameErrorMessage(CODE_RESERVED_NAME, flagsDecl.nameLine, flagsDecl.name, "Declared flags use reserved name for type primitives."))
+	} else if structSet.Contains(flagsDecl.name) {
+		// Find the first declaration, which is either a type or other flags declared earlier
+		firstLine := flagsDecl.nameLine
+		for _, decl := range parser.flags {
+			if decl.name == flagsDecl.name {
+				firstLine = decl.nameLine
+				break
+			}
+		}
+
+		for _, decl := range parser.structs {
+			if decl.typeName == flagsDecl.name {
+				firstLine = decl.typeLine
+				break
+			}
+		}
+
+		parser.reportError(parser.redeclarationError(CODE_REDECLARED_TYPE, "Type", flagsDecl.name, firstLine, flagsDecl.nameLine))
+	}
+
+	bits, isBacking := FLAGS_BACKING_TYPES[flagsDecl.backingType]
+	if !isBacking {
+		message := fmt.Sprintf("Backing type '%s' of flags '%s' must be one of u8, u16, u32 or u64.", flagsDecl.backingType, flagsDecl.name)
+		parser.reportError(parser.nameErrorMessage(CODE_INVALID_FLAGS_BACKING, flagsDecl.backingLine, flagsDecl.backingType, message))
+	} else if len(flagsDecl.members) > bits {
+		overflowing := flagsDecl.members[bits]
+		message := fmt.Sprintf("Flag '%s' overflows backing type '%s' of flags '%s', which only holds %v flags.", overflowing.name, flagsDecl.backingType, flagsDecl.name, bits)
+		parser.reportError(parser.nameErrorMessage(CODE_FLAGS_OVERFLOW, overflowing.line, overflowing.name, message))
+	}
+
+	memberSet := NewSet(len(flagsDecl.members))
+	for _, member := range flagsDecl.members {
+		if memberSet.Contains(member.name) {
+			parser.reportError(parser.parserErrorMessage(CODE_REDECLARED_FLAG, member.line, fmt.Sprintf("Flag '%s' was declared multiple times in flags '%s'.", member.name, flagsDecl.name)))
+		}
+		memberSet.Add(member.name)
+	}
+}
+
+// Checks the parsed declarations. Every problem is reported, not just the first one,
+// and the returned result lists them in the order of their positions in the file.
 func TypecheckFile(parser *Parser) ParserResult {
+	reported := len(parser.diagnostics)
+
+	ExpandMixins(parser)
+	VerifyCodeBlocks(parser, parser.codeBlocks)
+
 	structSet := NewSet(len(parser.structs))
 	// Populate set now with one pass to prevent O(n^2) complexity later
 	for _, decl := range parser.structs {
 		if slices.Contains(PRIMITIVES, decl.typeName) {
-			return parser.parserErrorMessage(decl.line, "Declared type uses reserved name for type primitives.")
+			parser.reportError(parser.nameErrorMessage(CODE_RESERVED_NAME, decl.typeLine, decl.typeName, "Declared type uses reserved name for type primitives."))
 		}
 
-		result := CheckForTypeRedeclarations(parser, decl, structSet)
-		if !result.success {
-			return result
+		CheckForTypeRedeclarations(parser, decl, structSet)
+		structSet.Add(decl.typeName)
+	}
+
+	for _, flagsDecl := range parser.flags {
+		VerifyFlagsDeclaration(parser, flagsDecl, structSet)
+		structSet.Add(flagsDecl.name)
+	}
+
+	// Mixins are checked on their own, so that problems are found even when no type uses them.
+	// The same problems are found again in members spliced into types and are reported only once.
+	for i, mixin := range parser.mixins {
+		VerifyCodeBlocks(parser, mixin.codeBlocks)
+		for j := range mixin.fields {
+			field := &parser.mixins[i].fields[j]
+			VerifyAttributes(parser, field.attributes)
+			VerifyFieldType(parser, field, structSet)
 		}
+		CheckForFieldRedeclarations(parser, mixin.fields)
 
-		structSet.Add(decl.typeName)
+		for j := range mixin.methods {
+			funcDecl := &parser.mixins[i].methods[j]
+			VerifyAttributes(parser, funcDecl.attributes)
+			VerifyFunctionDeclaration(parser, mixin, funcDecl, structSet)
+		}
+		CheckForMethodRedeclarations(parser, mixin)
 	}
 
 	for i, decl := range parser.structs {
+		VerifyAttributes(parser, decl.attributes)
+		VerifyCodeBlocks(parser, decl.codeBlocks)
+
 		for j := range decl.fields {
 			field := &parser.structs[i].fields[j]
-			result := VerifyFieldType(parser, field, structSet)
-			if !result.success {
-				return result
-			}
-
-			result = CheckForFieldRedeclarations(parser, decl.fields, *field, j)
-			if !result.success {
-				return result
-			}
+			VerifyAttributes(parser, field.attributes)
+			VerifyFieldType(parser, field, structSet)
 		}
+		CheckForFieldRedeclarations(parser, decl.fields)
 
 		for j := range decl.methods {
 			funcDecl := &parser.structs[i].methods[j]
-			result := VerifyFunctionDeclaration(parser, decl, funcDecl, structSet)
-			if !result.success {
-				return result
-			}
+			VerifyAttributes(parser, funcDecl.attributes)
+			VerifyFunctionDeclaration(parser, decl, funcDecl, structSet)
 		}
+		CheckForMethodRedeclarations(parser, decl)
 	}
 
-	return parserOk()
+	CheckTargetReferences(parser)
+	CheckTargetNames(parser)
+	CheckForRecursiveTypes(parser)
+
+	parser.diagnostics = append(parser.diagnostics[:reported], dropRepeatedDiagnostics(parser.diagnostics[reported:])...)
+	sortDiagnostics(parser.diagnostics[reported:])
+	return parser.errorsResult()
+}
+
+// Keeps the first of the diagnostics reported with the same code at the same position
+func dropRepeatedDiagnostics(diagnostics []Diagnostic) []Diagnostic {
+	kept := make([]Diagnostic, 0, len(diagnostics))
+	for _, diagnostic := range diagnostics {
+		repeated := slices.ContainsFunc(kept, func(other Diagnostic) bool {
+			return other.code == diagnostic.code && other.start == diagnostic.start
+		})
+		if !repeated {
+			kept = append(kept, diagnostic)
+		}
+	}
+	return kept
+}
+
+func isBefore(a LinePos, b LinePos) bool {
+	if a.number != b.number {
+		return a.number < b.number
+	}
+	return a.offset < b.offset
+}
+
+// Sorts diagnostics by their start position, keeping the order of diagnostics reported at the same position
+func sortDiagnostics(diagnostics []Diagnostic) {
+	slices.SortStableFunc(diagnostics, func(a Diagnostic, b Diagnostic) int {
+		if a.start.number != b.start.number {
+			return a.start.number - b.start.number
+		}
+		return a.start.offset - b.start.offset
+	})
 }
 
 type StringSet struct {
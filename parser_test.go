@@ -1,6 +1,7 @@
 package main
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -14,10 +15,13 @@ func makeTokenWithValue(tokenType TokenType, val string) Token {
 	}
 }
 
-func mockLexer(tokens []Token) Lexer {
+func mockLexer(t *testing.T, tokens []Token) Lexer {
 	lexer := CreateLexer([]byte(""))
 	tokenIndex := 0
 
+	lexNextToken := nextToken
+	t.Cleanup(func() { nextToken = lexNextToken })
+
 	nextToken = func(lexer *Lexer) Token {
 		if tokenIndex < len(tokens) {
 			tok := tokens[tokenIndex]
@@ -73,7 +77,7 @@ func TestSingleCatType(t *testing.T) {
 		makeTokenWithValue(TOKEN_EOF, ""),
 	)
 
-	lexer := mockLexer(tokens)
+	lexer := mockLexer(t, tokens)
 	parser := createParserWithLexer(lexer)
 
 	result := ParseFile(&parser)
@@ -147,3 +151,375 @@ func TestSingleCatType(t *testing.T) {
 		return
 	}
 }
+
+func parseAndTypecheck(t *testing.T, source string) (Parser, ParserResult) {
+	parser := CreateParserFromData("test", []byte(source))
+
+	result := ParseFile(&parser)
+	if !result.success {
+		t.Fatal(result.message)
+	}
+
+	return parser, TypecheckFile(&parser)
+}
+
+func TestMixinExpansion(t *testing.T) {
+	parser, success := CreateParser("test/audit.tg")
+	if !success {
+		t.Fatal("Failed to open test/audit.tg")
+	}
+
+	result := ParseFile(&parser)
+	if !result.success {
+		t.Fatal(result.message)
+	}
+
+	result = TypecheckFile(&parser)
+	if !result.success {
+		t.Fatal(result.message)
+	}
+
+	order := &parser.structs[0]
+	expectedFields := []string{"id", "createdAt", "createdBy", "items"}
+	if len(order.fields) != len(expectedFields) {
+		t.Fatalf("Expected %v fields in Order, found %v", len(expectedFields), len(order.fields))
+	}
+
+	for i, field := range order.fields {
+		if field.varName != expectedFields[i] {
+			t.Errorf("Expected field[%v] to be '%v', found '%v'", i, expectedFields[i], field.varName)
+		}
+	}
+
+	if len(order.methods) != 1 || order.methods[0].name != "touch" {
+		t.Errorf("Expected method 'touch' to be spliced into Order")
+	}
+
+	invoice := &parser.structs[1]
+	if len(invoice.fields) != 3 || invoice.fields[0].varName != "createdAt" || invoice.fields[2].varName != "total" {
+		t.Errorf("Expected mixin fields to precede 'total' in Invoice")
+	}
+}
+
+func TestMixinFieldCollision(t *testing.T) {
+	source := "mixin Audited {\n    u64 createdAt;\n}\n\ntype Order {\n    use Audited;\n    u64 createdAt;\n}\n"
+	_, result := parseAndTypecheck(t, source)
+	if result.success {
+		t.Fatal("Expected field collision between mixin and type to be reported")
+	}
+
+	// Both the mixin field and the type field location must be present
+	if !strings.Contains(result.message, "test:2:9") || !strings.Contains(result.message, "test:7:9") {
+		t.Errorf("Expected both declaration sites in message, got: %v", result.message)
+	}
+}
+
+func TestAttributesOnMixinsAndFlags(t *testing.T) {
+	source := "@only(go) mixin M {\n    u32 x;\n}\ntype A {\n    use M;\n}\n@except(js) flags F : u8 { A; }\n"
+	parser := CreateParserFromData("test", []byte(source))
+	result := ParseFile(&parser)
+	if result.success || len(parser.diagnostics) != 2 {
+		t.Fatalf("Expected two misplaced attributes to be reported, found:\n%v", result.message)
+	}
+
+	expected := []string{"test:1:1 Attributes cannot be applied to mixin declarations.", "test:7:1 Attributes cannot be applied to flags declarations."}
+	for i, diagnostic := range parser.diagnostics {
+		if diagnostic.code != CODE_ATTRIBUTES_ON_DECLARATION || !strings.Contains(diagnostic.Error(), expected[i]) {
+			t.Errorf("Expected '%v', found: %v", expected[i], diagnostic.Error())
+		}
+	}
+
+	// The declarations themselves are still parsed
+	if len(parser.mixins) != 1 || len(parser.flags) != 1 {
+		t.Errorf("Expected the mixin and the flags to be parsed, found %v mixins and %v flags", len(parser.mixins), len(parser.flags))
+	}
+}
+
+func TestMixinTypeNameCollision(t *testing.T) {
+	sources := []string{"mixin A {\n    u32 x;\n}\ntype A {\n    use A;\n}\n", "type A {\n    use A;\n}\nmixin A {\n    u32 x;\n}\n"}
+	for _, source := range sources {
+		parser, result := parseAndTypecheck(t, source)
+		if result.success || len(parser.diagnostics) != 1 {
+			t.Errorf("Expected a single redeclaration, found:\n%v", result.message)
+			continue
+		}
+
+		diagnostic := parser.diagnostics[0]
+		if diagnostic.code != CODE_REDECLARED_TYPE || diagnostic.start.number != 4 || len(diagnostic.related) != 1 || diagnostic.related[0].start.number != 1 {
+			t.Errorf("Expected the later declaration to be reported with the first one as a related location, found: %v", diagnostic.Error())
+		}
+	}
+}
+
+func TestUnusedMixinIsChecked(t *testing.T) {
+	parser, result := parseAndTypecheck(t, "mixin M {\n    Nope x;\n}\n")
+	if result.success || len(parser.diagnostics) != 1 || parser.diagnostics[0].code != CODE_UNDECLARED_TYPE {
+		t.Fatalf("Expected the undeclared type of an unused mixin to be reported, found:\n%v", result.message)
+	}
+
+	// Problems within a mixin are reported once, no matter how many types use it
+	parser, result = parseAndTypecheck(t, "mixin M {\n    Nope x;\n}\ntype A {\n    use M;\n}\ntype B {\n    use M;\n}\n")
+	if len(parser.diagnostics) != 1 {
+		t.Errorf("Expected a single error, found:\n%v", result.message)
+	}
+}
+
+func TestTypecheckKeepsParsedTypes(t *testing.T) {
+	parser, result := parseAndTypecheck(t, "mixin M {\n    u32 x;\n}\ntype A {\n    use M;\n    u32 y;\n}\n")
+	if !result.success {
+		t.Fatal(result.message)
+	}
+
+	result = TypecheckFile(&parser)
+	if !result.success || len(parser.structs[0].fields) != 2 {
+		t.Errorf("Expected typechecking again to splice the mixin once, found %v fields:\n%v", len(parser.structs[0].fields), result.message)
+	}
+	if len(parser.parsedStructs[0].fields) != 1 {
+		t.Errorf("Expected the parsed type to be left without the mixin fields, found %v fields", len(parser.parsedStructs[0].fields))
+	}
+}
+
+func TestUndeclaredMixin(t *testing.T) {
+	_, result := parseAndTypecheck(t, "type Order {\n    use Audited;\n}\n")
+	if result.success {
+		t.Fatal("Expected undeclared mixin to be reported")
+	}
+}
+
+func TestFlagsOverflow(t *testing.T) {
+	_, result := parseAndTypecheck(t, "flags Small : u8 { A; B; C; D; E; F; G; H; I; }\n")
+	if result.success {
+		t.Fatal("Expected 9 flags to overflow u8")
+	}
+
+	if !strings.Contains(result.message, "'I'") {
+		t.Errorf("Expected the overflowing flag to be reported, got: %v", result.message)
+	}
+
+	_, result = parseAndTypecheck(t, "flags Signed : i32 { A; }\n")
+	if result.success {
+		t.Fatal("Expected signed backing type to be rejected")
+	}
+}
+
+func TestTupleTypes(t *testing.T) {
+	source := "type Car {\n    [(u32, (string, bool))?] path;\n    func locate() (u32, string);\n}\n"
+	parser, result := parseAndTypecheck(t, source)
+	if !result.success {
+		t.Fatal(result.message)
+	}
+
+	path := &parser.structs[0].fields[0]
+	if !path.hasModifier(FIELD_ARRAY) || !path.hasModifier(FIELD_TUPLE) || !path.hasModifier(FIELD_NULLABLE) {
+		t.Fatalf("Expected 'path' to be an array of nullable tuples, modifiers: %v", path.modifiers)
+	}
+
+	if len(path.elements) != 2 || path.elements[0].typeName != "u32" || !path.elements[0].hasModifier(FIELD_PRIMITIVE) {
+		t.Fatalf("Expected first element of 'path' to be primitive u32")
+	}
+
+	nested := &path.elements[1]
+	if !nested.hasModifier(FIELD_TUPLE) || len(nested.elements) != 2 || nested.elements[1].typeName != "bool" {
+		t.Errorf("Expected second element of 'path' to be tuple (string, bool)")
+	}
+
+	locate := &parser.structs[0].methods[0]
+	if locate.returnType != "" || locate.returnTuple == nil || len(locate.returnTuple.elements) != 2 {
+		t.Errorf("Expected 'locate' to return a tuple of two elements")
+	}
+}
+
+func TestSingleElementTuple(t *testing.T) {
+	parser := CreateParserFromData("test", []byte("type Car {\n    (u32) position;\n}\n"))
+	result := ParseFile(&parser)
+	if result.success {
+		t.Fatal("Expected tuple with a single element to be rejected")
+	}
+}
+
+func TestFunctionTypes(t *testing.T) {
+	source := "type Widget {\n    fn(string, u32) bool onChange;\n    fn() onClick;\n    [fn(u32) Widget] handlers;\n}\n"
+	parser, result := parseAndTypecheck(t, source)
+	if !result.success {
+		t.Fatal(result.message)
+	}
+
+	onChange := &parser.structs[0].fields[0]
+	if !onChange.hasModifier(FIELD_FUNCTION) || onChange.varName != "onChange" || len(onChange.elements) != 2 {
+		t.Fatalf("Expected 'onChange' to be a function of two parameters")
+	}
+
+	if onChange.result == nil || onChange.result.typeName != "bool" || !onChange.result.hasModifier(FIELD_PRIMITIVE) {
+		t.Errorf("Expected 'onChange' to return primitive bool")
+	}
+
+	// Without a following identifier, 'onClick' is the field name and not the return type
+	onClick := &parser.structs[0].fields[1]
+	if onClick.varName != "onClick" || onClick.result != nil || len(onClick.elements) != 0 {
+		t.Errorf("Expected 'onClick' to be a function without parameters and result")
+	}
+
+	handlers := &parser.structs[0].fields[2]
+	if !handlers.hasModifier(FIELD_ARRAY) || handlers.result == nil || handlers.result.typeName != "Widget" {
+		t.Errorf("Expected 'handlers' to be an array of functions returning Widget")
+	}
+}
+
+func TestUndeclaredFunctionParameter(t *testing.T) {
+	_, result := parseAndTypecheck(t, "type Widget {\n    fn(Event) onChange;\n}\n")
+	if result.success {
+		t.Fatal("Expected undeclared type of a function parameter to be rejected")
+	}
+}
+
+func TestParserReportsEveryError(t *testing.T) {
+	source := "type A {\n    u32 ;\n    string name;\n    [u32 list;\n}\ntype {\n    u32 x;\n}\ntype B { u32 z; }\n"
+	parser := CreateParserFromData("test", []byte(source))
+	result := ParseFile(&parser)
+	if result.success {
+		t.Fatal("Expected the file to fail parsing")
+	}
+
+	expectedLines := []int{2, 4, 6}
+	if len(parser.diagnostics) != len(expectedLines) {
+		t.Fatalf("Expected %v errors, found %v:\n%v", len(expectedLines), len(parser.diagnostics), result.message)
+	}
+
+	for i, line := range expectedLines {
+		diagnostic := parser.diagnostics[i]
+		if diagnostic.start.number != line || diagnostic.code != CODE_UNEXPECTED_TOKEN {
+			t.Errorf("Expected error %v to be reported at line %v, found: %v", i, line, diagnostic.Error())
+		}
+	}
+
+	// Members and declarations following the errors are still parsed
+	if len(parser.structs) != 3 || parser.structs[2].typeName != "B" {
+		t.Fatalf("Expected type B to be parsed after the errors")
+	}
+
+	fields := parser.structs[0].fields
+	if len(fields) != 1 || fields[0].varName != "name" {
+		t.Errorf("Expected only the field 'name' of type A to be parsed, found %v fields", len(fields))
+	}
+}
+
+func TestParserReportsLexerErrors(t *testing.T) {
+	source := "type A {\n    u32 \xff age;\n    string $name;\n    @name(go=\"x) u8 y;\n}\n"
+	parser := CreateParserFromData("test", []byte(source))
+	ParseFile(&parser)
+
+	expectedCodes := []DiagnosticCode{CODE_INVALID_RUNE_ENCODING, CODE_UNKNOWN_SYMBOL, CODE_UNCLOSED_STRING}
+	if len(parser.diagnostics) < len(expectedCodes) {
+		t.Fatalf("Expected at least %v errors, found %v", len(expectedCodes), len(parser.diagnostics))
+	}
+
+	for i, code := range expectedCodes {
+		diagnostic := parser.diagnostics[i]
+		if diagnostic.code != code || diagnostic.start.number != i+2 {
+			t.Errorf("Expected error %v with code %v at line %v, found: %v", i, code, i+2, diagnostic.Error())
+		}
+	}
+
+	if !strings.Contains(parser.diagnostics[0].message, "byte 0xFF at byte offset 17") {
+		t.Errorf("Expected the invalid byte and its offset in the message: %v", parser.diagnostics[0].message)
+	}
+
+	// Fields around the invalid characters are still parsed
+	fields := parser.structs[0].fields
+	if len(fields) < 2 || fields[0].varName != "age" || fields[1].varName != "name" {
+		t.Errorf("Expected fields 'age' and 'name' to be parsed, found %v fields", len(fields))
+	}
+}
+
+func TestParserEmptyFile(t *testing.T) {
+	parser, result := parseAndTypecheck(t, "")
+	if !result.success || len(parser.diagnostics) != 0 {
+		t.Errorf("Expected an empty file to be valid, found:\n%v", result.message)
+	}
+}
+
+func TestParserErrorLimit(t *testing.T) {
+	source := "type A {\n    u32 ;\n    u32 ;\n    u32 ;\n}\n"
+	parser := CreateParserFromData("test", []byte(source))
+	parser.maxErrors = 2
+
+	result := ParseFile(&parser)
+	if result.success || len(parser.diagnostics) != 2 {
+		t.Fatalf("Expected parsing to stop after 2 errors, found %v", len(parser.diagnostics))
+	}
+
+	if !strings.HasSuffix(result.message, "Stopped after 2 errors.") {
+		t.Errorf("Expected the message to mention the error limit: %v", result.message)
+	}
+}
+
+func TestParserUnclosedType(t *testing.T) {
+	parser := CreateParserFromData("test", []byte("type A {\n    u32 a;\n    u32\n"))
+	result := ParseFile(&parser)
+	if result.success || len(parser.diagnostics) != 2 {
+		t.Fatalf("Expected the missing field name and the unclosed type to be reported, found:\n%v", result.message)
+	}
+}
+
+func TestTypecheckReportsEveryError(t *testing.T) {
+	source := "type B {\n    Detials d;\n    func f();\n    func f();\n}\ntype A {\n    string name;\n    u32 name;\n    (Foo, u8) pair;\n}\ntype u32 {}\n"
+	parser, result := parseAndTypecheck(t, source)
+	if result.success {
+		t.Fatal("Expected the file to fail typechecking")
+	}
+
+	// Errors are sorted by position, even though primitive names are checked before fields
+	expectedErrors := []string{"Detials", "'B::f'", "'name'", "'Foo'", "reserved name"}
+	if len(parser.diagnostics) != len(expectedErrors) {
+		t.Fatalf("Expected %v errors, found %v:\n%v", len(expectedErrors), len(parser.diagnostics), result.message)
+	}
+
+	for i, expected := range expectedErrors {
+		if !strings.Contains(parser.diagnostics[i].message, expected) {
+			t.Errorf("Expected error %v to mention %v, found: %v", i, expected, parser.diagnostics[i].message)
+		}
+	}
+}
+
+func TestRecursiveTypes(t *testing.T) {
+	source := "type Node {\n    Node next;\n    [Node] children;\n}\ntype A {\n    B b;\n}\ntype B {\n    (u32, A) pair;\n    fn(B) callback;\n}\n"
+	parser, result := parseAndTypecheck(t, source)
+	if result.success || len(parser.diagnostics) != 2 {
+		t.Fatalf("Expected two recursive type errors, found:\n%v", result.message)
+	}
+
+	expectedPaths := []string{"Node.next -> Node.", "A.b -> B.pair -> A."}
+	for i, path := range expectedPaths {
+		diagnostic := parser.diagnostics[i]
+		if diagnostic.code != CODE_RECURSIVE_TYPE || !strings.Contains(diagnostic.message, path) {
+			t.Errorf("Expected the cycle '%v' to be reported, found: %v", path, diagnostic.Error())
+		}
+	}
+
+	if related := parser.diagnostics[1].related; len(related) != 1 || related[0].start.number != 6 {
+		t.Errorf("Expected the field 'A.b' as a related location: %v", parser.diagnostics[1].Error())
+	}
+}
+
+func TestRecursiveTypesOfReferenceLanguages(t *testing.T) {
+	source := "@only(java, kotlin) type Node {\n    Node next;\n}\ntype Tree {\n    @except(go, rust) Tree parent;\n}\n"
+	_, result := parseAndTypecheck(t, source)
+	if !result.success {
+		t.Errorf("Expected recursive types generated only for languages with references to be accepted:\n%v", result.message)
+	}
+}
+
+func TestBoxedRecursiveTypes(t *testing.T) {
+	parser := CreateParserFromData("test", []byte("type Node {\n    Node next;\n    (Node, u32) pair;\n}\n"))
+	parser.boxRecursiveTypes = true
+	ParseFile(&parser)
+	result := TypecheckFile(&parser)
+	if !result.success {
+		t.Fatal(result.message)
+	}
+
+	fields := parser.structs[0].fields
+	if !fields[0].hasModifier(FIELD_BOXED) || !fields[1].elements[0].hasModifier(FIELD_BOXED) {
+		t.Errorf("Expected both references to 'Node' to be boxed")
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Plugins are executables generating code for languages not built into pocketgen. A plugin receives a pluginRequest
+// as JSON on its standard input and answers with a pluginResponse as JSON on its standard output. Anything the plugin
+// writes to the standard error is shown only when it fails, which is signalled by a non-zero exit code.
+
+// Version of the protocol, increased whenever a field is removed or changes its meaning
+const PLUGIN_PROTOCOL_VERSION = 1
+
+type pluginOptions struct {
+	Indent               int    `json:"indent"`
+	PackageName          string `json:"packageName"`
+	ReceiverNameFallback string `json:"receiverNameFallback"`
+	JsonAnnotations      bool   `json:"jsonAnnotations"`
+	// Passed with --plugin-opt key=value, their meaning is up to the plugin
+	Parameters map[string]string `json:"parameters"`
+}
+
+type pluginRequest struct {
+	Version int    `json:"version"`
+	File    string `json:"file"`
+	// The typechecked schema in the same form as written by 'dump typed'
+	Schema  jsonDeclarationsDump `json:"schema"`
+	Options pluginOptions        `json:"options"`
+}
+
+// pluginFile is a generated file, named relative to the directory of the schema file
+type pluginFile struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
+
+type pluginResponse struct {
+	Files       []pluginFile     `json:"files"`
+	Diagnostics []jsonDiagnostic `json:"diagnostics"`
+}
+
+func newPluginRequest(parser *Parser, options GeneratorOptions, parameters map[string]string) pluginRequest {
+	if parameters == nil {
+		parameters = make(map[string]string)
+	}
+
+	return pluginRequest{
+		Version: PLUGIN_PROTOCOL_VERSION,
+		File:    parser.filepath,
+		Schema:  dumpDeclarations(parser, DUMP_TYPED),
+		Options: pluginOptions{
+			Indent:               options.indent,
+			PackageName:          options.packageName,
+			ReceiverNameFallback: options.receiverNameFallback,
+			JsonAnnotations:      options.jsonAnnotations,
+			Parameters:           parameters,
+		},
+	}
+}
+
+// Runs the plugin (a path, or a name looked up in PATH) over a typechecked file. Files and diagnostics returned
+// by the plugin are validated, failures of the plugin itself are returned as a diagnostic error.
+func runPlugin(plugin string, parser *Parser, options GeneratorOptions, parameters map[string]string) ([]pluginFile, []Diagnostic, error) {
+	failure := func(format string, args ...any) error {
+		message := fmt.Sprintf("Plugin '%s' ", plugin) + fmt.Sprintf(format, args...)
+		return newDiagnostic(CODE_PLUGIN_FAILURE, parser.filepath, LinePos{}, message)
+	}
+
+	request, err := json.Marshal(newPluginRequest(parser, options, parameters))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stdout := bytes.Buffer{}
+	stderr := bytes.Buffer{}
+	command := exec.Command(plugin)
+	command.Stdin = bytes.NewReader(request)
+	command.Stdout = &stdout
+	command.Stderr = &stderr
+
+	err = command.Run()
+	if err != nil {
+		output := strings.TrimSpace(stderr.String())
+		if output != "" {
+			return nil, nil, failure("failed: %v:\n%s", err, output)
+		}
+		return nil, nil, failure("failed: %v.", err)
+	}
+
+	var response pluginResponse
+	err = json.Unmarshal(stdout.Bytes(), &response)
+	if err != nil {
+		return nil, nil, failure("returned an invalid response: %v.", err)
+	}
+
+	for _, file := range response.Files {
+		// Plugins may only write next to the schema file or into its subdirectories
+		if !filepath.IsLocal(file.Name) {
+			return nil, nil, failure("attempted to write a file outside of the output directory: '%s'.", file.Name)
+		}
+	}
+
+	diagnostics := make([]Diagnostic, 0, len(response.Diagnostics))
+	for _, converted := range response.Diagnostics {
+		diagnostic := fromJSONDiagnostic(converted)
+		if diagnostic.code == "" {
+			diagnostic.code = CODE_PLUGIN_FAILURE
+		}
+		if diagnostic.file == "" {
+			diagnostic.file = parser.filepath
+		}
+		diagnostics = append(diagnostics, diagnostic)
+	}
+	return response.Files, diagnostics, nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// Builds the example plugin from plugins/tg-gen-markdown into a temporary directory
+func buildMarkdownPlugin(t *testing.T) string {
+	plugin := filepath.Join(t.TempDir(), "tg-gen-markdown")
+	output, err := exec.Command("go", "build", "-o", plugin, "./plugins/tg-gen-markdown").CombinedOutput()
+	if err != nil {
+		t.Skipf("Failed to build the plugin: %v\n%s", err, output)
+	}
+	return plugin
+}
+
+func TestPlugin(t *testing.T) {
+	plugin := buildMarkdownPlugin(t)
+	parser, result := parseAndTypecheck(t, "type Cat {\n    [u32?] ages;\n    func meow(string sound) bool;\n}\ntype Empty {}\n")
+	if !result.success {
+		t.Fatal(result.message)
+	}
+
+	files, diagnostics, err := runPlugin(plugin, &parser, defaultOptions(), map[string]string{"title": "Animals"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 1 || files[0].Name != "test.md" {
+		t.Fatalf("Expected a single markdown file, found %+v", files)
+	}
+	for _, expected := range []string{"# Animals", "## Cat", "| `ages` | `[u32?]` |", "- `meow(string sound) bool`"} {
+		if !strings.Contains(files[0].Content, expected) {
+			t.Errorf("Expected %q in generated file:\n%v", expected, files[0].Content)
+		}
+	}
+
+	if len(diagnostics) != 1 {
+		t.Fatalf("Expected a single warning about the empty type, found %v", diagnostics)
+	}
+	warning := diagnostics[0]
+	if warning.code != "MD001" || warning.severity != SEVERITY_WARNING || warning.file != "test" || warning.start != (LinePos{number: 5, offset: 6}) {
+		t.Errorf("Unexpected warning: %v", warning.Error())
+	}
+}
+
+func TestPluginFailures(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Shell scripts are used as plugins")
+	}
+
+	parser, result := parseAndTypecheck(t, "type Cat {}\n")
+	if !result.success {
+		t.Fatal(result.message)
+	}
+
+	directory := t.TempDir()
+	scripts := map[string]string{
+		"escaping": "#!/bin/sh\ncat > /dev/null\necho '{\"files\": [{\"name\": \"../cat.md\", \"content\": \"\"}]}'\n",
+		"invalid":  "#!/bin/sh\ncat > /dev/null\necho 'not json'\n",
+		"failing":  "#!/bin/sh\ncat > /dev/null\necho 'out of memory' >&2\nexit 3\n",
+	}
+	expected := map[string]string{
+		"escaping": "outside of the output directory",
+		"invalid":  "invalid response",
+		"failing":  "out of memory",
+	}
+
+	for name, script := range scripts {
+		plugin := filepath.Join(directory, name)
+		err := os.WriteFile(plugin, []byte(script), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, _, err = runPlugin(plugin, &parser, defaultOptions(), nil)
+		var diagnostic Diagnostic
+		if !errors.As(err, &diagnostic) || diagnostic.code != CODE_PLUGIN_FAILURE || !strings.Contains(diagnostic.message, expected[name]) {
+			t.Errorf("Expected plugin '%v' to fail with %q, found: %v", name, expected[name], err)
+		}
+	}
+}
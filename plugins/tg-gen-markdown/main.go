@@ -0,0 +1,198 @@
+// Command tg-gen-markdown is an example pocketgen plugin documenting schemas in Markdown.
+// It reads a request from the standard input and writes a response to the standard output:
+//
+//	tg test/cat.tg --plugin=tg-gen-markdown --plugin-opt title=Animals
+//
+// Types without any fields are reported as warnings.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Only the parts of the protocol used by the plugin are declared
+
+type position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+type field struct {
+	Name      string   `json:"name"`
+	Type      string   `json:"type"`
+	Modifiers []string `json:"modifiers"`
+	Elements  []field  `json:"elements"`
+	Result    *field   `json:"result"`
+}
+
+type method struct {
+	Name        string  `json:"name"`
+	Params      []field `json:"params"`
+	ReturnType  string  `json:"returnType"`
+	ReturnTuple *field  `json:"returnTuple"`
+}
+
+type typeDecl struct {
+	Name      string   `json:"name"`
+	NameStart position `json:"nameStart"`
+	Fields    []field  `json:"fields"`
+	Methods   []method `json:"methods"`
+}
+
+type flagsDecl struct {
+	Name        string `json:"name"`
+	BackingType string `json:"backingType"`
+	Members     []struct {
+		Name  string `json:"name"`
+		Value uint64 `json:"value"`
+	} `json:"members"`
+}
+
+type request struct {
+	Version int    `json:"version"`
+	File    string `json:"file"`
+	Schema  struct {
+		Types []typeDecl  `json:"types"`
+		Flags []flagsDecl `json:"flags"`
+	} `json:"schema"`
+	Options struct {
+		Parameters map[string]string `json:"parameters"`
+	} `json:"options"`
+}
+
+type file struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
+
+type diagnostic struct {
+	Code     string   `json:"code"`
+	Severity string   `json:"severity"`
+	Start    position `json:"start"`
+	End      position `json:"end"`
+	Message  string   `json:"message"`
+}
+
+type response struct {
+	Files       []file       `json:"files"`
+	Diagnostics []diagnostic `json:"diagnostics"`
+}
+
+func has(modifiers []string, modifier string) bool {
+	for _, m := range modifiers {
+		if m == modifier {
+			return true
+		}
+	}
+	return false
+}
+
+// Writes the type back in the schema syntax
+func typeString(f field) string {
+	var typeName string
+	switch {
+	case has(f.Modifiers, "tuple"):
+		elements := make([]string, len(f.Elements))
+		for i, element := range f.Elements {
+			elements[i] = typeString(element)
+		}
+		typeName = "(" + strings.Join(elements, ", ") + ")"
+	case has(f.Modifiers, "function"):
+		params := make([]string, len(f.Elements))
+		for i, param := range f.Elements {
+			params[i] = typeString(param)
+		}
+		typeName = "fn(" + strings.Join(params, ", ") + ")"
+		if f.Result != nil {
+			typeName += " " + typeString(*f.Result)
+		}
+	default:
+		typeName = f.Type
+	}
+
+	if has(f.Modifiers, "nullable") {
+		typeName += "?"
+	}
+	if has(f.Modifiers, "array") {
+		typeName = "[" + typeName + "]"
+	}
+	return typeName
+}
+
+func generate(req request) response {
+	resp := response{Files: []file{}, Diagnostics: []diagnostic{}}
+
+	title := req.Options.Parameters["title"]
+	if title == "" {
+		title = strings.TrimSuffix(filepath.Base(req.File), filepath.Ext(req.File))
+	}
+
+	doc := strings.Builder{}
+	fmt.Fprintf(&doc, "# %s\n", title)
+	for _, decl := range req.Schema.Types {
+		fmt.Fprintf(&doc, "\n## %s\n", decl.Name)
+		if len(decl.Fields) == 0 {
+			end := decl.NameStart
+			end.Column += len(decl.Name) - 1
+			resp.Diagnostics = append(resp.Diagnostics, diagnostic{
+				Code:     "MD001",
+				Severity: "warning",
+				Start:    decl.NameStart,
+				End:      end,
+				Message:  fmt.Sprintf("Type '%s' has no fields to document.", decl.Name),
+			})
+		} else {
+			doc.WriteString("\n| Field | Type |\n|-------|------|\n")
+			for _, f := range decl.Fields {
+				fmt.Fprintf(&doc, "| `%s` | `%s` |\n", f.Name, typeString(f))
+			}
+		}
+
+		for _, m := range decl.Methods {
+			params := make([]string, len(m.Params))
+			for i, param := range m.Params {
+				params[i] = typeString(param) + " " + param.Name
+			}
+			returns := m.ReturnType
+			if m.ReturnTuple != nil {
+				returns = typeString(*m.ReturnTuple)
+			}
+			fmt.Fprintf(&doc, "\n- `%s(%s) %s`\n", m.Name, strings.Join(params, ", "), returns)
+		}
+	}
+
+	for _, flags := range req.Schema.Flags {
+		fmt.Fprintf(&doc, "\n## %s : %s\n\n", flags.Name, flags.BackingType)
+		for _, member := range flags.Members {
+			fmt.Fprintf(&doc, "- `%s` = %v\n", member.Name, member.Value)
+		}
+	}
+
+	name := strings.TrimSuffix(filepath.Base(req.File), filepath.Ext(req.File)) + ".md"
+	resp.Files = append(resp.Files, file{Name: name, Content: doc.String()})
+	return resp
+}
+
+func main() {
+	var req request
+	err := json.NewDecoder(os.Stdin).Decode(&req)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid request:", err)
+		os.Exit(1)
+	}
+
+	if req.Version != 1 {
+		fmt.Fprintf(os.Stderr, "unsupported protocol version %v\n", req.Version)
+		os.Exit(1)
+	}
+
+	err = json.NewEncoder(os.Stdout).Encode(generate(req))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to write response:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Generator turns a typechecked schema into source files of a single target language.
+// Every built-in generator is listed in GENERATORS, which the CLI, help text and tests are driven by.
+type Generator interface {
+	language() Language
+	// Canonical identifier of the language, accepted on the command line and in attributes
+	name() string
+	// Other identifiers of the language, such as 'golang'
+	aliases() []string
+	// Extension of generated files, including the dot
+	extension() string
+	// Names which cannot be used for declarations, fields and parameters
+	keywords() []string
+	// Converts a primitive type name, declared type names are kept
+	mapType(typeName string) string
+	// Renders the type of a field whose type names were already converted with mapType
+	typeString(field Field) string
+	capabilities() Capability
+	generate(schema *Schema) ([]GeneratedFile, error)
+	// Places a generated file within an output directory following the conventions of the language. The path
+	// is relative to the directory of the input, such as 'pets/cat.java' when generating from 'schemas/pets/cat.tg'
+	// with 'schemas' as the input.
+	layoutPath(relative string) string
+}
+
+// GeneratedFile is a file written by a generator. The path is relative to the working directory.
+type GeneratedFile struct {
+	path    string
+	content []byte
+}
+
+// Capability is a construct or option which a generator may not support. Schemas using an unsupported construct
+// are still generated, but the loss is reported as a warning.
+type Capability = uint32
+
+const (
+	CAPABILITY_METHODS Capability = 1 << iota
+	CAPABILITY_TUPLES
+	CAPABILITY_FUNCTION_TYPES
+	CAPABILITY_FLAGS
+	CAPABILITY_CODE_BLOCKS
+	// Nullable types are distinguished from the non-nullable ones
+	CAPABILITY_NULLABLE
+	// Fields are annotated for JSON serialization with --json
+	CAPABILITY_JSON_ANNOTATIONS
+	// Types are declared as records with --java.records
+	CAPABILITY_RECORDS
+	// Generated code is declared in the package set with --<language>.package
+	CAPABILITY_PACKAGES
+	// Declarations are written to files of their own with --<language>.per-type
+	CAPABILITY_FILE_PER_TYPE
+)
+
+const CAPABILITIES_ALL_CONSTRUCTS = CAPABILITY_METHODS | CAPABILITY_TUPLES | CAPABILITY_FUNCTION_TYPES | CAPABILITY_FLAGS | CAPABILITY_CODE_BLOCKS | CAPABILITY_NULLABLE
+
+// Built-in generators, created with the given options
+var GENERATORS = []func(options GeneratorOptions) Generator{
+	func(options GeneratorOptions) Generator { return &GoGenerator{options} },
+	func(options GeneratorOptions) Generator { return &JavascriptGenerator{options} },
+	func(options GeneratorOptions) Generator { return &JavaGenerator{options} },
+	func(options GeneratorOptions) Generator { return &KotlinGenerator{options} },
+	func(options GeneratorOptions) Generator { return &RustGenerator{options} },
+}
+
+func allGenerators(options GeneratorOptions) []Generator {
+	generators := make([]Generator, len(GENERATORS))
+	for i, create := range GENERATORS {
+		generators[i] = create(options)
+	}
+	return generators
+}
+
+// Finds the generator by its name or any of the aliases (case insensitive), nil when there is none
+func findGenerator(identifier string, options GeneratorOptions) Generator {
+	identifier = strings.ToLower(identifier)
+	for _, generator := range allGenerators(options) {
+		if generator.name() == identifier {
+			return generator
+		}
+		for _, alias := range generator.aliases() {
+			if alias == identifier {
+				return generator
+			}
+		}
+	}
+	return nil
+}
+
+// The subset of LANGUAGE_IDENTIFIERS naming a generator
+func generatorIdentifiers() []string {
+	identifiers := make([]string, 0)
+	for _, identifier := range LANGUAGE_IDENTIFIERS {
+		if findGenerator(identifier, defaultOptions()) != nil {
+			identifiers = append(identifiers, identifier)
+		}
+	}
+	return identifiers
+}
+
+// Writes the declarations of the schema as source code, either all of them or only the named one (see writesDeclaration)
+type declarationWriter = func(schema *Schema, declaration string, writer *bytes.Buffer) error
+
+// Generates the single file named after the schema file with the extension of the generator
+func generateSingleFile(schema *Schema, extension string, write declarationWriter) ([]GeneratedFile, error) {
+	buffer := bytes.Buffer{}
+	err := write(schema, "", &buffer)
+	if err != nil {
+		return nil, err
+	}
+
+	// cat.tg -> cat.js
+	return []GeneratedFile{{path: changeExtension(schema.filepath, extension), content: buffer.Bytes()}}, nil
+}
+
+// Generates a file for every flags and type declaration of the target, placed next to the schema file and named
+// by fileName after the declaration: pets/cat.tg -> pets/Cat.java, pets/Owner.java
+func generateFilePerType(schema *Schema, language Language, options GeneratorOptions, fileName func(name string) string, write declarationWriter) ([]GeneratedFile, error) {
+	resolved := resolveTarget(schema, language, options)
+	names := make([]string, 0, len(resolved.flags)+len(resolved.types))
+	for _, flags := range resolved.flags {
+		names = append(names, flags.name)
+	}
+	for _, t := range resolved.types {
+		names = append(names, t.typeName)
+	}
+
+	directory := filepath.Dir(schema.filepath)
+	files := make([]GeneratedFile, 0, len(names))
+	for _, name := range names {
+		buffer := bytes.Buffer{}
+		err := write(schema, name, &buffer)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, GeneratedFile{path: filepath.Join(directory, fileName(name)), content: buffer.Bytes()})
+	}
+	return files, nil
+}
+
+func unsupportedConstruct(generator Generator, file string, pos LinePos, name string, message string) Diagnostic {
+	message = fmt.Sprintf("%s, which is not supported by the %s generator.", message, generator.name())
+	diagnostic := newNameDiagnostic(CODE_UNSUPPORTED_CONSTRUCT, file, pos, name, message)
+	diagnostic.severity = SEVERITY_WARNING
+	return diagnostic
+}
+
+// Places the file in the directory of the package within the source root, or mirrors the input directory
+// when there is no package. Packages are separated with the separator, such as '.' in Java.
+func packageLayoutPath(sourceRoot string, packageName string, separator string, relative string) string {
+	directory := filepath.Dir(relative)
+	if packageName != "" {
+		directory = filepath.Join(strings.Split(packageName, separator)...)
+	}
+	return filepath.Join(sourceRoot, directory, filepath.Base(relative))
+}
+
+// Reports constructs which the generator cannot express, in the schema as seen by its target
+func checkCapabilities(generator Generator, schema *Schema) []Diagnostic {
+	capabilities := generator.capabilities()
+	resolved := resolveTarget(schema, generator.language(), defaultOptions())
+	file := schema.filepath
+	diagnostics := make([]Diagnostic, 0)
+
+	var checkField func(field Field, name string)
+	checkField = func(field Field, name string) {
+		if field.hasModifier(FIELD_NULLABLE) && capabilities&CAPABILITY_NULLABLE == 0 {
+			diagnostics = append(diagnostics, unsupportedConstruct(generator, file, field.typeLine, field.typeName, fmt.Sprintf("Type of %s is nullable", name)))
+		}
+		if field.hasModifier(FIELD_TUPLE) && capabilities&CAPABILITY_TUPLES == 0 {
+			diagnostics = append(diagnostics, unsupportedConstruct(generator, file, field.typeLine, "(", fmt.Sprintf("Type of %s is a tuple", name)))
+		}
+		if field.hasModifier(FIELD_FUNCTION) && capabilities&CAPABILITY_FUNCTION_TYPES == 0 {
+			diagnostics = append(diagnostics, unsupportedConstruct(generator, file, field.typeLine, KEYWORD_FN, fmt.Sprintf("Type of %s is a function type", name)))
+		}
+
+		for _, element := range field.elements {
+			checkField(element, name)
+		}
+		if field.result != nil {
+			checkField(*field.result, name)
+		}
+	}
+
+	for _, flags := range resolved.flags {
+		if capabilities&CAPABILITY_FLAGS == 0 {
+			diagnostics = append(diagnostics, unsupportedConstruct(generator, file, flags.nameLine, flags.name, fmt.Sprintf("'%s' are flags", flags.name)))
+		}
+	}
+
+	for _, t := range resolved.types {
+		for _, field := range t.fields {
+			checkField(field, fmt.Sprintf("field '%s'", field.varName))
+		}
+
+		for _, method := range t.methods {
+			if capabilities&CAPABILITY_METHODS == 0 {
+				diagnostics = append(diagnostics, unsupportedConstruct(generator, file, method.line, method.name, fmt.Sprintf("'%s' is a method", method.name)))
+				continue
+			}
+
+			for _, param := range method.fields {
+				checkField(param, fmt.Sprintf("parameter '%s'", param.varName))
+			}
+			if method.returnTuple != nil {
+				checkField(*method.returnTuple, fmt.Sprintf("the result of method '%s'", method.name))
+			}
+		}
+
+		for _, block := range t.codeBlocks {
+			if capabilities&CAPABILITY_CODE_BLOCKS == 0 {
+				diagnostics = append(diagnostics, unsupportedConstruct(generator, file, block.line, block.tag, "Code block is tagged with the language"))
+			}
+		}
+	}
+
+	for _, block := range resolved.codeBlocks {
+		if capabilities&CAPABILITY_CODE_BLOCKS == 0 {
+			diagnostics = append(diagnostics, unsupportedConstruct(generator, file, block.line, block.tag, "Code block is tagged with the language"))
+		}
+	}
+
+	return diagnostics
+}
+
+// Lists options which the generator ignores, named as on the command line without the dashes
+func unsupportedOptions(generator Generator, options GeneratorOptions) []string {
+	unsupported := make([]string, 0)
+	if options.jsonAnnotations && generator.capabilities()&CAPABILITY_JSON_ANNOTATIONS == 0 {
+		unsupported = append(unsupported, "json")
+	}
+	if options.records && generator.capabilities()&CAPABILITY_RECORDS == 0 {
+		unsupported = append(unsupported, "records")
+	}
+	if options.packageName != "" && generator.capabilities()&CAPABILITY_PACKAGES == 0 {
+		unsupported = append(unsupported, "package")
+	}
+	if options.filePerType && generator.capabilities()&CAPABILITY_FILE_PER_TYPE == 0 {
+		unsupported = append(unsupported, "per-type")
+	}
+	return unsupported
+}
+
+func (goGen *GoGenerator) language() Language { return GO }
+func (goGen *GoGenerator) name() string       { return "go" }
+func (goGen *GoGenerator) aliases() []string  { return []string{"golang"} }
+func (goGen *GoGenerator) extension() string  { return ".go" }
+func (goGen *GoGenerator) keywords() []string { return GO_KEYWORDS }
+func (goGen *GoGenerator) mapType(typeName string) string {
+	return toGoType(typeName)
+}
+
+// Nullability is not expressed, since pointers are reserved for boxed fields
+func (goGen *GoGenerator) capabilities() Capability {
+	return CAPABILITIES_ALL_CONSTRUCTS&^CAPABILITY_NULLABLE | CAPABILITY_JSON_ANNOTATIONS | CAPABILITY_PACKAGES | CAPABILITY_FILE_PER_TYPE
+}
+
+// Name of the package in the package clause, the last element of the package path
+func (goGen *GoGenerator) packageClause() string {
+	if goGen.options.packageName == "" {
+		return "main"
+	}
+	return path.Base(goGen.options.packageName)
+}
+
+// Packages are directories named by the package path, files of the main package mirror the input
+func (goGen *GoGenerator) layoutPath(relative string) string {
+	return packageLayoutPath("", goGen.options.packageName, "/", relative)
+}
+
+// Files of single types are named in snake case: PetShop -> pet_shop.go
+func (goGen *GoGenerator) generate(schema *Schema) ([]GeneratedFile, error) {
+	if goGen.options.filePerType {
+		fileName := func(name string) string { return toSnakeCase(name) + goGen.extension() }
+		return generateFilePerType(schema, GO, goGen.options, fileName, goGen.write)
+	}
+	return generateSingleFile(schema, goGen.extension(), goGen.write)
+}
+
+func (js *JavascriptGenerator) language() Language { return JAVASCRIPT }
+func (js *JavascriptGenerator) name() string       { return "js" }
+func (js *JavascriptGenerator) aliases() []string  { return []string{"javascript"} }
+func (js *JavascriptGenerator) extension() string  { return ".js" }
+
+// Reserved words are allowed as names of properties and methods
+func (js *JavascriptGenerator) keywords() []string { return nil }
+func (js *JavascriptGenerator) mapType(typeName string) string {
+	return toJSDocType(typeName)
+}
+func (js *JavascriptGenerator) typeString(field Field) string {
+	return js.jsDocType(field)
+}
+func (js *JavascriptGenerator) capabilities() Capability {
+	return CAPABILITIES_ALL_CONSTRUCTS | CAPABILITY_FILE_PER_TYPE
+}
+func (js *JavascriptGenerator) layoutPath(relative string) string {
+	return relative
+}
+
+// Files of single types are ES modules, exporting the declaration and importing the ones it references
+func (js *JavascriptGenerator) generate(schema *Schema) ([]GeneratedFile, error) {
+	if js.options.filePerType {
+		fileName := func(name string) string { return name + js.extension() }
+		return generateFilePerType(schema, JAVASCRIPT, js.options, fileName, js.write)
+	}
+	return generateSingleFile(schema, js.extension(), js.write)
+}
+
+func (java *JavaGenerator) language() Language { return JAVA }
+func (java *JavaGenerator) name() string       { return "java" }
+func (java *JavaGenerator) aliases() []string  { return nil }
+func (java *JavaGenerator) extension() string  { return ".java" }
+func (java *JavaGenerator) keywords() []string { return JAVA_KEYWORDS }
+func (java *JavaGenerator) mapType(typeName string) string {
+	return toJavaType(typeName)
+}
+
+// Nullable primitives are not boxed and reference types are always nullable
+func (java *JavaGenerator) capabilities() Capability {
+	return CAPABILITIES_ALL_CONSTRUCTS&^CAPABILITY_NULLABLE | CAPABILITY_RECORDS | CAPABILITY_PACKAGES | CAPABILITY_FILE_PER_TYPE
+}
+
+// Maven and Gradle layout: src/main/java/com/example/pets/Cat.java
+func (java *JavaGenerator) layoutPath(relative string) string {
+	return packageLayoutPath(filepath.Join("src", "main", "java"), java.options.packageName, ".", relative)
+}
+
+// Declarations are public only in files of their own, which Java requires to be named after the public class
+func (java *JavaGenerator) generate(schema *Schema) ([]GeneratedFile, error) {
+	if java.options.filePerType {
+		fileName := func(name string) string { return name + java.extension() }
+		return generateFilePerType(schema, JAVA, java.options, fileName, java.write)
+	}
+	return generateSingleFile(schema, java.extension(), java.write)
+}
+
+func (kotlin *KotlinGenerator) language() Language { return KOTLIN }
+func (kotlin *KotlinGenerator) name() string       { return "kotlin" }
+func (kotlin *KotlinGenerator) aliases() []string  { return []string{"kt"} }
+func (kotlin *KotlinGenerator) extension() string  { return ".kt" }
+func (kotlin *KotlinGenerator) keywords() []string { return KOTLIN_KEYWORDS }
+func (kotlin *KotlinGenerator) mapType(typeName string) string {
+	return toKotlinType(typeName)
+}
+func (kotlin *KotlinGenerator) capabilities() Capability {
+	return CAPABILITIES_ALL_CONSTRUCTS | CAPABILITY_PACKAGES | CAPABILITY_FILE_PER_TYPE
+}
+func (kotlin *KotlinGenerator) layoutPath(relative string) string {
+	return packageLayoutPath(filepath.Join("src", "main", "kotlin"), kotlin.options.packageName, ".", relative)
+}
+
+func (kotlin *KotlinGenerator) generate(schema *Schema) ([]GeneratedFile, error) {
+	if kotlin.options.filePerType {
+		fileName := func(name string) string { return name + kotlin.extension() }
+		return generateFilePerType(schema, KOTLIN, kotlin.options, fileName, kotlin.write)
+	}
+	return generateSingleFile(schema, kotlin.extension(), kotlin.write)
+}
+
+func (rust *RustGenerator) language() Language { return RUST }
+func (rust *RustGenerator) name() string       { return "rust" }
+func (rust *RustGenerator) aliases() []string  { return []string{"rs"} }
+func (rust *RustGenerator) extension() string  { return ".rs" }
+func (rust *RustGenerator) keywords() []string { return RUST_KEYWORDS }
+func (rust *RustGenerator) mapType(typeName string) string {
+	return toRustType(typeName)
+}
+func (rust *RustGenerator) capabilities() Capability {
+	return CAPABILITIES_ALL_CONSTRUCTS
+}
+
+// Cargo layout, files are modules named in snake case: src/pet_shop.rs
+func (rust *RustGenerator) layoutPath(relative string) string {
+	module := toSnakeCase(strings.TrimSuffix(filepath.Base(relative), rust.extension()))
+	return filepath.Join("src", filepath.Dir(relative), module+rust.extension())
+}
+
+func (rust *RustGenerator) generate(schema *Schema) ([]GeneratedFile, error) {
+	return generateSingleFile(schema, rust.extension(), rust.write)
+}
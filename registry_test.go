@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestGeneratorRegistry(t *testing.T) {
+	names := make([]string, 0)
+	for _, generator := range allGenerators(defaultOptions()) {
+		identifiers := append([]string{generator.name()}, generator.aliases()...)
+		for _, identifier := range identifiers {
+			if languageIdentifierToLanguage(identifier) != generator.language() {
+				t.Errorf("[%v] Identifier '%v' is not recognized as the language of the generator", generator.name(), identifier)
+			}
+			if found := findGenerator(identifier, defaultOptions()); found == nil || found.name() != generator.name() {
+				t.Errorf("[%v] Expected the generator to be found by '%v'", generator.name(), identifier)
+			}
+			if !slices.Contains(generatorIdentifiers(), identifier) {
+				t.Errorf("[%v] Identifier '%v' is missing from the language identifiers", generator.name(), identifier)
+			}
+		}
+
+		if slices.Contains(names, generator.name()) {
+			t.Errorf("Generator '%v' is registered multiple times", generator.name())
+		}
+		names = append(names, generator.name())
+	}
+
+	if findGenerator("ts", defaultOptions()) != nil || findGenerator("swift", defaultOptions()) != nil {
+		t.Errorf("Expected no generators for TypeScript and Swift")
+	}
+}
+
+func TestGeneratedFiles(t *testing.T) {
+	parser, result := parseAndTypecheck(t, "type Cat {\n    u32 age;\n}\n")
+	if !result.success {
+		t.Fatal(result.message)
+	}
+	parser.filepath = "animals/cat.tg"
+	schema := parser.Schema()
+
+	for _, generator := range allGenerators(defaultOptions()) {
+		files, err := generator.generate(&schema)
+		if err != nil {
+			t.Errorf("[%v] %v", generator.name(), err)
+			continue
+		}
+
+		expected := "animals/cat" + generator.extension()
+		if len(files) != 1 || files[0].path != expected || len(files[0].content) == 0 {
+			t.Errorf("[%v] Expected a single file '%v', found %v", generator.name(), expected, files)
+		}
+	}
+}
+
+func TestGeneratorsShareSchema(t *testing.T) {
+	source := "flags Perm : u8 {\n    READ;\n}\ntype Widget {\n    (u32, [string?]) pair;\n    fn(string, u32) bool onChange;\n    Perm perm;\n    @name(go=\"Size\") u64 size;\n" +
+		"    func split(u32 at, fn(u8) (u8, i64) cb) (u32, string);\n}\n"
+	parser, result := parseAndTypecheck(t, source)
+	if !result.success {
+		t.Fatal(result.message)
+	}
+	schema := parser.Schema()
+	typed := bytes.Buffer{}
+	writeDump(&typed, dumpDeclarations(&parser, DUMP_TYPED))
+
+	// Every generator runs twice over the same schema, the second run following every other generator
+	generators := allGenerators(defaultOptions())
+	first := make(map[string][]GeneratedFile)
+	for round := 0; round < 2; round++ {
+		for _, generator := range generators {
+			files, err := generator.generate(&schema)
+			if err != nil {
+				t.Fatalf("[%v] %v", generator.name(), err)
+			}
+
+			if round == 0 {
+				first[generator.name()] = files
+			} else if !bytes.Equal(files[0].content, first[generator.name()][0].content) {
+				t.Errorf("[%v] Expected the same output after other generators ran, found:\n%s", generator.name(), files[0].content)
+			}
+		}
+	}
+
+	after := bytes.Buffer{}
+	writeDump(&after, dumpDeclarations(&parser, DUMP_TYPED))
+	if after.String() != typed.String() {
+		t.Errorf("Expected generators to leave the schema unchanged, found:\n%v", after.String())
+	}
+}
+
+func TestLayoutPaths(t *testing.T) {
+	packaged := defaultOptions()
+	packaged.packageName = "com.example.pets"
+	goPackaged := defaultOptions()
+	goPackaged.packageName = "internal/pets"
+
+	tests := []struct {
+		generator Generator
+		relative  string
+		expected  string
+	}{
+		{&GoGenerator{defaultOptions()}, "shop/cat.go", "shop/cat.go"},
+		{&GoGenerator{goPackaged}, "shop/cat.go", "internal/pets/cat.go"},
+		{&JavaGenerator{defaultOptions()}, "shop/cat.java", "src/main/java/shop/cat.java"},
+		{&JavaGenerator{packaged}, "shop/cat.java", "src/main/java/com/example/pets/cat.java"},
+		{&KotlinGenerator{packaged}, "cat.kt", "src/main/kotlin/com/example/pets/cat.kt"},
+		{&RustGenerator{defaultOptions()}, "shop/PetShop.rs", "src/shop/pet_shop.rs"},
+		{&JavascriptGenerator{defaultOptions()}, "shop/cat.js", "shop/cat.js"},
+	}
+
+	for _, test := range tests {
+		if actual := test.generator.layoutPath(filepath.FromSlash(test.relative)); actual != filepath.FromSlash(test.expected) {
+			t.Errorf("[%v] Expected '%v' to be laid out as '%v', found '%v'", test.generator.name(), test.relative, test.expected, actual)
+		}
+	}
+}
+
+func TestPackageDeclarations(t *testing.T) {
+	parser, result := parseAndTypecheck(t, "type Cat {\n    u32 age;\n}\n")
+	if !result.success {
+		t.Fatal(result.message)
+	}
+	schema := parser.Schema()
+
+	options := defaultOptions()
+	options.packageName = "com.example.pets"
+	goOptions := defaultOptions()
+	goOptions.packageName = "internal/pets"
+
+	expectations := []struct {
+		generator Generator
+		prefix    string
+	}{
+		{&GoGenerator{defaultOptions()}, "package main\n"},
+		{&GoGenerator{goOptions}, "package pets\n"},
+		{&JavaGenerator{options}, "package com.example.pets;\n"},
+		{&JavaGenerator{defaultOptions()}, "class Cat {"},
+		{&KotlinGenerator{options}, "package com.example.pets\n"},
+	}
+
+	for _, expected := range expectations {
+		files, err := expected.generator.generate(&schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if content := string(files[0].content); !strings.HasPrefix(content, expected.prefix) {
+			t.Errorf("[%v] Expected the file to start with %q, found:\n%v", expected.generator.name(), expected.prefix, content)
+		}
+	}
+}
+
+func TestFilePerType(t *testing.T) {
+	source := "flags Perm : u8 {\n    READ;\n}\ntype Owner {\n    string name;\n    Perm perm;\n}\n" +
+		"type PetShop {\n    Owner owner;\n    func sell(u32 id) (u32, string);\n}\n"
+	parser, result := parseAndTypecheck(t, source)
+	if !result.success {
+		t.Fatal(result.message)
+	}
+	parser.filepath = "pets/shop.tg"
+	schema := parser.Schema()
+
+	options := defaultOptions()
+	options.filePerType = true
+
+	// Expected files, each starting with the given text
+	expectations := map[string]map[string]string{
+		"go": {
+			"pets/perm.go":     "package main\n\ntype Perm uint8",
+			"pets/owner.go":    "package main\n\ntype Owner struct",
+			"pets/pet_shop.go": "package main\n\ntype PetShop struct",
+		},
+		"java": {
+			"pets/Perm.java":    "import java.util.EnumSet;\n\npublic enum Perm {",
+			"pets/Owner.java":   "import java.util.EnumSet;\n\npublic class Owner {",
+			"pets/PetShop.java": "public class PetShop {\n    Owner owner;\n\n    public PetShop(Owner owner) {",
+		},
+		"js": {
+			"pets/Perm.js":    "export const Perm = ",
+			"pets/Owner.js":   "import { Perm } from \"./Perm.js\";\n\nexport class Owner {",
+			"pets/PetShop.js": "import { Owner } from \"./Owner.js\";\n\nexport class PetShop {",
+		},
+		"kotlin": {
+			"pets/Perm.kt":    "@JvmInline\nvalue class Perm(",
+			"pets/Owner.kt":   "data class Owner(",
+			"pets/PetShop.kt": "data class PetShop(",
+		},
+	}
+
+	for name, expected := range expectations {
+		generator := findGenerator(name, options)
+		files, err := generator.generate(&schema)
+		if err != nil {
+			t.Fatalf("[%v] %v", name, err)
+		}
+		if len(files) != len(expected) {
+			t.Errorf("[%v] Expected %v files, found %v", name, len(expected), len(files))
+		}
+
+		for _, file := range files {
+			prefix, found := expected[filepath.ToSlash(file.path)]
+			if !found {
+				t.Errorf("[%v] Unexpected file '%v'", name, file.path)
+			} else if !strings.HasPrefix(string(file.content), prefix) {
+				t.Errorf("[%v] Expected '%v' to start with %q, found:\n%s", name, file.path, prefix, file.content)
+			}
+		}
+	}
+
+	if unsupported := unsupportedOptions(&RustGenerator{options}, options); !slices.Equal(unsupported, []string{"per-type"}) {
+		t.Errorf("Expected a file per type to be unsupported by Rust, found %v", unsupported)
+	}
+}
+
+func TestFilePerTypeHeaderCodeBlocks(t *testing.T) {
+	parser, result := parseAndTypecheck(t, "%go{ import \"fmt\" }%\nflags P : u8 {\n    A;\n}\ntype B {\n    u32 x;\n}\n")
+	if !result.success {
+		t.Fatal(result.message)
+	}
+	parser.filepath = "pets/shop.tg"
+	schema := parser.Schema()
+
+	options := defaultOptions()
+	options.filePerType = true
+	files, err := findGenerator("go", options).generate(&schema)
+	if err != nil || len(files) != 2 {
+		t.Fatalf("Expected two files, found %v files and error %v", len(files), err)
+	}
+
+	// Header blocks are written only to the file of the first declaration
+	for _, file := range files {
+		imports := strings.Count(string(file.content), "import \"fmt\"")
+		if expected := map[string]int{"pets/p.go": 1, "pets/b.go": 0}[filepath.ToSlash(file.path)]; imports != expected {
+			t.Errorf("Expected '%v' to hold the header %v times, found:\n%s", file.path, expected, file.content)
+		}
+	}
+}
+
+func TestUnsupportedConstructs(t *testing.T) {
+	parser, result := parseAndTypecheck(t, "type Cat {\n    string? name;\n    @only(kotlin) u32? age;\n}\n")
+	if !result.success {
+		t.Fatal(result.message)
+	}
+	schema := parser.Schema()
+
+	expected := map[string]int{"go": 1, "java": 1, "js": 0, "kotlin": 0, "rust": 0}
+	for _, generator := range allGenerators(defaultOptions()) {
+		diagnostics := checkCapabilities(generator, &schema)
+		if len(diagnostics) != expected[generator.name()] {
+			t.Errorf("[%v] Expected %v warnings, found %v", generator.name(), expected[generator.name()], diagnostics)
+			continue
+		}
+
+		for _, diagnostic := range diagnostics {
+			if diagnostic.code != CODE_UNSUPPORTED_CONSTRUCT || diagnostic.severity != SEVERITY_WARNING || diagnostic.start != (LinePos{number: 2, offset: 5}) {
+				t.Errorf("[%v] Unexpected diagnostic: %v", generator.name(), diagnostic.Error())
+			}
+		}
+	}
+
+	options := defaultOptions()
+	options.jsonAnnotations = true
+	if unsupported := unsupportedOptions(&GoGenerator{options}, options); len(unsupported) != 0 {
+		t.Errorf("Expected --json to be supported by Go, found %v", unsupported)
+	}
+	if unsupported := unsupportedOptions(&RustGenerator{options}, options); !slices.Equal(unsupported, []string{"json"}) {
+		t.Errorf("Expected --json to be unsupported by Rust, found %v", unsupported)
+	}
+}
@@ -1,14 +1,20 @@
 
-syn keyword	pgDeclare  type enum
-syn keyword	pgKeyword  func const
+syn keyword	pgDeclare  type enum mixin flags
+syn keyword	pgKeyword  func const use fn
 syn keyword	pgType     i8 i64 i32 i64
 syn keyword	pgType     u8 u16 u32 u64
 syn keyword	pgType     f32 f64
 syn keyword	pgType     bool char string
 
 syn region pgCommentLine start="#" end="$"
+syn region pgString      start=+"+ skip=+\\"+ end=+"+ oneline
+syn match  pgAttribute   "@\k\+"
+syn region pgCodeBlock   start="%\k\+{" end="}%"
 
 hi def link pgKeyword     Keyword
 hi def link pgType        Type
 hi def link pgDeclare     Structure
 hi def link pgCommentLine Comment
+hi def link pgString      String
+hi def link pgAttribute   PreProc
+hi def link pgCodeBlock   Special
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Templates are text/template files rendered against the typechecked schema, which is passed in the same form
+// as written by 'dump typed' (.Types, .Flags, .CodeBlocks). Every file named '<extension>.tmpl' in the template
+// directory produces one file per schema, for example 'sql.tmpl' renders 'cat.tg' into 'cat.sql'.
+const TEMPLATE_EXTENSION = ".tmpl"
+
+// templateOutput is a rendered template, named after the schema file and the template
+type templateOutput struct {
+	name    string
+	content string
+}
+
+func lowercaseFirstLetter(str string) string {
+	if len(str) == 0 {
+		return ""
+	}
+	r, size := utf8.DecodeRuneInString(str)
+	r = unicode.ToLower(r)
+	return string(r) + str[size:]
+}
+
+// Functions available to templates in addition to the built-in ones
+func templateFunctions(options GeneratorOptions) template.FuncMap {
+	// Renders the type of a field as generated by the generator
+	fieldType := func(generator Generator) func(converted jsonField) string {
+		return func(converted jsonField) string {
+			field := translateField(fromJSONField(converted), generator.mapType)
+			return generator.typeString(field)
+		}
+	}
+
+	return template.FuncMap{
+		"snake":  toSnakeCase,
+		"pascal": toPascalCase,
+		"camel": func(name string) string {
+			return lowercaseFirstLetter(toPascalCase(name))
+		},
+		"goType":   fieldType(&GoGenerator{options}),
+		"javaType": fieldType(&JavaGenerator{options}),
+		"isArray": func(field jsonField) bool {
+			return slices.Contains(field.Modifiers, "array")
+		},
+		"isNullable": func(field jsonField) bool {
+			return slices.Contains(field.Modifiers, "nullable")
+		},
+		// Joins elements of any slice, formatted as with 'print': {{join ", " .Names}}
+		"join": func(separator string, items any) (string, error) {
+			value := reflect.ValueOf(items)
+			if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+				return "", fmt.Errorf("join expects a slice, found %T", items)
+			}
+
+			elements := make([]string, value.Len())
+			for i := range elements {
+				elements[i] = fmt.Sprint(value.Index(i).Interface())
+			}
+			return strings.Join(elements, separator), nil
+		},
+		// Indents every non-empty line with the number of spaces: {{indent 4 .Code}}
+		"indent": func(spaces int, text string) string {
+			lines := strings.Split(text, "\n")
+			for i, line := range lines {
+				if line != "" {
+					lines[i] = strings.Repeat(" ", spaces) + line
+				}
+			}
+			return strings.Join(lines, "\n")
+		},
+	}
+}
+
+// Parses every template in the directory. Fails when there are none.
+func loadTemplates(directory string, options GeneratorOptions) (*template.Template, error) {
+	paths, err := filepath.Glob(filepath.Join(directory, "*"+TEMPLATE_EXTENSION))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		message := fmt.Sprintf("No templates (files ending with '%s') found in directory '%s'.", TEMPLATE_EXTENSION, directory)
+		return nil, newDiagnostic(CODE_TEMPLATE_FAILURE, directory, LinePos{}, message)
+	}
+
+	templates, err := template.New("").Funcs(templateFunctions(options)).ParseFiles(paths...)
+	if err != nil {
+		return nil, newDiagnostic(CODE_TEMPLATE_FAILURE, directory, LinePos{}, err.Error())
+	}
+	return templates, nil
+}
+
+// Renders every loaded template against the typechecked file
+func renderTemplates(templates *template.Template, parser *Parser) ([]templateOutput, error) {
+	schema := dumpDeclarations(parser, DUMP_TYPED)
+	outputs := make([]templateOutput, 0)
+	for _, tmpl := range templates.Templates() {
+		extension, isFile := strings.CutSuffix(tmpl.Name(), TEMPLATE_EXTENSION)
+		// Templates defined with {{define}} within the files are only used by other templates
+		if !isFile {
+			continue
+		}
+
+		buffer := bytes.Buffer{}
+		err := tmpl.Execute(&buffer, schema)
+		if err != nil {
+			return nil, newDiagnostic(CODE_TEMPLATE_FAILURE, parser.filepath, LinePos{}, err.Error())
+		}
+		outputs = append(outputs, templateOutput{name: changeExtension(parser.filepath, "."+extension), content: buffer.String()})
+	}
+
+	slices.SortFunc(outputs, func(a, b templateOutput) int { return strings.Compare(a.name, b.name) })
+	return outputs, nil
+}
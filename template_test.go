@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTemplates(t *testing.T, templates map[string]string) string {
+	directory := t.TempDir()
+	for name, content := range templates {
+		err := os.WriteFile(filepath.Join(directory, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return directory
+}
+
+func TestTemplates(t *testing.T) {
+	directory := writeTemplates(t, map[string]string{
+		"sql.tmpl": `{{range .Types}}CREATE TABLE {{snake .Name}} ({{range $i, $f := .Fields}}{{if $i}}, {{end}}{{snake $f.Name}}{{if not (isNullable $f)}} NOT NULL{{end}}{{end}});{{end}}`,
+		"txt.tmpl": `{{range .Types}}{{range .Fields}}{{camel (pascal .Name)}}: {{goType .}} {{javaType .}} {{isArray .}}{{"\n"}}{{end}}{{end}}{{indent 2 "a\n\nb"}}|{{join ", " .Flags}}`,
+		"README":   "Not a template",
+	})
+
+	templates, err := loadTemplates(directory, defaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parser, result := parseAndTypecheck(t, "type UserAccount {\n    string? displayName;\n    [(u32, string)] pairs;\n}\n")
+	if !result.success {
+		t.Fatal(result.message)
+	}
+
+	outputs, err := renderTemplates(templates, &parser)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []templateOutput{
+		{"test.sql", "CREATE TABLE user_account (display_name, pairs NOT NULL);"},
+		{"test.txt", "displayName: string String false\npairs: []UserAccountPairs UserAccountPairs[] true\n  a\n\n  b|"},
+	}
+	if len(outputs) != len(expected) {
+		t.Fatalf("Expected %v outputs, found %+v", len(expected), outputs)
+	}
+	for i, output := range outputs {
+		if output != expected[i] {
+			t.Errorf("Expected output %+v, found %+v", expected[i], output)
+		}
+	}
+}
+
+func TestTemplateCaseFunctions(t *testing.T) {
+	directory := writeTemplates(t, map[string]string{
+		"txt.tmpl": `{{pascal "created_at"}} {{camel "created_at"}} {{pascal "userAccount"}} {{camel "UserAccount"}}`,
+	})
+	templates, err := loadTemplates(directory, defaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parser, _ := parseAndTypecheck(t, "type A {}\n")
+	outputs, err := renderTemplates(templates, &parser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "CreatedAt createdAt UserAccount userAccount"; len(outputs) != 1 || outputs[0].content != expected {
+		t.Errorf("Expected %q, found %+v", expected, outputs)
+	}
+}
+
+func TestTemplateErrors(t *testing.T) {
+	_, err := loadTemplates(t.TempDir(), defaultOptions())
+	var diagnostic Diagnostic
+	if !errors.As(err, &diagnostic) || diagnostic.code != CODE_TEMPLATE_FAILURE {
+		t.Errorf("Expected an error for a directory without templates, found: %v", err)
+	}
+
+	directory := writeTemplates(t, map[string]string{"txt.tmpl": "{{range .Types}}{{join \", \" .Name}}{{end}}"})
+	templates, err := loadTemplates(directory, defaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parser, _ := parseAndTypecheck(t, "type Cat {}\n")
+	_, err = renderTemplates(templates, &parser)
+	if !errors.As(err, &diagnostic) || diagnostic.code != CODE_TEMPLATE_FAILURE || diagnostic.file != "test" {
+		t.Errorf("Expected join of a string to fail, found: %v", err)
+	}
+}